@@ -0,0 +1,202 @@
+// Package analysis turns text into index terms.
+//
+// An Analyzer is a pipeline: char filters change runes of the source text, the tokenizer splits it into tokens
+// and token filters change or drop the tokens one by one. The same analyzer has to be used
+// when the index is built and when it is searched, so indexes record the name of their analyzer.
+//
+// The auto analyzer detects the language of every text and analyzes it with the analyzer of the language,
+// queries to such indexes are analyzed with the analyzers of all languages.
+package analysis
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// Token is a term of the text with the byte offset of its start in the source text
+type Token struct {
+	Term   string
+	Offset int
+	Type   TokenType
+}
+
+// TokenType is the kind of the token recognized by the tokenizer
+type TokenType uint8
+
+const (
+	Word TokenType = iota
+	Number
+	Email
+	URL
+	// Ident is a word of an identifier split by case or underscores, like parse and request of parseRequest
+	Ident
+)
+
+var typeNames = [...]string{Word: "word", Number: "number", Email: "email", URL: "url", Ident: "ident"}
+
+func (t TokenType) String() string {
+	if int(t) < len(typeNames) {
+		return typeNames[t]
+	}
+	return "unknown"
+}
+
+// ParseTokenType returns the token type by its name
+func ParseTokenType(name string) (TokenType, bool) {
+	for t, n := range typeNames {
+		if n == name {
+			return TokenType(t), true
+		}
+	}
+	return Word, false
+}
+
+// TypedTerm returns the term marked with its type, like number:2020.
+// Tokens of other types than Word are indexed both as is and as typed terms, so queries can target the type
+func TypedTerm(t TokenType, term string) string {
+	return t.String() + ":" + term
+}
+
+// CharFilter maps runes of the source text before they are tokenized, negative result drops the rune
+type CharFilter func(r rune) rune
+
+// TokenFilter changes the token, the token is dropped if false is returned
+type TokenFilter func(t *Token) bool
+
+// Tokenizer splits runes of the reader into tokens
+type Tokenizer interface {
+	Tokenize(r *Reader, fn func(Token)) error
+}
+
+// Reader reads runes of the source text passed through the char filters
+type Reader struct {
+	r       io.RuneReader
+	filters []CharFilter
+	offset  int
+}
+
+// NewReader creates reader applying the char filters in order
+func NewReader(reader io.Reader, filters ...CharFilter) *Reader {
+	rr, ok := reader.(io.RuneReader)
+	if !ok {
+		rr = bufio.NewReader(reader)
+	}
+	return &Reader{r: rr, filters: filters}
+}
+
+// Next returns the next rune and its byte offset in the source text, io.EOF is returned at the end
+func (r *Reader) Next() (rune, int, error) {
+	for {
+		c, size, err := r.r.ReadRune()
+		if err != nil {
+			return 0, 0, err
+		}
+		offset := r.offset
+		r.offset += size
+		for _, f := range r.filters {
+			if c = f(c); c < 0 {
+				break
+			}
+		}
+		if c >= 0 {
+			return c, offset, nil
+		}
+	}
+}
+
+// Analyzer converts text into terms
+type Analyzer struct {
+	// Name identifies the analyzer, Get returns the same analyzer by it
+	Name        string
+	CharFilters []CharFilter
+	Tokenizer   Tokenizer
+	Filters     []TokenFilter
+	// Languages replace the pipeline if they are set: every text is analyzed by the analyzer of its language
+	Languages []*Language
+
+	// spec is the canonical spec of the pipeline
+	spec string
+	// kinds of the filters, filters without a kind are plain
+	kinds []filterKind
+}
+
+// stopMode tells which stop filters are applied
+type stopMode uint8
+
+const (
+	// indexStop applies the stop filters of the index
+	indexStop stopMode = iota
+	// queryStop applies all stop filters
+	queryStop
+	// noStop applies no stop filters
+	noStop
+)
+
+func (a *Analyzer) addFilter(f TokenFilter, kind filterKind) {
+	a.Filters = append(a.Filters, f)
+	a.kinds = append(a.kinds, kind)
+}
+
+// skip reports if the filter is not applied in the mode
+func (a *Analyzer) skip(i int, mode stopMode) bool {
+	if i >= len(a.kinds) {
+		return false
+	}
+	switch a.kinds[i] {
+	case stopFilter:
+		return mode == noStop
+	case queryStopFilter:
+		return mode != queryStop
+	}
+	return false
+}
+
+// Analyze passes the tokens left after the filters to the function, it is used to index texts.
+// Tokens read before an error are still passed, the error is returned
+func (a *Analyzer) Analyze(reader io.Reader, fn func(Token)) error {
+	return a.analyze(reader, fn, indexStop)
+}
+
+func (a *Analyzer) analyze(reader io.Reader, fn func(Token), mode stopMode) error {
+	if len(a.Languages) > 0 {
+		return a.analyzeDetected(reader, fn, mode)
+	}
+	return a.Tokenizer.Tokenize(NewReader(reader, a.CharFilters...), func(t Token) {
+		for i, f := range a.Filters {
+			if !a.skip(i, mode) && !f(&t) {
+				return
+			}
+		}
+		fn(t)
+	})
+}
+
+func (a *Analyzer) terms(text string, mode stopMode) []string {
+	var res []string
+	// strings.Reader never fails
+	_ = a.analyze(strings.NewReader(text), func(t Token) {
+		res = append(res, t.Term)
+	}, mode)
+	return res
+}
+
+// Terms returns terms of the text without stop words, it is used to analyze search queries
+func (a *Analyzer) Terms(text string) []string {
+	return a.terms(text, queryStop)
+}
+
+// PhraseTerms returns terms of the quoted phrase of a query,
+// stop words are kept if the index keeps them
+func (a *Analyzer) PhraseTerms(text string) []string {
+	return a.terms(text, indexStop)
+}
+
+// AllTerms returns terms of the text with stop words, they are searched if the query has nothing else
+func (a *Analyzer) AllTerms(text string) []string {
+	return a.terms(text, noStop)
+}
+
+func (a *Analyzer) String() string {
+	return a.Name
+}
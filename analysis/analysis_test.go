@@ -0,0 +1,197 @@
+package analysis
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func tokens(t *testing.T, a *Analyzer, text string) []Token {
+	var res []Token
+	require.NoError(t, a.Analyze(strings.NewReader(text), func(tok Token) {
+		res = append(res, tok)
+	}))
+	return res
+}
+
+func TestDefault(t *testing.T) {
+	a := Default()
+	require.Equal(t, DefaultName, a.Name)
+	require.Equal(t, []Token{{"hello", 3, Word}, {"мир", 12, Word}, {"world", 24, Word}},
+		tokens(t, a, "  (Hello),\n\tмир the  world!"))
+	require.Equal(t, []string{"beauti", "e-mail"}, a.Terms("a Beautiful e-mail 42"))
+	require.Nil(t, a.Terms("you and THE"))
+}
+
+func TestGet(t *testing.T) {
+	a, err := Get("simple")
+	require.NoError(t, err)
+	require.Equal(t, "simple", a.Name)
+	require.Equal(t, []string{"the", "e", "mail", "x"}, a.Terms("The e-mail 42x"))
+
+	b, err := Get("simple")
+	require.NoError(t, err)
+	require.True(t, a == b, "analyzers are cached")
+
+	a, err = Get(" quotes | word|lowercase| length( 2 ,5 ) ")
+	require.NoError(t, err)
+	require.Equal(t, "quotes|word|lowercase|length(2,5)", a.Name)
+	require.Equal(t, []string{"it", "go113", "go"}, a.Terms("It’s a GO113 Go longword"))
+
+	a, err = Get("lowercase|whitespace|lowercase")
+	require.NoError(t, err)
+	require.Len(t, a.CharFilters, 1)
+	require.Len(t, a.Filters, 1)
+
+	for _, spec := range []string{"unknown", "word|unknown", "quotes", "word|length(1)", "word|length(x,1)",
+		"word(1)", "word|stop(1)", "word||stop", "word|length(1,2"} {
+		_, err := Get(spec)
+		require.Error(t, err, spec)
+	}
+
+	require.Equal(t, []string{"auto", "english", "russian", "simple", "standard", "unicode"}, Names())
+}
+
+func TestStandard(t *testing.T) {
+	a, err := Get("standard")
+	require.NoError(t, err)
+	require.Equal(t, []Token{
+		{"creme", 0, Word}, {"brulee", 7, Word}, {"strasse", 17, Word}, {"ёлка", 29, Word}, {"2020", 42, Word}},
+		tokens(t, a, "Crème brûlée; Straße and ёлка — 2020"))
+}
+
+func TestFilters(t *testing.T) {
+	tok := Token{Term: "«Hello»,", Offset: 10}
+	require.True(t, trim(&tok))
+	require.Equal(t, Token{Term: "Hello", Offset: 12}, tok)
+
+	tok = Token{Term: "123"}
+	require.False(t, trim(&tok))
+
+	tok = Token{Term: "Ærø Łódź"}
+	require.True(t, foldASCII(&tok))
+	require.Equal(t, "AEro Lodz", tok.Term)
+
+	tok = Token{Term: "Ёж"}
+	require.True(t, foldASCII(&tok))
+	require.Equal(t, "Ёж", tok.Term, "cyrillic letters are kept")
+
+	require.Equal(t, '\'', quotes('’'))
+	require.Equal(t, 'a', quotes('a'))
+}
+
+func TestStopWords(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stop")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "stop.txt")
+	require.NoError(t, ioutil.WriteFile(file, []byte("# custom list\nGolang  rust\n\nЁлка\n"), 0644))
+
+	a, err := Get("word|stop(" + file + ")")
+	require.NoError(t, err)
+	require.Equal(t, []string{"the", "Go"}, a.Terms("the Golang елка Go ёлка RUST"))
+
+	a, err = Get("word|stop( en ," + file + ",ru)")
+	require.NoError(t, err)
+	require.Equal(t, "word|stop(en,words:golang rust елка,ru)", a.Name, "words of the file are recorded")
+	require.Equal(t, []string{"Java"}, a.Terms("the Golang и Java"))
+
+	_, err = Get("word|stop(" + filepath.Join(dir, "missing.txt") + ")")
+	require.Error(t, err)
+
+	english := Default()
+	a, err = WithStopWords(english, nil, true)
+	require.NoError(t, err)
+	require.Equal(t, "whitespace|trim|stop(en,keep)|porter", a.Name)
+	require.Equal(t, []string{"the", "golang"}, terms(t, a, "The golang"), "stop words are indexed")
+	require.Equal(t, []string{"golang"}, a.Terms("The golang"))
+	require.Equal(t, []string{"the", "golang"}, a.PhraseTerms("The golang"))
+	require.Equal(t, []string{"golang"}, english.PhraseTerms("The golang"))
+	require.Equal(t, []string{"the", "golang"}, english.AllTerms("The golang"))
+
+	a, err = WithStopWords(english, []string{"none"}, false)
+	require.NoError(t, err)
+	require.Equal(t, "whitespace|trim|porter", a.Name)
+
+	a, err = WithStopWords(english, []string{"en", file}, false)
+	require.NoError(t, err)
+	require.Equal(t, "whitespace|trim|stop(en,words:golang rust елка)|porter", a.Name)
+
+	simple, err := Get("simple")
+	require.NoError(t, err)
+	a, err = WithStopWords(simple, []string{"ru"}, true)
+	require.NoError(t, err)
+	require.Equal(t, "letter|lowercase|stop(ru,keep)", a.Name)
+
+	a, err = Get("russian")
+	require.NoError(t, err)
+	a, err = WithStopWords(a, nil, true)
+	require.NoError(t, err)
+	require.Equal(t, "whitespace|trim|stop(ru,keep)|stem_ru", a.Name)
+
+	auto, err := Get("auto")
+	require.NoError(t, err)
+	_, err = WithStopWords(auto, []string{"none"}, false)
+	require.Error(t, err)
+}
+
+func TestStopWords_Recorded(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stop")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "stop.txt")
+	require.NoError(t, ioutil.WriteFile(file, []byte("golang c++ a|b (x,y) 100%\n"), 0644))
+
+	a, err := Get("word|stop(" + file + ",keep)")
+	require.NoError(t, err)
+	require.Equal(t, "word|stop(words:%28x%2Cy%29 100%25 a%7Cb c++ golang,keep)", a.Name)
+
+	require.NoError(t, ioutil.WriteFile(file, []byte("rust\n"), 0644))
+	loaded, err := build(a.Name)
+	require.NoError(t, err, "the edited file is not read again")
+	require.Equal(t, []string{"rust"}, loaded.Terms("golang rust"))
+
+	require.NoError(t, os.Remove(file))
+	loaded, err = build(a.Name)
+	require.NoError(t, err, "the removed file is not read again")
+	require.Equal(t, a.Name, loaded.Name)
+
+	words, err := loadStopList(a.Name[len("word|stop(") : len(a.Name)-len(",keep)")])
+	require.NoError(t, err)
+	require.Equal(t, map[string]bool{"(x,y)": true, "100%": true, "a|b": true, "c++": true, "golang": true}, words)
+}
+
+func terms(t *testing.T, a *Analyzer, text string) []string {
+	var res []string
+	for _, tok := range tokens(t, a, text) {
+		res = append(res, tok.Term)
+	}
+	return res
+}
+
+type failingReader struct {
+	data string
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.data == "" {
+		return 0, errors.New("read failed")
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestAnalyze_Error(t *testing.T) {
+	var terms []string
+	err := Default().Analyze(&failingReader{data: "hello world"}, func(tok Token) {
+		terms = append(terms, tok.Term)
+	})
+	require.Error(t, err)
+	require.Equal(t, []string{"hello", "world"}, terms, "tokens read before the error are kept")
+}
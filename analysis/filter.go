@@ -0,0 +1,279 @@
+package analysis
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/reiver/go-porterstemmer"
+	"golang.org/x/text/unicode/norm"
+)
+
+// filterFactory creates the filter with the arguments given in the analyzer spec
+type filterFactory func(args []string) (TokenFilter, error)
+
+var tokenFilters = map[string]filterFactory{
+	"lowercase": noArgs(lowercase),
+	"trim":      noArgs(trim),
+	"porter":    noArgs(porter),
+	"stem_en":   noArgs(porter),
+	"stem_ru":   noArgs(stemRu),
+	"ascii":     noArgs(foldASCII),
+	"length":    lengthFilter,
+}
+
+// stopFilters are the names of the stop filters and their default lists
+var stopFilters = map[string]string{
+	"stop":    "en",
+	"stop_en": "en",
+	"stop_ru": "ru",
+}
+
+// stemmers are the filters before which stop words are removed
+var stemmers = map[string]bool{
+	"porter":  true,
+	"stem_en": true,
+	"stem_ru": true,
+}
+
+// stopLists are the builtin stop lists
+var stopLists = map[string]map[string]bool{
+	"en": english,
+	"ru": russian,
+}
+
+// filterKind tells when the filter is applied
+type filterKind uint8
+
+const (
+	plainFilter filterKind = iota
+	// stopFilter drops stop words from texts and queries
+	stopFilter
+	// queryStopFilter drops stop words from queries only, they stay in the index
+	queryStopFilter
+)
+
+// keepArg of a stop filter leaves stop words in the index
+const keepArg = "keep"
+
+var charFilters = map[string]CharFilter{
+	"lowercase": unicode.ToLower,
+	"quotes":    quotes,
+}
+
+func noArgs(f TokenFilter) filterFactory {
+	return func(args []string) (TokenFilter, error) {
+		if len(args) != 0 {
+			return nil, fmt.Errorf("unexpected arguments %v", args)
+		}
+		return f, nil
+	}
+}
+
+func lowercase(t *Token) bool {
+	t.Term = strings.ToLower(t.Term)
+	return true
+}
+
+// trim removes runes other than letters from both ends of the token
+func trim(t *Token) bool {
+	start := strings.IndexFunc(t.Term, unicode.IsLetter)
+	if start < 0 {
+		return false
+	}
+	t.Offset += start
+	t.Term = strings.TrimRightFunc(t.Term[start:], func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	return true
+}
+
+// wordsPrefix marks the argument of a stop filter listing the words, like stop(words:golang rust).
+// Files are written so in the canonical spec, the analyzer recorded by an index does not depend on the file
+const wordsPrefix = "words:"
+
+// wordsEscaper escapes the runes which separate elements and arguments of the spec
+var (
+	wordsEscaper   = strings.NewReplacer("%", "%25", ",", "%2C", "|", "%7C", "(", "%28", ")", "%29")
+	wordsUnescaper = strings.NewReplacer("%25", "%", "%2C", ",", "%7C", "|", "%28", "(", "%29", ")")
+)
+
+// newStopFilter makes the filter dropping words of the lists in any case, the lists are builtin lists,
+// files or listed words. The default list is used if no lists are given.
+// The canonical arguments have the words of the files instead of their names
+func newStopFilter(list string, args []string) (TokenFilter, filterKind, []string, error) {
+	kind := stopFilter
+	var lists []map[string]bool
+	canonical := make([]string, 0, len(args))
+	for _, arg := range args {
+		if arg == keepArg {
+			kind = queryStopFilter
+			canonical = append(canonical, arg)
+			continue
+		}
+		words, err := loadStopList(arg)
+		if err != nil {
+			return nil, kind, nil, err
+		}
+		if _, ok := stopLists[arg]; !ok {
+			arg = formatWords(words)
+		}
+		canonical = append(canonical, arg)
+		lists = append(lists, words)
+	}
+	if len(lists) == 0 {
+		lists = append(lists, stopLists[list])
+	}
+
+	words := lists[0]
+	if len(lists) > 1 {
+		words = make(map[string]bool)
+		for _, l := range lists {
+			for w := range l {
+				words[w] = true
+			}
+		}
+	}
+	return func(t *Token) bool {
+		return !words[stopKey(t.Term)]
+	}, kind, canonical, nil
+}
+
+// loadStopList returns the builtin list, the listed words or reads the file with a word per line,
+// lines starting with # are skipped
+func loadStopList(name string) (map[string]bool, error) {
+	if words, ok := stopLists[name]; ok {
+		return words, nil
+	}
+	if strings.HasPrefix(name, wordsPrefix) {
+		words := make(map[string]bool)
+		for _, w := range strings.Fields(name[len(wordsPrefix):]) {
+			words[wordsUnescaper.Replace(w)] = true
+		}
+		return words, nil
+	}
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("stop list: %w", err)
+	}
+	words := make(map[string]bool)
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, w := range strings.Fields(line) {
+			words[stopKey(w)] = true
+		}
+	}
+	return words, nil
+}
+
+// formatWords writes the sorted words as the argument of a stop filter
+func formatWords(words map[string]bool) string {
+	list := make([]string, 0, len(words))
+	for w := range words {
+		list = append(list, wordsEscaper.Replace(w))
+	}
+	sort.Strings(list)
+	return wordsPrefix + strings.Join(list, " ")
+}
+
+// stopKey is the form of the words in stop lists: lowercase with 'ё' written as 'е'
+func stopKey(word string) string {
+	return strings.Replace(strings.ToLower(word), "ё", "е", -1)
+}
+
+// porter stems english words, the result is lowercase
+func porter(t *Token) bool {
+	t.Term = porterstemmer.StemString(t.Term)
+	return t.Term != ""
+}
+
+// stemRu stems russian words with the snowball stemmer, the result is lowercase
+func stemRu(t *Token) bool {
+	t.Term = stemRussian(strings.ToLower(t.Term))
+	return t.Term != ""
+}
+
+// foldSpecial maps latin letters without decomposition to their ascii spelling
+var foldSpecial = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'Æ': "AE", 'œ': "oe", 'Œ': "OE", 'ø': "o", 'Ø': "O",
+	'đ': "d", 'Đ': "D", 'ð': "d", 'Ð': "D", 'ł': "l", 'Ł': "L", 'þ': "th", 'Þ': "TH", 'ı': "i",
+}
+
+// foldASCII removes diacritics of latin letters, letters of other scripts are kept as is
+func foldASCII(t *Token) bool {
+	ascii := true
+	for i := 0; i < len(t.Term); i++ {
+		if t.Term[i] >= utf8.RuneSelf {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return true
+	}
+
+	var sb strings.Builder
+	// latin is set if the last base rune is a latin letter, its marks are dropped
+	var latin bool
+	for _, r := range norm.NFD.String(t.Term) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			if !latin {
+				sb.WriteRune(r)
+			}
+			continue
+		case foldSpecial[r] != "":
+			sb.WriteString(foldSpecial[r])
+		default:
+			sb.WriteRune(r)
+		}
+		latin = unicode.Is(unicode.Latin, r)
+	}
+	t.Term = norm.NFC.String(sb.String())
+	return t.Term != ""
+}
+
+// lengthFilter keeps tokens of min to max runes, 2 and 64 if they are not given
+func lengthFilter(args []string) (TokenFilter, error) {
+	min, max := 2, 64
+	switch len(args) {
+	case 0:
+	case 2:
+		var err error
+		if min, err = strconv.Atoi(args[0]); err != nil {
+			return nil, fmt.Errorf("minimal length: %w", err)
+		}
+		if max, err = strconv.Atoi(args[1]); err != nil {
+			return nil, fmt.Errorf("maximal length: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("expected minimal and maximal length, got %v", args)
+	}
+	if min < 0 || max < min {
+		return nil, fmt.Errorf("invalid length range %d to %d", min, max)
+	}
+	return func(t *Token) bool {
+		n := utf8.RuneCountInString(t.Term)
+		return n >= min && n <= max
+	}, nil
+}
+
+// quotes replaces typographic apostrophes, quotes and dashes with ascii ones
+func quotes(r rune) rune {
+	switch r {
+	case '‘', '’', '‛', 'ʼ', '′':
+		return '\''
+	case '“', '”', '„', '‟', '″', '«', '»':
+		return '"'
+	case '‐', '‑', '‒', '–', '—', '―', '−':
+		return '-'
+	}
+	return r
+}
@@ -0,0 +1,104 @@
+package analysis
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"unicode"
+)
+
+const (
+	// detectSample is the number of bytes at the start of the text used to detect its language
+	detectSample = 4096
+	// stopWeight is the score of a stop word of the language, every letter of its script scores 1
+	stopWeight = 5
+)
+
+// Language is the analyzer of the texts in one language
+type Language struct {
+	Code     string
+	Analyzer *Analyzer
+	script   *unicode.RangeTable
+	stop     map[string]bool
+}
+
+// languages known to the auto analyzer, the first one is used when nothing is recognized
+var languages = []struct {
+	code     string
+	analyzer string
+	script   *unicode.RangeTable
+	stop     map[string]bool
+}{
+	{code: "en", analyzer: "english", script: unicode.Latin, stop: english},
+	{code: "ru", analyzer: "russian", script: unicode.Cyrillic, stop: russian},
+}
+
+// autoName is the analyzer choosing the language of every text
+const autoName = "auto"
+
+// buildAuto makes the analyzer of all known languages, the caller must hold the cache lock
+func buildAuto() (*Analyzer, error) {
+	a := &Analyzer{Name: autoName}
+	for _, l := range languages {
+		la, err := getLocked(l.analyzer)
+		if err != nil {
+			return nil, err
+		}
+		a.Languages = append(a.Languages, &Language{Code: l.code, Analyzer: la, script: l.script, stop: l.stop})
+	}
+	return a, nil
+}
+
+// Detect returns the language of the text sample scoring letters of the language script and its stop words
+func (a *Analyzer) Detect(sample []byte) *Language {
+	if len(a.Languages) == 0 {
+		return nil
+	}
+	scores := make([]int, len(a.Languages))
+	words := strings.FieldsFunc(string(sample), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	for _, w := range words {
+		w = strings.ToLower(w)
+		for i, l := range a.Languages {
+			if l.stop[strings.Replace(w, "ё", "е", -1)] {
+				scores[i] += stopWeight
+			}
+			for _, r := range w {
+				if unicode.Is(l.script, r) {
+					scores[i]++
+				}
+			}
+		}
+	}
+	best := 0
+	for i, s := range scores {
+		if s > scores[best] {
+			best = i
+		}
+	}
+	return a.Languages[best]
+}
+
+// analyzeDetected analyzes the text with the analyzer of its language detected by the start of the text
+func (a *Analyzer) analyzeDetected(reader io.Reader, fn func(Token), mode stopMode) error {
+	br := bufio.NewReaderSize(reader, detectSample)
+	sample, err := br.Peek(detectSample)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return err
+	}
+	return a.Detect(sample).Analyzer.analyze(br, fn, mode)
+}
+
+// QueryAnalyzers returns the analyzers of all languages, queries are too short to detect their language,
+// so they are analyzed with every analyzer. Analyzers without languages return themselves
+func (a *Analyzer) QueryAnalyzers() []*Analyzer {
+	if len(a.Languages) == 0 {
+		return []*Analyzer{a}
+	}
+	res := make([]*Analyzer, len(a.Languages))
+	for i, l := range a.Languages {
+		res[i] = l.Analyzer
+	}
+	return res
+}
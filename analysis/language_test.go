@@ -0,0 +1,62 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStemRussian(t *testing.T) {
+	for word, stem := range map[string]string{
+		"вавиловка":   "вавиловк",
+		"важнейшие":   "важн",
+		"длинный":     "длин",
+		"гуляя":       "гул",
+		"бегавшие":    "бега",
+		"ёлка":        "елк",
+		"красивейший": "красив",
+		"книгами":     "книг",
+		"читала":      "чита",
+		"радость":     "радост",
+		"мир":         "мир",
+		"вв":          "вв",
+	} {
+		require.Equal(t, stem, stemRussian(word), word)
+	}
+}
+
+func TestRussian(t *testing.T) {
+	a, err := Get("russian")
+	require.NoError(t, err)
+	require.Equal(t, []Token{{"красив", 0, Word}, {"книг", 22, Word}},
+		tokens(t, a, "Красивые и «книги»"))
+	require.Nil(t, a.Terms("Что же ЭТО"))
+}
+
+func TestDetect(t *testing.T) {
+	a, err := Get("auto")
+	require.NoError(t, err)
+	require.Equal(t, "auto", a.Name)
+
+	require.Equal(t, "ru", a.Detect([]byte("Мама мыла раму, и это было хорошо")).Code)
+	require.Equal(t, "en", a.Detect([]byte("The quick brown fox")).Code)
+	require.Equal(t, "ru", a.Detect([]byte("Статья про Kubernetes и Docker")).Code)
+	require.Equal(t, "en", a.Detect([]byte("Using Go with Москва in the title")).Code)
+	require.Equal(t, "en", a.Detect(nil).Code, "the first language is the default")
+}
+
+func TestAuto(t *testing.T) {
+	a, err := Get("auto")
+	require.NoError(t, err)
+	require.Equal(t, []string{"красив", "книг"}, a.Terms("красивые книги"))
+	require.Equal(t, []string{"beauti", "book"}, a.Terms("beautiful books"))
+
+	queries := a.QueryAnalyzers()
+	require.Len(t, queries, 2)
+	require.Equal(t, "english", queries[0].Name)
+	require.Equal(t, "russian", queries[1].Name)
+
+	english, err := Get("english")
+	require.NoError(t, err)
+	require.Equal(t, []*Analyzer{english}, english.QueryAnalyzers())
+}
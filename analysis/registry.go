@@ -0,0 +1,251 @@
+package analysis
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultName is the analyzer of the indexes which do not record their analyzer.
+// It splits the text by spaces, trims punctuation, drops english stop words and stems the words
+const DefaultName = "english"
+
+// named analyzers and their specs
+var named = map[string]string{
+	"english":  "whitespace|trim|stop|porter",
+	"russian":  "whitespace|trim|stop_ru|stem_ru",
+	"simple":   "letter|lowercase",
+	"standard": "quotes|word|lowercase|ascii|stop|length(1,64)",
+	"unicode":  "quotes|uax29|lowercase|ascii|stop|length(1,256)",
+}
+
+var (
+	// cache keeps the analyzers by the names they were requested with,
+	// so indexes made by the same analyzer share it
+	cache   = make(map[string]*Analyzer)
+	cacheMu sync.Mutex
+
+	defaultAnalyzer = mustGet(DefaultName)
+)
+
+// Default returns the default analyzer
+func Default() *Analyzer {
+	return defaultAnalyzer
+}
+
+// Names returns names of the predefined analyzers
+func Names() []string {
+	res := make([]string, 0, len(named)+1)
+	for name := range named {
+		res = append(res, name)
+	}
+	res = append(res, autoName)
+	sort.Strings(res)
+	return res
+}
+
+// Get returns the predefined analyzer by its name or the analyzer made by the spec.
+// The auto analyzer chooses the english or the russian analyzer by the language of the text.
+// The spec lists char filters, the tokenizer and token filters separated by "|",
+// filters may have arguments, like "quotes|word|lowercase|stop(en,stop.txt)|length(2,20)".
+// Empty name means the default analyzer
+func Get(name string) (*Analyzer, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = DefaultName
+	}
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	return getLocked(name)
+}
+
+func getLocked(name string) (*Analyzer, error) {
+	if a, ok := cache[name]; ok {
+		return a, nil
+	}
+	a, err := build(name)
+	if err != nil {
+		return nil, err
+	}
+	cache[name] = a
+	return a, nil
+}
+
+func build(name string) (*Analyzer, error) {
+	if name == autoName {
+		return buildAuto()
+	}
+	if spec, ok := named[name]; ok {
+		a, err := parseSpec(spec)
+		if err != nil {
+			return nil, fmt.Errorf("analyzer %s: %w", name, err)
+		}
+		a.Name = name
+		return a, nil
+	}
+	a, err := parseSpec(name)
+	if err != nil {
+		return nil, fmt.Errorf("analyzer %q: %w", name, err)
+	}
+	a.Name = a.spec
+	return a, nil
+}
+
+func mustGet(name string) *Analyzer {
+	a, err := Get(name)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+// parseSpec makes the analyzer of the spec, the spec is kept in the canonical form
+func parseSpec(spec string) (*Analyzer, error) {
+	a := &Analyzer{}
+	parts := strings.Split(spec, "|")
+	canonical := make([]string, len(parts))
+	for i, part := range parts {
+		name, args, err := parseElement(part)
+		if err != nil {
+			return nil, err
+		}
+		canonical[i] = formatElement(name, args)
+
+		// char filters go before the tokenizer and token filters after it, so the names never clash
+		if a.Tokenizer == nil {
+			if f, ok := charFilters[name]; ok && len(args) == 0 {
+				a.CharFilters = append(a.CharFilters, f)
+				continue
+			}
+			t, ok := tokenizers[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown tokenizer or char filter %s", name)
+			}
+			if len(args) != 0 {
+				return nil, fmt.Errorf("tokenizer %s has no arguments", name)
+			}
+			a.Tokenizer = t
+			continue
+		}
+		if list, ok := stopFilters[name]; ok {
+			f, kind, stopArgs, err := newStopFilter(list, args)
+			if err != nil {
+				return nil, fmt.Errorf("token filter %s: %w", name, err)
+			}
+			canonical[i] = formatElement(name, stopArgs)
+			a.addFilter(f, kind)
+			continue
+		}
+		factory, ok := tokenFilters[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown token filter %s", name)
+		}
+		f, err := factory(args)
+		if err != nil {
+			return nil, fmt.Errorf("token filter %s: %w", name, err)
+		}
+		a.addFilter(f, plainFilter)
+	}
+	if a.Tokenizer == nil {
+		return nil, errors.New("no tokenizer")
+	}
+	a.spec = strings.Join(canonical, "|")
+	return a, nil
+}
+
+// WithStopWords returns the analyzer with its stop filters replaced by the filter of the lists,
+// the lists are en, ru or files with a word per line, "none" removes the stop filters
+// and no lists keep the lists of the analyzer.
+// If keep is set, stop words stay in the index and are dropped from queries only
+func WithStopWords(a *Analyzer, lists []string, keep bool) (*Analyzer, error) {
+	if len(lists) == 0 && !keep {
+		return a, nil
+	}
+	if len(a.Languages) > 0 {
+		return nil, fmt.Errorf("stop words of the %s analyzer can not be changed", a.Name)
+	}
+	none := len(lists) == 1 && lists[0] == "none"
+
+	var res []string
+	// insert is the position of the new stop filter: the first stop filter or the first stemmer
+	insert := -1
+	for _, part := range strings.Split(a.spec, "|") {
+		name, args, err := parseElement(part)
+		if err != nil {
+			return nil, err
+		}
+		list, ok := stopFilters[name]
+		switch {
+		case ok && len(lists) == 0:
+			args = withoutArg(args, keepArg)
+			if len(args) == 0 {
+				args = []string{list}
+			}
+			args = append(args, keepArg)
+			res = append(res, formatElement("stop", args))
+			continue
+		case ok:
+			if insert < 0 {
+				insert = len(res)
+			}
+			continue
+		case stemmers[name] && insert < 0:
+			insert = len(res)
+		}
+		res = append(res, formatElement(name, args))
+	}
+	if len(lists) > 0 && !none {
+		if insert < 0 {
+			insert = len(res)
+		}
+		args := append([]string(nil), lists...)
+		if keep {
+			args = append(args, keepArg)
+		}
+		res = append(res[:insert], append([]string{formatElement("stop", args)}, res[insert:]...)...)
+	}
+	return Get(strings.Join(res, "|"))
+}
+
+func withoutArg(args []string, arg string) []string {
+	var res []string
+	for _, a := range args {
+		if a != arg {
+			res = append(res, a)
+		}
+	}
+	return res
+}
+
+// parseElement parses the name and the arguments of the element, like length(2,20)
+func parseElement(s string) (string, []string, error) {
+	s = strings.TrimSpace(s)
+	open := strings.IndexByte(s, '(')
+	if open < 0 {
+		if s == "" {
+			return "", nil, errors.New("empty element")
+		}
+		return s, nil, nil
+	}
+	if !strings.HasSuffix(s, ")") {
+		return "", nil, fmt.Errorf("unclosed arguments of %s", s)
+	}
+	var args []string
+	for _, arg := range strings.Split(s[open+1:len(s)-1], ",") {
+		arg = strings.TrimSpace(arg)
+		if arg == "" {
+			return "", nil, fmt.Errorf("empty argument of %s", s)
+		}
+		args = append(args, arg)
+	}
+	return strings.TrimSpace(s[:open]), args, nil
+}
+
+func formatElement(name string, args []string) string {
+	if len(args) == 0 {
+		return name
+	}
+	return name + "(" + strings.Join(args, ",") + ")"
+}
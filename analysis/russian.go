@@ -0,0 +1,163 @@
+package analysis
+
+import (
+	"strings"
+)
+
+// Russian stemmer implements the Snowball algorithm:
+// https://snowballstem.org/algorithms/russian/stemmer.html
+//
+// Endings of the first groups are removed only after 'а' or 'я', which is kept.
+// Among several matching endings of a class the longest one is chosen.
+var (
+	perfectiveGerund1 = []string{"в", "вши", "вшись"}
+	perfectiveGerund2 = []string{"ив", "ивши", "ившись", "ыв", "ывши", "ывшись"}
+	adjective         = []string{"ее", "ие", "ые", "ое", "ими", "ыми", "ей", "ий", "ый", "ой", "ем", "им", "ым", "ом",
+		"его", "ого", "ему", "ому", "их", "ых", "ую", "юю", "ая", "яя", "ою", "ею"}
+	participle1 = []string{"ем", "нн", "вш", "ющ", "щ"}
+	participle2 = []string{"ивш", "ывш", "ующ"}
+	reflexive   = []string{"ся", "сь"}
+	verb1       = []string{"ла", "на", "ете", "йте", "ли", "й", "л", "ем", "н", "ло", "но", "ет", "ют", "ны", "ть", "ешь",
+		"нно"}
+	verb2 = []string{"ила", "ыла", "ена", "ейте", "уйте", "ите", "или", "ыли", "ей", "уй", "ил", "ыл", "им", "ым", "ен",
+		"ило", "ыло", "ено", "ят", "ует", "уют", "ит", "ыт", "ены", "ить", "ыть", "ишь", "ую", "ю"}
+	noun = []string{"а", "ев", "ов", "ие", "ье", "е", "иями", "ями", "ами", "еи", "ии", "и", "ией", "ей", "ой", "ий", "й",
+		"иям", "ям", "ием", "ем", "ам", "ом", "о", "у", "ах", "иях", "ях", "ы", "ь", "ию", "ью", "ю", "ия", "ья", "я"}
+	superlative   = []string{"ейш", "ейше"}
+	derivational  = []string{"ост", "ость"}
+	russianVowels = "аеиоуыэюя"
+)
+
+// stemRussian returns the stem of the lowercase russian word, words without cyrillic vowels are kept as is
+func stemRussian(word string) string {
+	w := []rune(strings.Replace(word, "ё", "е", -1))
+	rv, r2 := russianRegions(w)
+	if rv == len(w) {
+		return string(w)
+	}
+	s := &stemmer{w: w, limit: rv}
+
+	// step 1
+	if !s.removeGroups(perfectiveGerund1, perfectiveGerund2) {
+		s.remove(reflexive)
+		if s.remove(adjective) {
+			s.removeGroups(participle1, participle2)
+		} else if !s.removeGroups(verb1, verb2) {
+			s.remove(noun)
+		}
+	}
+	// step 2
+	s.remove([]string{"и"})
+	// step 3
+	if e := s.longest(derivational); e != "" && len(s.w)-len([]rune(e)) >= r2 {
+		s.w = s.w[:len(s.w)-len([]rune(e))]
+	}
+	// step 4
+	switch {
+	case s.remove(superlative):
+		s.undouble()
+	case s.undouble():
+	default:
+		s.remove([]string{"ь"})
+	}
+	return string(s.w)
+}
+
+// russianRegions returns starts of RV, the region after the first vowel,
+// and R2, the region after the first non-vowel following a vowel in R1, which is defined the same way
+func russianRegions(w []rune) (int, int) {
+	rv := len(w)
+	for i, r := range w {
+		if strings.ContainsRune(russianVowels, r) {
+			rv = i + 1
+			break
+		}
+	}
+	next := func(start int) int {
+		for i := start + 1; i < len(w); i++ {
+			if !strings.ContainsRune(russianVowels, w[i]) && strings.ContainsRune(russianVowels, w[i-1]) {
+				return i + 1
+			}
+		}
+		return len(w)
+	}
+	r1 := next(0)
+	return rv, next(r1)
+}
+
+// stemmer removes endings of the word which lie after the limit
+type stemmer struct {
+	w     []rune
+	limit int
+}
+
+// longest returns the longest ending of the list which lies after the limit
+func (s *stemmer) longest(endings []string) string {
+	var res string
+	for _, e := range endings {
+		n := len([]rune(e))
+		if n > len(s.w)-s.limit || len(e) <= len(res) {
+			continue
+		}
+		if string(s.w[len(s.w)-n:]) == e {
+			res = e
+		}
+	}
+	return res
+}
+
+func (s *stemmer) remove(endings []string) bool {
+	e := s.longest(endings)
+	if e == "" {
+		return false
+	}
+	s.w = s.w[:len(s.w)-len([]rune(e))]
+	return true
+}
+
+// removeGroups removes the longest ending of both groups, endings of the first group must follow 'а' or 'я'
+func (s *stemmer) removeGroups(first, second []string) bool {
+	e1, e2 := s.longest(first), s.longest(second)
+	if e2 != "" && len(e2) >= len(e1) {
+		s.w = s.w[:len(s.w)-len([]rune(e2))]
+		return true
+	}
+	if e1 == "" {
+		return false
+	}
+	n := len([]rune(e1))
+	if before := len(s.w) - n - 1; before >= s.limit && (s.w[before] == 'а' || s.w[before] == 'я') {
+		s.w = s.w[:len(s.w)-n]
+		return true
+	}
+	return false
+}
+
+// undouble replaces the final "нн" with "н"
+func (s *stemmer) undouble() bool {
+	n := len(s.w)
+	if n-2 >= s.limit && s.w[n-1] == 'н' && s.w[n-2] == 'н' {
+		s.w = s.w[:n-1]
+		return true
+	}
+	return false
+}
+
+// russian contains lowercase russian stop words, 'ё' is written as 'е'
+var russian = toSet(strings.Fields(`
+и в во не что он на я с со как а то все она так его но да ты к у же вы за бы по только ее мне было вот от меня
+еще нет о из ему теперь когда даже ну вдруг ли если уже или ни быть был него до вас нибудь опять уж вам ведь там
+потом себя ничего ей может они тут где есть надо ней для мы тебя их чем была сам чтоб без будто чего раз тоже себе
+под будет ж тогда кто этот того потому этого какой совсем ним здесь этом один почти мой тем чтобы нее сейчас были
+куда зачем всех никогда можно при наконец два об другой хоть после над больше тот через эти нас про всего них какая
+много разве три эту моя впрочем хорошо свою этой перед иногда лучше чуть том нельзя такой им более всегда конечно
+всю между это эта мои свои твой твоя наш наша ваш ваша который которая которые которых также
+`))
+
+func toSet(words []string) map[string]bool {
+	res := make(map[string]bool, len(words))
+	for _, w := range words {
+		res[w] = true
+	}
+	return res
+}
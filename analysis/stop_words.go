@@ -1,12 +1,6 @@
-package util
-
-import "strings"
-
-// EnglishStopWordChecker checks token is english stop word
-func EnglishStopWordChecker(s string) bool {
-	return english[strings.ToLower(s)]
-}
+package analysis
 
+// english contains lowercase english stop words
 var english = map[string]bool{
 	"a":            true,
 	"about":        true,
@@ -0,0 +1,50 @@
+package analysis
+
+import (
+	"io"
+	"strings"
+	"unicode"
+)
+
+// runTokenizer makes tokens of the longest runs of runes accepted by the function
+type runTokenizer func(r rune) bool
+
+func (t runTokenizer) Tokenize(r *Reader, fn func(Token)) error {
+	var sb strings.Builder
+	var start int
+	for {
+		c, offset, err := r.Next()
+		if err == nil && t(c) {
+			if sb.Len() == 0 {
+				start = offset
+			}
+			sb.WriteRune(c)
+			continue
+		}
+		if sb.Len() > 0 {
+			fn(Token{Term: sb.String(), Offset: start})
+			sb.Reset()
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+var tokenizers = map[string]Tokenizer{
+	// whitespace splits the text by spaces keeping punctuation in the tokens
+	"whitespace": runTokenizer(func(r rune) bool {
+		return !unicode.IsSpace(r)
+	}),
+	// letter makes tokens of letters only
+	"letter": runTokenizer(unicode.IsLetter),
+	// word makes tokens of letters and digits
+	"word": runTokenizer(func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	}),
+	// uax29 finds words, numbers, emails and urls and splits identifiers
+	"uax29": uax29{},
+}
@@ -0,0 +1,235 @@
+package analysis
+
+import (
+	"io"
+	"strings"
+	"unicode"
+)
+
+// uax29 splits the text into words by rules close to the unicode word boundaries (UAX #29):
+//
+//   - letters and digits joined by an apostrophe, a dot or a hyphen make one word, like don't, e.g. and e-mail;
+//   - digits joined by a dot, a comma or an apostrophe make one number, like 1,000.5;
+//   - letters followed by "++" or "#" keep them, like c++ and c#;
+//   - every ideograph is a word;
+//   - emails and urls are single tokens;
+//   - identifiers with underscores or in camel case are split into their words, like parse and http of parse_HTTP.
+//
+// Other runes separate words and are dropped
+type uax29 struct{}
+
+func (uax29) Tokenize(r *Reader, fn func(Token)) error {
+	var chunk []rune
+	var offsets []int
+	for {
+		c, offset, err := r.Next()
+		if err == nil && !unicode.IsSpace(c) {
+			chunk = append(chunk, c)
+			offsets = append(offsets, offset)
+			continue
+		}
+		if len(chunk) > 0 {
+			segment(chunk, offsets, fn)
+			chunk, offsets = chunk[:0], offsets[:0]
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// segment splits the runes between spaces into tokens, offsets are byte offsets of the runes in the source text
+func segment(w []rune, offsets []int, fn func(Token)) {
+	emit := func(from, to int, t TokenType) {
+		fn(Token{Term: string(w[from:to]), Offset: offsets[from], Type: t})
+	}
+	for i := 0; i < len(w); {
+		if !isWordStart(w[i]) {
+			i++
+			continue
+		}
+		if end := urlEnd(w, i); end > 0 {
+			emit(i, end, URL)
+			i = end
+			continue
+		}
+		if end := emailEnd(w, i); end > 0 {
+			emit(i, end, Email)
+			i = end
+			continue
+		}
+		end := wordEnd(w, i)
+		switch {
+		case isNumber(w[i:end]):
+			emit(i, end, Number)
+		case isIdent(w[i:end]):
+			for _, part := range identParts(w[i:end]) {
+				emit(i+part[0], i+part[1], Ident)
+			}
+		default:
+			emit(i, end, Word)
+		}
+		i = end
+	}
+}
+
+func isWordStart(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isWordRune(r rune) bool {
+	return isWordStart(r) || unicode.IsMark(r) || r == '_'
+}
+
+func isIdeograph(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana)
+}
+
+// wordEnd returns the end of the word starting at i
+func wordEnd(w []rune, i int) int {
+	if isIdeograph(w[i]) {
+		return i + 1
+	}
+	j := i + 1
+	for j < len(w) {
+		c := w[j]
+		switch {
+		case isIdeograph(c):
+			return j
+		case isWordRune(c):
+			j++
+		case j+1 < len(w) && joins(w[j-1], c, w[j+1]):
+			j += 2
+		default:
+			return plusEnd(w, j)
+		}
+	}
+	return j
+}
+
+// joins reports if the punctuation between two runes does not separate them
+func joins(prev, mid, next rune) bool {
+	if unicode.IsDigit(prev) && unicode.IsDigit(next) {
+		return strings.ContainsRune(".,'’", mid)
+	}
+	return isWordStart(prev) && isWordStart(next) && !isIdeograph(next) && strings.ContainsRune(".'’-", mid)
+}
+
+// plusEnd keeps "++" or "#" after the letters, like c++ and c#, if they end the word
+func plusEnd(w []rune, j int) int {
+	if !unicode.IsLetter(w[j-1]) {
+		return j
+	}
+	end := j
+	switch {
+	case w[j] == '#':
+		end = j + 1
+	case j+1 < len(w) && w[j] == '+' && w[j+1] == '+':
+		end = j + 2
+	default:
+		return j
+	}
+	if end < len(w) && isWordRune(w[end]) {
+		return j
+	}
+	return end
+}
+
+func isNumber(w []rune) bool {
+	for _, r := range w {
+		if !unicode.IsDigit(r) && !strings.ContainsRune(".,'’", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// isIdent reports if the word has underscores or changes the case inside, like parse_request or parseRequest
+func isIdent(w []rune) bool {
+	return strings.ContainsRune(string(w), '_') || len(identParts(w)) > 1
+}
+
+// identParts returns ranges of the identifier words, the identifier is split at underscores,
+// before an upper case letter following a lower case letter or a digit,
+// and before the last letter of upper case letters followed by a lower case one, like HTTP and Request
+func identParts(w []rune) [][2]int {
+	var parts [][2]int
+	start := -1
+	for k, c := range w {
+		if c == '_' {
+			if start >= 0 {
+				parts = append(parts, [2]int{start, k})
+			}
+			start = -1
+			continue
+		}
+		if start < 0 {
+			start = k
+			continue
+		}
+		prev := w[k-1]
+		if unicode.IsUpper(c) && (unicode.IsLower(prev) || unicode.IsDigit(prev) ||
+			unicode.IsUpper(prev) && k+1 < len(w) && unicode.IsLower(w[k+1])) {
+			parts = append(parts, [2]int{start, k})
+			start = k
+		}
+	}
+	if start >= 0 {
+		parts = append(parts, [2]int{start, len(w)})
+	}
+	return parts
+}
+
+// urlEnd returns the end of the url starting at i with a scheme, like https://, or with www., or -1.
+// Punctuation and unbalanced brackets at the end are not the part of the url
+func urlEnd(w []rune, i int) int {
+	k := i
+	for k < len(w) && (unicode.IsLetter(w[k]) || unicode.IsDigit(w[k]) || strings.ContainsRune("+.-", w[k])) {
+		k++
+	}
+	switch {
+	case k > i && k+3 < len(w) && string(w[k:k+3]) == "://":
+	case i+4 < len(w) && strings.EqualFold(string(w[i:i+4]), "www."):
+	default:
+		return -1
+	}
+	end := len(w)
+	for end > i {
+		last := w[end-1]
+		if strings.ContainsRune(".,;:!?'\"’”»>]}", last) ||
+			last == ')' && strings.Count(string(w[i:end]), "(") < strings.Count(string(w[i:end]), ")") {
+			end--
+			continue
+		}
+		break
+	}
+	return end
+}
+
+// emailEnd returns the end of the email starting at i or -1
+func emailEnd(w []rune, i int) int {
+	k := i
+	for k < len(w) && (isWordStart(w[k]) || strings.ContainsRune("._%+-", w[k])) {
+		k++
+	}
+	if k == i || k+1 >= len(w) || w[k] != '@' {
+		return -1
+	}
+	k++
+	start := k
+	for k < len(w) && (isWordStart(w[k]) || w[k] == '.' || w[k] == '-') {
+		k++
+	}
+	for k > start && (w[k-1] == '.' || w[k-1] == '-') {
+		k--
+	}
+	domain := string(w[start:k])
+	dot := strings.LastIndexByte(domain, '.')
+	if dot <= 0 || !unicode.IsLetter(w[k-1]) {
+		return -1
+	}
+	return k
+}
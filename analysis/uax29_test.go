@@ -0,0 +1,64 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUAX29(t *testing.T) {
+	tests := []struct {
+		text string
+		want []Token
+	}{
+		{text: "Hello, world!", want: []Token{{"Hello", 0, Word}, {"world", 7, Word}}},
+		{text: "foo,bar", want: []Token{{"foo", 0, Word}, {"bar", 4, Word}}},
+		{text: "don't e-mail e.g.", want: []Token{{"don't", 0, Word}, {"e-mail", 6, Word}, {"e.g", 13, Word}}},
+		{text: "2020 1,000.5 v1.2 (42)", want: []Token{
+			{"2020", 0, Number}, {"1,000.5", 5, Number}, {"v1.2", 13, Word}, {"42", 19, Number}}},
+		{text: "2019-2020", want: []Token{{"2019", 0, Number}, {"2020", 5, Number}}},
+		{text: "C++ and C# or c+", want: []Token{{"C++", 0, Word}, {"and", 4, Word}, {"C#", 8, Word}, {"or", 11, Word},
+			{"c", 14, Word}}},
+		{text: "mail John.Doe+x@mail.example.com.", want: []Token{{"mail", 0, Word}, {"John.Doe+x@mail.example.com", 5, Email}}},
+		{text: "user@localhost", want: []Token{{"user", 0, Word}, {"localhost", 5, Word}}},
+		{text: "see (https://example.com/a_(b)?q=1), www.golang.org.", want: []Token{
+			{"see", 0, Word}, {"https://example.com/a_(b)?q=1", 5, URL}, {"www.golang.org", 37, URL}}},
+		{text: "parseHTTPRequest snake_case __init__ utf8Decode", want: []Token{
+			{"parse", 0, Ident}, {"HTTP", 5, Ident}, {"Request", 9, Ident},
+			{"snake", 17, Ident}, {"case", 23, Ident},
+			{"init", 30, Ident},
+			{"utf8", 37, Ident}, {"Decode", 41, Ident}}},
+		{text: "мама_мыла Раму", want: []Token{{"мама", 0, Ident}, {"мыла", 9, Ident}, {"Раму", 18, Word}}},
+		{text: "東京タワー", want: []Token{{"東", 0, Word}, {"京", 3, Word}, {"タワー", 6, Word}}},
+		{text: "...---", want: nil},
+	}
+	a, err := Get("uax29")
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			require.Equal(t, tt.want, tokens(t, a, tt.text))
+		})
+	}
+}
+
+func TestUnicode(t *testing.T) {
+	a, err := Get("unicode")
+	require.NoError(t, err)
+	require.Equal(t, []string{"email", "john@example.com", "c++", "2020", "parse", "request"},
+		a.Terms("Email John@Example.com about C++ in 2020: parseRequest"))
+
+	long := "https://example.com/" + strings.Repeat("a", 100)
+	require.Equal(t, []Token{{long, 0, URL}}, tokens(t, a, long))
+}
+
+func TestTokenType(t *testing.T) {
+	for _, typ := range []TokenType{Word, Number, Email, URL, Ident} {
+		parsed, ok := ParseTokenType(typ.String())
+		require.True(t, ok)
+		require.Equal(t, typ, parsed)
+	}
+	_, ok := ParseTokenType("unknown")
+	require.False(t, ok)
+	require.Equal(t, "number:2020", TypedTerm(Number, "2020"))
+}
@@ -14,11 +14,14 @@ type Config struct {
 	TimeOut  string
 	DbListen string
 	Database string
+	Scorer   string
+	// APIToken protects document endpoints, they are disabled if the token is empty
+	APIToken string
 }
 
 func Load() *Config {
 	once.Do(func() {
-		var listen, logLevel, timeout, db, dbListen string
+		var listen, logLevel, timeout, db, dbListen, scorer string
 		if listen = os.Getenv("LISTEN"); listen == "" {
 			listen = "localhost:8080"
 		}
@@ -34,12 +37,17 @@ func Load() *Config {
 		if dbListen = os.Getenv("DB_INTERFACE"); dbListen == "" {
 			dbListen = "127.0.0.1:3301"
 		}
+		if scorer = os.Getenv("SCORER"); scorer == "" {
+			scorer = "proximity"
+		}
 		instance = &Config{
 			Listen:   listen,
 			LogLevel: logLevel,
 			TimeOut:  timeout,
 			DbListen: dbListen,
 			Database: db,
+			Scorer:   scorer,
+			APIToken: os.Getenv("API_TOKEN"),
 		}
 	})
 	return instance
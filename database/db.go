@@ -1,12 +1,18 @@
 package database
 
 import (
+	"bytes"
 	"context"
+	"io"
+	"io/ioutil"
 	"sync"
 	"time"
+	"unicode/utf8"
 
+	"github.com/polisgo2020/search-senyast4745/analysis"
 	"github.com/polisgo2020/search-senyast4745/config"
 	"github.com/polisgo2020/search-senyast4745/index"
+	"github.com/polisgo2020/search-senyast4745/manifest"
 	"github.com/rs/zerolog/log"
 	"github.com/xlab/closer"
 	"go.mongodb.org/mongo-driver/bson"
@@ -68,8 +74,29 @@ func (c *Connection) Close() {
 	log.Info().Msg("Database disconnect successfully")
 }
 
+// updateTimeout limits incremental update operations which touch many documents
+const updateTimeout = time.Minute
+
+// fuzzyTimeout limits the scan of the terms for a fuzzy word
+const fuzzyTimeout = time.Second
+
+// textTimeout limits reading of the text of a single document
+const textTimeout = time.Second
+
+// docsTimeout limits reading of the lengths of all documents
+const docsTimeout = time.Second
+
 type IndexRepository struct {
-	col *mongo.Collection
+	col         *mongo.Collection
+	docCol      *mongo.Collection
+	manifestCol *mongo.Collection
+	settingsCol *mongo.Collection
+	// textCol keeps the text of the documents added through the api
+	textCol *mongo.Collection
+	// analyzer made the terms of the stored index
+	analyzer *analysis.Analyzer
+	// withCorpus is set if the scorer needs statistics of the whole collection
+	withCorpus bool
 }
 
 type indexItem struct {
@@ -77,6 +104,43 @@ type indexItem struct {
 	FileStr []*index.FileStruct
 }
 
+type docItem struct {
+	File   string
+	Length int
+}
+
+type manifestItem struct {
+	File    string
+	ModTime time.Time
+	Size    int64
+	Hash    string
+	// Saved marks the entries written by the same SaveManifest
+	Saved int64
+}
+
+type textItem struct {
+	File string
+	Text []byte
+}
+
+type settingItem struct {
+	Key   string
+	Value string
+}
+
+// analyzerSetting is the key of the analyzer name in the settings collection
+const analyzerSetting = "analyzer"
+
+// corpusSetting is the key of the corpus totals in the settings collection,
+// they are changed with the documents, so searches do not count them
+const corpusSetting = "corpus"
+
+type corpusItem struct {
+	Key       string
+	Documents int
+	Tokens    int
+}
+
 func transformIndex(i *index.Index) []indexItem {
 	dto := make([]indexItem, 0, len(i.Data))
 	log.Debug().Interface("index", i).Msg("start index transfer")
@@ -101,9 +165,164 @@ func NewIndexRepository(ctx context.Context, c *config.Config) (*IndexRepository
 		}, Options: options.Index().SetUnique(true),
 	}
 
-	_, err = col.Indexes().CreateOne(ctx, mod)
+	if _, err = col.Indexes().CreateOne(ctx, mod); err != nil {
+		return nil, err
+	}
+
+	docCol := con.client.Database(database).Collection("docCol")
+	mod = mongo.IndexModel{
+		Keys: bson.M{
+			"file": 1,
+		}, Options: options.Index().SetUnique(true),
+	}
+	if _, err = docCol.Indexes().CreateOne(ctx, mod); err != nil {
+		return nil, err
+	}
+
+	manifestCol := con.client.Database(database).Collection("manifestCol")
+	if _, err = manifestCol.Indexes().CreateOne(ctx, mod); err != nil {
+		return nil, err
+	}
+
+	textCol := con.client.Database(database).Collection("textCol")
+	if _, err = textCol.Indexes().CreateOne(ctx, mod); err != nil {
+		return nil, err
+	}
+
+	rep := &IndexRepository{col: col, docCol: docCol, manifestCol: manifestCol, textCol: textCol,
+		settingsCol: con.client.Database(database).Collection("settingsCol")}
+	if scorer, err := index.NewScorer(c.Scorer); err == nil {
+		rep.withCorpus = index.UsesCorpus(scorer)
+	}
+	if err := rep.loadAnalyzer(ctx); err != nil {
+		return nil, err
+	}
+	return rep, rep.ensureCorpus(ctx)
+}
+
+// ensureCorpus counts the corpus totals of indexes stored before they were kept
+func (rep *IndexRepository) ensureCorpus(ctx context.Context) error {
+	err := rep.settingsCol.FindOne(ctx, bson.M{"key": corpusSetting}).Err()
+	if err != mongo.ErrNoDocuments {
+		return err
+	}
+	c, err := rep.countCorpus(ctx)
+	if err != nil {
+		return err
+	}
+	return rep.addCorpus(ctx, c.Documents, c.Tokens)
+}
+
+// RecountCorpus sets the corpus totals counted over the stored documents,
+// it repairs the totals of the writes interrupted before the totals were changed
+func (rep *IndexRepository) RecountCorpus(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, updateTimeout)
+	defer cancel()
+	c, err := rep.countCorpus(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = rep.settingsCol.UpdateOne(ctx, bson.M{"key": corpusSetting},
+		bson.M{"$set": bson.M{"documents": c.Documents, "tokens": c.Tokens}}, options.Update().SetUpsert(true))
+	return err
+}
+
+// countCorpus counts the stored documents and their total length
+func (rep *IndexRepository) countCorpus(ctx context.Context) (corpusItem, error) {
+	cursor, err := rep.docCol.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{
+			"_id":       nil,
+			"documents": bson.M{"$sum": 1},
+			"tokens":    bson.M{"$sum": "$length"},
+		}}},
+	})
+	if err != nil {
+		return corpusItem{}, err
+	}
+	var c corpusItem
+	if cursor.Next(ctx) {
+		if err := cursor.Decode(&c); err != nil {
+			return corpusItem{}, err
+		}
+	}
+	return c, cursor.Err()
+}
+
+// addCorpus changes the corpus totals by the numbers of documents and tokens
+func (rep *IndexRepository) addCorpus(ctx context.Context, documents, tokens int) error {
+	_, err := rep.settingsCol.UpdateOne(ctx, bson.M{"key": corpusSetting},
+		bson.M{"$inc": bson.M{"documents": documents, "tokens": tokens}}, options.Update().SetUpsert(true))
+	return err
+}
+
+// replaceDoc stores the length of the document and changes the corpus totals by the difference
+// with the replaced document. The replaced document is returned by the write itself,
+// so concurrent writes of the same document are counted once
+func (rep *IndexRepository) replaceDoc(ctx context.Context, file string, length int) error {
+	var old docItem
+	err := rep.docCol.FindOneAndReplace(ctx, bson.M{"file": file}, docItem{File: file, Length: length},
+		options.FindOneAndReplace().SetUpsert(true).SetReturnDocument(options.Before)).Decode(&old)
+	documents := 0
+	switch {
+	case err == mongo.ErrNoDocuments:
+		documents = 1
+	case err != nil:
+		return err
+	}
+	return rep.addCorpus(ctx, documents, length-old.Length)
+}
+
+// deleteDoc deletes the document and subtracts it from the corpus totals if it was stored
+func (rep *IndexRepository) deleteDoc(ctx context.Context, file string) error {
+	var old docItem
+	err := rep.docCol.FindOneAndDelete(ctx, bson.M{"file": file}).Decode(&old)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return rep.addCorpus(ctx, -1, -old.Length)
+}
 
-	return &IndexRepository{col: col}, err
+// docsCorpus returns the number and the total length of the documents
+func docsCorpus(docs map[string]int) (int, int) {
+	var tokens int
+	for _, l := range docs {
+		tokens += l
+	}
+	return len(docs), tokens
+}
+
+// loadAnalyzer reads the analyzer of the stored index, indexes saved without it use the default analyzer
+func (rep *IndexRepository) loadAnalyzer(ctx context.Context) error {
+	var item settingItem
+	err := rep.settingsCol.FindOne(ctx, bson.M{"key": analyzerSetting}).Decode(&item)
+	if err == mongo.ErrNoDocuments {
+		rep.analyzer = analysis.Default()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	rep.analyzer, err = analysis.Get(item.Value)
+	return err
+}
+
+// SetAnalyzer saves the analyzer of the stored index, it has to be set before the documents are added
+func (rep *IndexRepository) SetAnalyzer(ctx context.Context, a *analysis.Analyzer) error {
+	_, err := rep.settingsCol.ReplaceOne(ctx, bson.M{"key": analyzerSetting},
+		settingItem{Key: analyzerSetting, Value: a.Name}, options.Replace().SetUpsert(true))
+	if err != nil {
+		return err
+	}
+	rep.analyzer = a
+	return nil
+}
+
+// Analyzer returns the analyzer which made the terms of the stored index
+func (rep *IndexRepository) Analyzer() *analysis.Analyzer {
+	return rep.analyzer
 }
 
 func (rep *IndexRepository) SaveIndex(ctx context.Context, i *index.Index) error {
@@ -114,8 +333,92 @@ func (rep *IndexRepository) SaveIndex(ctx context.Context, i *index.Index) error
 	log.Debug().Interface("transfer", transfer).Msg("data")
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
 	defer cancel()
-	_, err := rep.col.InsertMany(ctx, transfer)
-	return err
+	if err := rep.SetAnalyzer(ctx, i.Analyzer()); err != nil {
+		return err
+	}
+	if _, err := rep.col.InsertMany(ctx, transfer); err != nil {
+		return err
+	}
+
+	if len(i.Docs) == 0 {
+		return nil
+	}
+	docs := make([]interface{}, 0, len(i.Docs))
+	for file, l := range i.Docs {
+		docs = append(docs, docItem{File: file, Length: l})
+	}
+	if _, err := rep.docCol.InsertMany(ctx, docs); err != nil {
+		return err
+	}
+	documents, tokens := docsCorpus(i.Docs)
+	return rep.addCorpus(ctx, documents, tokens)
+}
+
+// saveBatch limits number of words and documents inserted at once by SaveBuilder
+const saveBatch = 1000
+
+// SaveBuilder inserts the index built by the builder, words are merged and inserted in batches,
+// so the whole index is never kept in memory. Every batch has its own timeout,
+// so the time of the whole build is limited only by the context
+func (rep *IndexRepository) SaveBuilder(ctx context.Context, b *index.Builder) error {
+	if err := rep.withTimeout(ctx, func(ctx context.Context) error {
+		return rep.SetAnalyzer(ctx, b.Analyzer())
+	}); err != nil {
+		return err
+	}
+
+	batch := make([]interface{}, 0, saveBatch)
+	flush := func(col *mongo.Collection) error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := rep.withTimeout(ctx, func(ctx context.Context) error {
+			_, err := col.InsertMany(ctx, batch)
+			return err
+		})
+		batch = batch[:0]
+		return err
+	}
+	err := b.Merge(func(word string, postings []*index.FileStruct) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		batch = append(batch, indexItem{word, postings})
+		if len(batch) < saveBatch {
+			return nil
+		}
+		return flush(rep.col)
+	})
+	if err != nil {
+		return err
+	}
+	if err := flush(rep.col); err != nil {
+		return err
+	}
+
+	docs := b.Docs()
+	for file, l := range docs {
+		batch = append(batch, docItem{File: file, Length: l})
+		if len(batch) == saveBatch {
+			if err := flush(rep.docCol); err != nil {
+				return err
+			}
+		}
+	}
+	if err := flush(rep.docCol); err != nil {
+		return err
+	}
+	documents, tokens := docsCorpus(docs)
+	return rep.withTimeout(ctx, func(ctx context.Context) error {
+		return rep.addCorpus(ctx, documents, tokens)
+	})
+}
+
+// withTimeout calls the function with the context limited by updateTimeout
+func (rep *IndexRepository) withTimeout(ctx context.Context, fn func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, updateTimeout)
+	defer cancel()
+	return fn(ctx)
 }
 
 func (rep *IndexRepository) FindAllByWords(ctx context.Context, wordArr []string) (*index.Index, error) {
@@ -128,6 +431,8 @@ func (rep *IndexRepository) FindAllByWords(ctx context.Context, wordArr []string
 		return nil, err
 	}
 	i := index.NewIndex()
+	i.SetAnalyzer(rep.analyzer)
+	files := make(map[string]bool)
 	for cursor.Next(ctx) {
 		var tmp indexItem
 		err := cursor.Decode(&tmp)
@@ -136,15 +441,307 @@ func (rep *IndexRepository) FindAllByWords(ctx context.Context, wordArr []string
 		}
 		log.Debug().Interface("parsed cursor", tmp).Msg("cursor parsed")
 		i.Data[tmp.Word] = tmp.FileStr
+		for _, f := range tmp.FileStr {
+			files[f.File] = true
+		}
+	}
+	if err := rep.findDocs(ctx, i, files); err != nil {
+		return nil, err
 	}
 	log.Info().Interface("index", i).Strs("words", wordArr).Msg("index get from db")
 	return i, nil
 }
 
+// findDocs loads lengths of the given files into the index and the corpus totals if the scorer needs them
+func (rep *IndexRepository) findDocs(ctx context.Context, i *index.Index, files map[string]bool) error {
+	if len(files) == 0 {
+		return nil
+	}
+	fileArr := make([]string, 0, len(files))
+	for f := range files {
+		fileArr = append(fileArr, f)
+	}
+	cursor, err := rep.docCol.Find(ctx, bson.M{"file": bson.M{"$in": fileArr}})
+	if err != nil {
+		return err
+	}
+	for cursor.Next(ctx) {
+		var tmp docItem
+		if err := cursor.Decode(&tmp); err != nil {
+			return err
+		}
+		i.Docs[tmp.File] = tmp.Length
+	}
+
+	if !rep.withCorpus {
+		return nil
+	}
+	var c corpusItem
+	err = rep.settingsCol.FindOne(ctx, bson.M{"key": corpusSetting}).Decode(&c)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	i.SetCorpus(index.Corpus{Documents: c.Documents, Tokens: c.Tokens})
+	return nil
+}
+
 func (rep *IndexRepository) DropIndex(ctx context.Context) error {
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
 	defer cancel()
-	return rep.col.Drop(ctx)
+	if err := rep.col.Drop(ctx); err != nil {
+		return err
+	}
+	if err := rep.docCol.Drop(ctx); err != nil {
+		return err
+	}
+	if err := rep.settingsCol.Drop(ctx); err != nil {
+		return err
+	}
+	if err := rep.textCol.Drop(ctx); err != nil {
+		return err
+	}
+	return rep.manifestCol.Drop(ctx)
+}
+
+// RemoveFiles pulls postings of the files from all words and deletes words without postings,
+// texts of the files are deleted too
+func (rep *IndexRepository) RemoveFiles(ctx context.Context, files []string) error {
+	if len(files) == 0 {
+		return nil
+	}
+	log.Debug().Strs("files", files).Msg("start removing files")
+	ctx, cancel := context.WithTimeout(ctx, updateTimeout)
+	defer cancel()
+
+	filter := bson.M{"filestr.file": bson.M{"$in": files}}
+	update := bson.M{"$pull": bson.M{"filestr": bson.M{"file": bson.M{"$in": files}}}}
+	if _, err := rep.col.UpdateMany(ctx, filter, update); err != nil {
+		return err
+	}
+	if _, err := rep.col.DeleteMany(ctx, bson.M{"filestr": bson.M{"$size": 0}}); err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err := rep.deleteDoc(ctx, file); err != nil {
+			return err
+		}
+	}
+	_, err := rep.textCol.DeleteMany(ctx, bson.M{"file": bson.M{"$in": files}})
+	return err
+}
+
+// MergeIndex appends postings of the index to the stored words, missing words are created
+func (rep *IndexRepository) MergeIndex(ctx context.Context, i *index.Index) error {
+	if len(i.Data) == 0 && len(i.Docs) == 0 {
+		return nil
+	}
+	log.Debug().Int("words", len(i.Data)).Int("files", len(i.Docs)).Msg("start merging index")
+	ctx, cancel := context.WithTimeout(ctx, updateTimeout)
+	defer cancel()
+
+	models := make([]mongo.WriteModel, 0, len(i.Data))
+	for word, postings := range i.Data {
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"word": word}).
+			SetUpdate(bson.M{"$push": bson.M{"filestr": bson.M{"$each": postings}}}).
+			SetUpsert(true))
+	}
+	if len(models) > 0 {
+		if _, err := rep.col.BulkWrite(ctx, models); err != nil {
+			return err
+		}
+	}
+
+	for file, l := range i.Docs {
+		if err := rep.replaceDoc(ctx, file, l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddDocument indexes the document replacing its previous postings and keeps its text.
+// Postings of every word are replaced at once before the old words are cleaned up,
+// so the document is never lost if the update is interrupted
+func (rep *IndexRepository) AddDocument(ctx context.Context, file string, reader io.Reader) error {
+	text, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	i := index.NewIndex()
+	i.SetAnalyzer(rep.analyzer)
+	if err := i.AddDocument(file, bytes.NewReader(text)); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, updateTimeout)
+	defer cancel()
+
+	words := make([]string, 0, len(i.Data))
+	models := make([]mongo.WriteModel, 0, len(i.Data))
+	for word, postings := range i.Data {
+		words = append(words, word)
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"word": word}).
+			SetUpdate(replacePostings(file, postings)).
+			SetUpsert(true))
+	}
+	if len(models) > 0 {
+		if _, err := rep.col.BulkWrite(ctx, models); err != nil {
+			return err
+		}
+	}
+	if err := rep.replaceDoc(ctx, file, i.Docs[file]); err != nil {
+		return err
+	}
+	if _, err := rep.textCol.ReplaceOne(ctx, bson.M{"file": file}, textItem{File: file, Text: text},
+		options.Replace().SetUpsert(true)); err != nil {
+		return err
+	}
+
+	// words which are not in the new text any more
+	filter := bson.M{"word": bson.M{"$nin": words}, "filestr.file": file}
+	update := bson.M{"$pull": bson.M{"filestr": bson.M{"file": file}}}
+	if _, err := rep.col.UpdateMany(ctx, filter, update); err != nil {
+		return err
+	}
+	_, err = rep.col.DeleteMany(ctx, bson.M{"filestr": bson.M{"$size": 0}})
+	return err
+}
+
+// Text returns the text of the document added through the api
+func (rep *IndexRepository) Text(file string) ([]byte, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), textTimeout)
+	defer cancel()
+	var item textItem
+	err := rep.textCol.FindOne(ctx, bson.M{"file": file}).Decode(&item)
+	if err == mongo.ErrNoDocuments {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return item.Text, true, nil
+}
+
+// replacePostings is the update pipeline replacing postings of the file in a word with the given ones
+func replacePostings(file string, postings []*index.FileStruct) mongo.Pipeline {
+	kept := bson.M{"$filter": bson.M{
+		"input": bson.M{"$ifNull": bson.A{"$filestr", bson.A{}}},
+		"cond":  bson.M{"$ne": bson.A{"$$this.file", file}},
+	}}
+	return mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"filestr": bson.M{"$concatArrays": bson.A{kept, bson.M{"$literal": postings}}},
+	}}}}
+}
+
+// RemoveDocument deletes all postings of the document
+func (rep *IndexRepository) RemoveDocument(ctx context.Context, file string) error {
+	return rep.RemoveFiles(ctx, []string{file})
+}
+
+// LoadManifest reads states of the indexed files
+func (rep *IndexRepository) LoadManifest(ctx context.Context) (*manifest.Manifest, error) {
+	ctx, cancel := context.WithTimeout(ctx, updateTimeout)
+	defer cancel()
+	cursor, err := rep.manifestCol.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	m := manifest.New()
+	for cursor.Next(ctx) {
+		var tmp manifestItem
+		if err := cursor.Decode(&tmp); err != nil {
+			return nil, err
+		}
+		m.Files[tmp.File] = &manifest.Entry{ModTime: tmp.ModTime, Size: tmp.Size, Hash: tmp.Hash}
+	}
+	return m, cursor.Err()
+}
+
+// SaveManifest replaces states of the indexed files. The entries are upserted before the stale ones are deleted,
+// so an interrupted save never leaves the manifest empty
+func (rep *IndexRepository) SaveManifest(ctx context.Context, m *manifest.Manifest) error {
+	ctx, cancel := context.WithTimeout(ctx, updateTimeout)
+	defer cancel()
+	saved := time.Now().UnixNano()
+	models := make([]mongo.WriteModel, 0, saveBatch)
+	flush := func() error {
+		if len(models) == 0 {
+			return nil
+		}
+		_, err := rep.manifestCol.BulkWrite(ctx, models)
+		models = models[:0]
+		return err
+	}
+	for file, e := range m.Files {
+		models = append(models, mongo.NewReplaceOneModel().
+			SetFilter(bson.M{"file": file}).
+			SetReplacement(manifestItem{File: file, ModTime: e.ModTime, Size: e.Size, Hash: e.Hash, Saved: saved}).
+			SetUpsert(true))
+		if len(models) == saveBatch {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	_, err := rep.manifestCol.DeleteMany(ctx, bson.M{"saved": bson.M{"$ne": saved}})
+	return err
+}
+
+// FuzzyTerms returns stored terms within the edit distance of the word,
+// only terms of the suitable length are read from the database
+func (rep *IndexRepository) FuzzyTerms(word string, distance int) ([]index.FuzzyTerm, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), fuzzyTimeout)
+	defer cancel()
+	n := utf8.RuneCountInString(word)
+	filter := bson.M{"$expr": bson.M{"$and": bson.A{
+		bson.M{"$gte": bson.A{bson.M{"$strLenCP": "$word"}, n - distance}},
+		bson.M{"$lte": bson.A{bson.M{"$strLenCP": "$word"}, n + distance}},
+	}}}
+	cursor, err := rep.col.Find(ctx, filter, options.Find().SetProjection(bson.M{"word": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var decodeErr error
+	res := index.FuzzyScan(word, distance, func(fn func(term string)) {
+		for cursor.Next(ctx) {
+			var tmp indexItem
+			if decodeErr = cursor.Decode(&tmp); decodeErr != nil {
+				return
+			}
+			fn(tmp.Word)
+		}
+	})
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+	return res, cursor.Err()
+}
+
+// DocLengths returns lengths of all stored documents
+func (rep *IndexRepository) DocLengths() (map[string]int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), docsTimeout)
+	defer cancel()
+	cursor, err := rep.docCol.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	docs := make(map[string]int)
+	for cursor.Next(ctx) {
+		var tmp docItem
+		if err := cursor.Decode(&tmp); err != nil {
+			return nil, err
+		}
+		docs[tmp.File] = tmp.Length
+	}
+	return docs, cursor.Err()
 }
 
 func (rep *IndexRepository) GetIndex(str ...string) (*index.Index, error) {
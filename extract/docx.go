@@ -0,0 +1,129 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+type docxExtractor struct{}
+
+// ErrNoDocument is returned for zip files without the main part of the word document
+var ErrNoDocument = errors.New("docx: word/document.xml not found")
+
+// Extract reads text of the paragraphs from word/document.xml and the title from docProps/core.xml.
+// Paragraphs with the heading and title styles are the headings
+func (docxExtractor) Extract(reader io.Reader) (*Document, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	z, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &Document{}
+	var found bool
+	for _, f := range z.File {
+		switch f.Name {
+		case "word/document.xml":
+			found = true
+			if err := readZipFile(f, func(r io.Reader) error { return docxBody(r, doc) }); err != nil {
+				return nil, err
+			}
+		case "docProps/core.xml":
+			if err := readZipFile(f, func(r io.Reader) error { return docxTitle(r, doc) }); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if !found {
+		return nil, ErrNoDocument
+	}
+	if doc.Title == "" && len(doc.Headings) > 0 {
+		doc.Title = doc.Headings[0]
+	}
+	return doc, nil
+}
+
+func readZipFile(f *zip.File, fn func(io.Reader) error) error {
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return fn(r)
+}
+
+func docxBody(reader io.Reader, doc *Document) error {
+	var text textBuilder
+	var para strings.Builder
+	var inText, heading bool
+
+	d := xml.NewDecoder(reader)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "p":
+				para.Reset()
+				heading = false
+			case "t":
+				inText = true
+			case "tab", "br", "cr":
+				para.WriteByte(' ')
+			case "pStyle":
+				for _, a := range t.Attr {
+					v := strings.ToLower(a.Value)
+					if a.Name.Local == "val" && (strings.HasPrefix(v, "heading") || v == "title") {
+						heading = true
+					}
+				}
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				p := squash(para.String())
+				if p == "" {
+					continue
+				}
+				text.text(p)
+				text.block()
+				if heading {
+					doc.Headings = append(doc.Headings, p)
+				}
+			}
+		case xml.CharData:
+			if inText {
+				para.Write(t)
+			}
+		}
+	}
+	doc.Text = text.String()
+	return nil
+}
+
+func docxTitle(reader io.Reader, doc *Document) error {
+	var props struct {
+		Title string `xml:"title"`
+	}
+	if err := xml.NewDecoder(reader).Decode(&props); err != nil {
+		return err
+	}
+	doc.Title = squash(props.Title)
+	return nil
+}
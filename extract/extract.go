@@ -0,0 +1,183 @@
+// Package extract turns documents into plain text before they are indexed.
+//
+// The extractor is chosen by the file extension or by the MIME type.
+// Besides the text, extractors report the title and the headings of the document,
+// those which are not in the text, like the title of PDF metadata, are indexed before the text.
+// Files of unknown types are read as plain text.
+package extract
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"mime"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// Document is the plain text of the document with its metadata
+type Document struct {
+	Text     string
+	Title    string
+	Headings []string
+}
+
+// IndexedText returns the text with the title and the headings which are not found in it written before it,
+// a line for each
+func (d *Document) IndexedText() string {
+	var sb strings.Builder
+	seen := make(map[string]bool)
+	for _, s := range append([]string{d.Title}, d.Headings...) {
+		if s == "" || seen[s] || strings.Contains(d.Text, s) {
+			continue
+		}
+		seen[s] = true
+		sb.WriteString(s)
+		sb.WriteByte('\n')
+	}
+	if sb.Len() == 0 {
+		return d.Text
+	}
+	sb.WriteString(d.Text)
+	return sb.String()
+}
+
+// Extractor converts the document of some type to plain text
+type Extractor interface {
+	Extract(reader io.Reader) (*Document, error)
+}
+
+// Built-in extractors
+var (
+	Text     Extractor = textExtractor{}
+	HTML     Extractor = htmlExtractor{}
+	Markdown Extractor = markdownExtractor{}
+	DOCX     Extractor = docxExtractor{}
+	PDF      Extractor = pdfExtractor{}
+)
+
+var byExtension = map[string]Extractor{
+	".html":     HTML,
+	".htm":      HTML,
+	".xhtml":    HTML,
+	".md":       Markdown,
+	".markdown": Markdown,
+	".docx":     DOCX,
+	".pdf":      PDF,
+}
+
+var byType = map[string]Extractor{
+	"text/plain":            Text,
+	"text/html":             HTML,
+	"application/xhtml+xml": HTML,
+	"text/markdown":         Markdown,
+	"text/x-markdown":       Markdown,
+	"application/pdf":       PDF,
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document": DOCX,
+}
+
+// ForFile returns the extractor for the file name, plain text is used for unknown extensions
+func ForFile(name string) Extractor {
+	if e, ok := byExtension[strings.ToLower(filepath.Ext(name))]; ok {
+		return e
+	}
+	return Text
+}
+
+// ForType returns the extractor for the MIME type, parameters of the type are ignored
+func ForType(mimeType string) (Extractor, bool) {
+	t, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return nil, false
+	}
+	e, ok := byType[t]
+	return e, ok
+}
+
+// Lookup returns the extractor for the MIME type if it is known and for the file name otherwise
+func Lookup(name, mimeType string) Extractor {
+	if e, ok := ForType(mimeType); ok {
+		return e
+	}
+	return ForFile(name)
+}
+
+// TextReader returns the plain text of the document, plain text itself is not buffered
+func TextReader(e Extractor, reader io.Reader) (io.Reader, error) {
+	if e == Text {
+		return reader, nil
+	}
+	doc, err := e.Extract(reader)
+	if err != nil {
+		return nil, err
+	}
+	return strings.NewReader(doc.IndexedText()), nil
+}
+
+// ReadText reads the file and returns its plain text, the offsets of the indexed words point into it
+func ReadText(file string) ([]byte, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	e := ForFile(file)
+	if e == Text {
+		return data, nil
+	}
+	doc, err := e.Extract(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(doc.IndexedText()), nil
+}
+
+type textExtractor struct{}
+
+func (textExtractor) Extract(reader io.Reader) (*Document, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	return &Document{Text: string(data)}, nil
+}
+
+// textBuilder joins pieces of the text, collapsing spaces and keeping line breaks between blocks
+type textBuilder struct {
+	sb strings.Builder
+	// space and line are pending separators written before the next text
+	space, line bool
+}
+
+func (t *textBuilder) text(s string) {
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			t.space = true
+			continue
+		}
+		if t.sb.Len() > 0 {
+			switch {
+			case t.line:
+				t.sb.WriteByte('\n')
+			case t.space:
+				t.sb.WriteByte(' ')
+			}
+		}
+		t.space, t.line = false, false
+		t.sb.WriteRune(r)
+	}
+}
+
+// block ends the line, so the words of different blocks are not joined
+func (t *textBuilder) block() {
+	t.line = true
+}
+
+func (t *textBuilder) String() string {
+	return t.sb.String()
+}
+
+// squash collapses the whitespace of the text
+func squash(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
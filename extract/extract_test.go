@@ -0,0 +1,155 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookup(t *testing.T) {
+	require.Equal(t, HTML, ForFile("a/page.HTML"))
+	require.Equal(t, Markdown, ForFile("README.md"))
+	require.Equal(t, DOCX, ForFile("report.docx"))
+	require.Equal(t, PDF, ForFile("paper.pdf"))
+	require.Equal(t, Text, ForFile("notes.txt"))
+	require.Equal(t, Text, ForFile("Makefile"))
+
+	e, ok := ForType("text/html; charset=utf-8")
+	require.True(t, ok)
+	require.Equal(t, HTML, e)
+	_, ok = ForType("application/octet-stream")
+	require.False(t, ok)
+
+	require.Equal(t, Markdown, Lookup("doc.txt", "text/markdown"))
+	require.Equal(t, PDF, Lookup("doc.pdf", ""))
+}
+
+func TestHTML(t *testing.T) {
+	doc, err := HTML.Extract(strings.NewReader(`<!DOCTYPE html>
+<html><head><title> The  Page </title><style>body { color: red }</style>
+<script>var hidden = "script";</script></head>
+<body><h1>Main <em>heading</em></h1><p>First&nbsp;paragraph with <a href="http://x">a link</a>.</p>
+<div>Second<br>line</div><img src="a.png" alt="picture"><h2>Sub</h2><noscript>no</noscript>
+<p class="note" data-secret="attribute">Last</p></body></html>`))
+	require.NoError(t, err)
+	require.Equal(t, "The Page", doc.Title)
+	require.Equal(t, []string{"Main heading", "Sub"}, doc.Headings)
+	require.Equal(t, "The Page\nMain heading\nFirst paragraph with a link.\nSecond\nline\npicture\nSub\nLast", doc.Text)
+}
+
+func TestMarkdown(t *testing.T) {
+	doc, err := Markdown.Extract(strings.NewReader(`Intro line
+=====
+
+Some *emphasis*, __strong__ and ` + "`code`" + ` with snake_case_name.
+A [link](http://example.com) and ![image alt](a.png) and <http://auto.link>.
+
+## Second ##
+
+> quoted text
+- item one
+1. item two
+
+---
+[ref]: http://example.com
+
+` + "```go\nfunc main() {}\n```" + `
+Escaped \*star\*
+`))
+	require.NoError(t, err)
+	require.Equal(t, "Intro line", doc.Title)
+	require.Equal(t, []string{"Intro line", "Second"}, doc.Headings)
+	require.Equal(t, strings.Join([]string{
+		"Intro line",
+		"Some emphasis, strong and code with snake_case_name.",
+		"A link and image alt and http://auto.link.",
+		"Second",
+		"quoted text",
+		"item one",
+		"item two",
+		"func main() {}",
+		"Escaped *star*",
+	}, "\n"), doc.Text)
+}
+
+func docxFile(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := z.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, z.Close())
+	return buf.Bytes()
+}
+
+func TestDOCX(t *testing.T) {
+	data := docxFile(t, map[string]string{
+		"word/document.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>
+<w:p><w:pPr><w:pStyle w:val="Heading1"/></w:pPr><w:r><w:t>Chapter</w:t></w:r><w:r><w:t xml:space="preserve"> one</w:t></w:r></w:p>
+<w:p><w:r><w:t>Hel</w:t></w:r><w:r><w:t>lo</w:t></w:r><w:r><w:tab/><w:t>world &amp; more</w:t></w:r></w:p>
+<w:p></w:p>
+<w:p><w:r><w:t>Last</w:t><w:br/><w:t>line</w:t></w:r></w:p>
+</w:body></w:document>`,
+		"docProps/core.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties"
+ xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>Report</dc:title></cp:coreProperties>`,
+	})
+	doc, err := DOCX.Extract(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, "Report", doc.Title)
+	require.Equal(t, []string{"Chapter one"}, doc.Headings)
+	require.Equal(t, "Chapter one\nHello world & more\nLast line", doc.Text)
+	require.Equal(t, "Report\nChapter one\nHello world & more\nLast line", doc.IndexedText(),
+		"the title of the properties is indexed")
+
+	r, err := TextReader(DOCX, bytes.NewReader(data))
+	require.NoError(t, err)
+	text, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, doc.IndexedText(), string(text))
+
+	_, err = DOCX.Extract(bytes.NewReader(docxFile(t, map[string]string{"a.txt": "a"})))
+	require.Equal(t, ErrNoDocument, err)
+	_, err = DOCX.Extract(strings.NewReader("not a zip"))
+	require.Error(t, err)
+}
+
+func TestDocument_IndexedText(t *testing.T) {
+	doc := &Document{Text: "The Page\nIntro text", Title: "The Page", Headings: []string{"Outline", "Intro", "Outline"}}
+	require.Equal(t, "Outline\nThe Page\nIntro text", doc.IndexedText())
+	doc = &Document{Text: "text"}
+	require.Equal(t, "text", doc.IndexedText())
+}
+
+func TestReadText(t *testing.T) {
+	dir, err := ioutil.TempDir("", "extract")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	page := filepath.Join(dir, "page.html")
+	require.NoError(t, ioutil.WriteFile(page, []byte("<p>Hello <b>world</b></p>"), 0644))
+	text, err := ReadText(page)
+	require.NoError(t, err)
+	require.Equal(t, "Hello world", string(text))
+
+	plain := filepath.Join(dir, "plain.txt")
+	require.NoError(t, ioutil.WriteFile(plain, []byte("<p>as is</p>"), 0644))
+	text, err = ReadText(plain)
+	require.NoError(t, err)
+	require.Equal(t, "<p>as is</p>", string(text))
+
+	r := strings.NewReader("plain")
+	res, err := TextReader(Text, r)
+	require.NoError(t, err)
+	require.Equal(t, r, res, "plain text is not buffered")
+}
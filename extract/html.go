@@ -0,0 +1,111 @@
+package extract
+
+import (
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+type htmlExtractor struct{}
+
+// skipped elements have no visible text
+var skipped = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Head:     true,
+	atom.Svg:      true,
+}
+
+// inline elements do not break the line
+var inline = map[atom.Atom]bool{
+	atom.A: true, atom.Abbr: true, atom.B: true, atom.Bdi: true, atom.Bdo: true, atom.Cite: true,
+	atom.Code: true, atom.Data: true, atom.Dfn: true, atom.Em: true, atom.I: true, atom.Kbd: true,
+	atom.Mark: true, atom.Q: true, atom.S: true, atom.Samp: true, atom.Small: true, atom.Span: true,
+	atom.Strong: true, atom.Sub: true, atom.Sup: true, atom.Time: true, atom.U: true, atom.Var: true,
+	atom.Wbr: true, atom.Font: true, atom.Label: true,
+}
+
+var headings = map[atom.Atom]bool{
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+}
+
+// Extract returns the visible text of the page, the title is written on the first line,
+// so it is indexed as well
+func (htmlExtractor) Extract(reader io.Reader) (*Document, error) {
+	doc := &Document{}
+	var text textBuilder
+	var heading strings.Builder
+	var title strings.Builder
+	// skip counts the open skipped elements, inTitle and inHeading are set inside the elements
+	var skip int
+	var inTitle, inHeading bool
+
+	z := html.NewTokenizer(reader)
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if err := z.Err(); err != io.EOF {
+				return nil, err
+			}
+			doc.Title = squash(title.String())
+			if doc.Title != "" {
+				doc.Text = doc.Title + "\n" + text.String()
+			} else {
+				doc.Text = text.String()
+			}
+			return doc, nil
+		case html.TextToken:
+			switch {
+			case inTitle:
+				title.Write(z.Text())
+			case skip > 0:
+			default:
+				t := string(z.Text())
+				text.text(t)
+				if inHeading {
+					heading.WriteString(t)
+				}
+			}
+		case html.StartTagToken, html.EndTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			a := atom.Lookup(name)
+			start := tt != html.EndTagToken
+			switch {
+			case a == atom.Title:
+				inTitle = tt == html.StartTagToken
+			case skipped[a]:
+				if tt == html.StartTagToken {
+					skip++
+				} else if tt == html.EndTagToken && skip > 0 {
+					skip--
+				}
+			case headings[a]:
+				text.block()
+				if start {
+					inHeading = true
+					heading.Reset()
+				} else if inHeading {
+					inHeading = false
+					if h := squash(heading.String()); h != "" {
+						doc.Headings = append(doc.Headings, h)
+					}
+				}
+			case a == atom.Img && start:
+				for more := hasAttr; more; {
+					var key, val []byte
+					key, val, more = z.TagAttr()
+					if string(key) == "alt" {
+						text.text(" " + string(val) + " ")
+					}
+				}
+			case !inline[a]:
+				text.block()
+			}
+		}
+	}
+}
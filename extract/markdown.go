@@ -0,0 +1,131 @@
+package extract
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+)
+
+type markdownExtractor struct{}
+
+var (
+	atxHeading  = regexp.MustCompile(`^ {0,3}(#{1,6})(?:\s+(.*?))?(?:\s+#+)?\s*$`)
+	setextLine  = regexp.MustCompile(`^ {0,3}(=+|-+)\s*$`)
+	fence       = regexp.MustCompile("^ {0,3}(```|~~~)")
+	ruleLine    = regexp.MustCompile(`^ {0,3}([-*_])(\s*([-*_])){2,}\s*$`)
+	refLink     = regexp.MustCompile(`^ {0,3}\[[^\]]+\]:\s*\S+.*$`)
+	blockPrefix = regexp.MustCompile(`^\s*(>\s?)*\s*(?:[-*+]|\d+[.)])?\s+`)
+	image       = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	link        = regexp.MustCompile(`\[([^\]]*)\](?:\([^)]*\)|\[[^\]]*\])`)
+	autolink    = regexp.MustCompile(`<((?:https?|ftp|mailto):[^>\s]+)>`)
+	htmlTag     = regexp.MustCompile(`</?[a-zA-Z][^>]*>`)
+	emphasis    = regexp.MustCompile("\\*+|~~|`+")
+	underscoreL = regexp.MustCompile(`(^|[^\p{L}\p{N}])_+`)
+	underscoreR = regexp.MustCompile(`_+([^\p{L}\p{N}]|$)`)
+	escaped     = regexp.MustCompile("\\\\([!-/:-@\\[-`{-~])")
+)
+
+// Extract strips the markup of the document, code blocks are kept as text.
+// The first heading of the top level is the title
+func (markdownExtractor) Extract(reader io.Reader) (*Document, error) {
+	doc := &Document{}
+	var text textBuilder
+	var inCode string
+	// prev is the previous line of the paragraph, it becomes a heading if it is underlined
+	var prev string
+	titleLevel := 7
+
+	addHeading := func(h string, level int) {
+		h = squash(inlineText(h))
+		if h == "" {
+			return
+		}
+		doc.Headings = append(doc.Headings, h)
+		if level < titleLevel {
+			doc.Title, titleLevel = h, level
+		}
+	}
+	flush := func() {
+		if prev != "" {
+			text.text(inlineText(prev))
+			text.block()
+			prev = ""
+		}
+	}
+
+	sc := bufio.NewScanner(reader)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		line := sc.Text()
+		if m := fence.FindStringSubmatch(line); m != nil {
+			flush()
+			if inCode == "" {
+				inCode = m[1]
+			} else if inCode == m[1] {
+				inCode = ""
+			}
+			continue
+		}
+		if inCode != "" {
+			text.text(line)
+			text.block()
+			continue
+		}
+
+		switch {
+		case strings.TrimSpace(line) == "":
+			flush()
+		case prev != "" && setextLine.MatchString(line):
+			level := 1
+			if strings.Contains(line, "-") {
+				level = 2
+			}
+			addHeading(prev, level)
+			text.text(inlineText(prev))
+			text.block()
+			prev = ""
+		case ruleLine.MatchString(line), refLink.MatchString(line):
+			flush()
+		default:
+			if m := atxHeading.FindStringSubmatch(line); m != nil {
+				flush()
+				addHeading(m[2], len(m[1]))
+				text.text(inlineText(m[2]))
+				text.block()
+				continue
+			}
+			flush()
+			prev = blockPrefix.ReplaceAllString(line, "")
+		}
+	}
+	flush()
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	doc.Text = text.String()
+	return doc, nil
+}
+
+// inlineText removes inline markup of the line, escaped characters are kept as is
+func inlineText(s string) string {
+	var sb strings.Builder
+	last := 0
+	for _, m := range escaped.FindAllStringSubmatchIndex(s, -1) {
+		sb.WriteString(stripInline(s[last:m[0]]))
+		sb.WriteString(s[m[2]:m[3]])
+		last = m[1]
+	}
+	sb.WriteString(stripInline(s[last:]))
+	return sb.String()
+}
+
+func stripInline(s string) string {
+	s = image.ReplaceAllString(s, "$1")
+	s = link.ReplaceAllString(s, "$1")
+	s = autolink.ReplaceAllString(s, "$1")
+	s = htmlTag.ReplaceAllString(s, " ")
+	s = emphasis.ReplaceAllString(s, "")
+	s = underscoreL.ReplaceAllString(s, "$1")
+	return underscoreR.ReplaceAllString(s, "$1")
+}
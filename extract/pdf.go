@@ -0,0 +1,882 @@
+package extract
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"unicode/utf16"
+)
+
+type pdfExtractor struct{}
+
+// ErrNoPDF is returned for files which are not pdf documents
+var ErrNoPDF = errors.New("pdf: not a pdf document")
+
+// maxCMapRange limits codes of a single range of the character map
+const maxCMapRange = 1 << 16
+
+// Extract reads the text layer of the pages in the order of the page tree.
+// Only streams without filters or compressed with FlateDecode are read, scanned pages have no text.
+// The title is read from the document information and the headings from the outline
+func (pdfExtractor) Extract(reader io.Reader) (*Document, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("%PDF")) {
+		return nil, ErrNoPDF
+	}
+
+	p := parsePDF(data)
+	doc := &Document{}
+	var text textBuilder
+	fonts := make(map[pdfRef]*pdfFont)
+	for _, page := range p.pages() {
+		p.pageText(page, fonts, &text)
+		text.block()
+	}
+	doc.Text = text.String()
+
+	if info, ok := p.resolve(p.trailer["Info"]).(pdfDict); ok {
+		if title, ok := p.resolve(info["Title"]).([]byte); ok {
+			doc.Title = squash(pdfString(title))
+		}
+	}
+	if catalog := p.catalog(); catalog != nil {
+		if outlines, ok := p.resolve(catalog["Outlines"]).(pdfDict); ok {
+			p.outline(outlines["First"], doc, make(map[pdfRef]bool))
+		}
+	}
+	return doc, nil
+}
+
+type (
+	pdfName    string
+	pdfKeyword string
+	pdfArray   []interface{}
+	pdfDict    map[pdfName]interface{}
+	pdfRef     struct{ num, gen int }
+)
+
+type pdfObject struct {
+	value  interface{}
+	stream []byte
+}
+
+type pdfFile struct {
+	objects map[int]*pdfObject
+	trailer pdfDict
+}
+
+var objectStart = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+
+// parsePDF reads all objects of the file ignoring the cross-reference table, so damaged files are read as well
+func parsePDF(data []byte) *pdfFile {
+	p := &pdfFile{objects: make(map[int]*pdfObject), trailer: pdfDict{}}
+	// next skips matches inside the data of the previous stream
+	var next int
+	for _, m := range objectStart.FindAllSubmatchIndex(data, -1) {
+		if m[0] < next {
+			continue
+		}
+		num, _ := strconv.Atoi(string(data[m[2]:m[3]]))
+		l := &pdfLexer{data: data, pos: m[1]}
+		v, err := l.value()
+		if err != nil {
+			continue
+		}
+		obj := &pdfObject{value: v}
+		if d, ok := v.(pdfDict); ok {
+			obj.stream = l.stream(d)
+			if d["Type"] == pdfName("XRef") {
+				p.mergeTrailer(d)
+			}
+		}
+		p.objects[num] = obj
+		next = l.pos
+	}
+	// trailers of incremental updates go later and override the previous ones
+	for off := 0; ; {
+		i := bytes.Index(data[off:], []byte("trailer"))
+		if i < 0 {
+			break
+		}
+		off += i + len("trailer")
+		if d, ok := mustValue(&pdfLexer{data: data, pos: off}).(pdfDict); ok {
+			p.mergeTrailer(d)
+		}
+	}
+
+	// objects may be compressed in object streams, they are added after the plain objects
+	nums := make([]int, 0, len(p.objects))
+	for num := range p.objects {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	for _, num := range nums {
+		obj := p.objects[num]
+		if d, ok := obj.value.(pdfDict); ok && d["Type"] == pdfName("ObjStm") {
+			p.objectStream(d, obj.stream)
+		}
+	}
+	return p
+}
+
+func (p *pdfFile) mergeTrailer(d pdfDict) {
+	for _, key := range []pdfName{"Root", "Info"} {
+		if v, ok := d[key]; ok {
+			p.trailer[key] = v
+		}
+	}
+}
+
+func (p *pdfFile) objectStream(d pdfDict, raw []byte) {
+	data, ok := decodeStream(d, raw)
+	if !ok {
+		return
+	}
+	n, _ := d["N"].(float64)
+	first, _ := d["First"].(float64)
+	if int(first) > len(data) {
+		return
+	}
+	l := &pdfLexer{data: data[:int(first)]}
+	for i := 0; i < int(n); i++ {
+		num, ok1 := mustValue(l).(float64)
+		off, ok2 := mustValue(l).(float64)
+		if !ok1 || !ok2 {
+			return
+		}
+		if _, ok := p.objects[int(num)]; ok || int(first)+int(off) >= len(data) {
+			continue
+		}
+		v := mustValue(&pdfLexer{data: data, pos: int(first) + int(off)})
+		p.objects[int(num)] = &pdfObject{value: v}
+	}
+}
+
+// resolve returns the value the reference points at
+func (p *pdfFile) resolve(v interface{}) interface{} {
+	for i := 0; i < 32; i++ {
+		ref, ok := v.(pdfRef)
+		if !ok {
+			return v
+		}
+		obj, ok := p.objects[ref.num]
+		if !ok {
+			return nil
+		}
+		v = obj.value
+	}
+	return nil
+}
+
+func (p *pdfFile) streamOf(v interface{}) ([]byte, bool) {
+	ref, ok := v.(pdfRef)
+	if !ok {
+		return nil, false
+	}
+	obj, ok := p.objects[ref.num]
+	if !ok {
+		return nil, false
+	}
+	d, _ := obj.value.(pdfDict)
+	return decodeStream(d, obj.stream)
+}
+
+func (p *pdfFile) catalog() pdfDict {
+	d, _ := p.resolve(p.trailer["Root"]).(pdfDict)
+	return d
+}
+
+type pdfPage struct {
+	dict      pdfDict
+	resources pdfDict
+}
+
+// pages returns the pages in the order of the page tree, or in the order of the objects if there is no tree
+func (p *pdfFile) pages() []pdfPage {
+	var pages []pdfPage
+	if catalog := p.catalog(); catalog != nil {
+		p.walkPages(catalog["Pages"], nil, &pages, make(map[pdfRef]bool))
+	}
+	if len(pages) > 0 {
+		return pages
+	}
+
+	nums := make([]int, 0, len(p.objects))
+	for num := range p.objects {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	for _, num := range nums {
+		if d, ok := p.objects[num].value.(pdfDict); ok && d["Type"] == pdfName("Page") {
+			res, _ := p.resolve(d["Resources"]).(pdfDict)
+			pages = append(pages, pdfPage{dict: d, resources: res})
+		}
+	}
+	return pages
+}
+
+func (p *pdfFile) walkPages(v interface{}, resources pdfDict, pages *[]pdfPage, seen map[pdfRef]bool) {
+	if ref, ok := v.(pdfRef); ok {
+		if seen[ref] {
+			return
+		}
+		seen[ref] = true
+	}
+	d, ok := p.resolve(v).(pdfDict)
+	if !ok {
+		return
+	}
+	// resources are inherited from the parent nodes
+	if res, ok := p.resolve(d["Resources"]).(pdfDict); ok {
+		resources = res
+	}
+	if d["Type"] == pdfName("Page") {
+		*pages = append(*pages, pdfPage{dict: d, resources: resources})
+		return
+	}
+	kids, _ := p.resolve(d["Kids"]).(pdfArray)
+	for _, kid := range kids {
+		p.walkPages(kid, resources, pages, seen)
+	}
+}
+
+func (p *pdfFile) outline(v interface{}, doc *Document, seen map[pdfRef]bool) {
+	for v != nil {
+		ref, ok := v.(pdfRef)
+		if !ok || seen[ref] {
+			return
+		}
+		seen[ref] = true
+		item, ok := p.resolve(ref).(pdfDict)
+		if !ok {
+			return
+		}
+		if title, ok := p.resolve(item["Title"]).([]byte); ok {
+			if h := squash(pdfString(title)); h != "" {
+				doc.Headings = append(doc.Headings, h)
+			}
+		}
+		p.outline(item["First"], doc, seen)
+		v = item["Next"]
+	}
+}
+
+// pageText writes the text of the page content to the builder
+func (p *pdfFile) pageText(page pdfPage, fonts map[pdfRef]*pdfFont, text *textBuilder) {
+	var content []byte
+	switch c := p.resolve(page.dict["Contents"]).(type) {
+	case pdfArray:
+		for _, part := range c {
+			if data, ok := p.streamOf(part); ok {
+				content = append(content, data...)
+				content = append(content, '\n')
+			}
+		}
+	default:
+		if data, ok := p.streamOf(page.dict["Contents"]); ok {
+			content = data
+		}
+	}
+
+	pageFonts, _ := p.resolve(page.resources["Font"]).(pdfDict)
+	font := func(name pdfName) *pdfFont {
+		ref, ok := pageFonts[name].(pdfRef)
+		if !ok {
+			return nil
+		}
+		if f, ok := fonts[ref]; ok {
+			return f
+		}
+		f := p.font(ref)
+		fonts[ref] = f
+		return f
+	}
+	showText(content, font, text)
+}
+
+// pdfFont decodes strings shown with the font
+type pdfFont struct {
+	cmap map[string]string
+	// lengths lists lengths of the codes of the character map, longest first
+	lengths []int
+	// twoByte fonts have no single-byte encoding to fall back to
+	twoByte bool
+}
+
+func (p *pdfFile) font(ref pdfRef) *pdfFont {
+	d, ok := p.resolve(ref).(pdfDict)
+	if !ok {
+		return nil
+	}
+	f := &pdfFont{twoByte: d["Subtype"] == pdfName("Type0")}
+	if data, ok := p.streamOf(d["ToUnicode"]); ok {
+		f.cmap = parseCMap(data)
+		seen := make(map[int]bool)
+		for code := range f.cmap {
+			if !seen[len(code)] {
+				seen[len(code)] = true
+				f.lengths = append(f.lengths, len(code))
+			}
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(f.lengths)))
+	}
+	return f
+}
+
+func (f *pdfFont) decode(s []byte) string {
+	if f == nil || len(f.cmap) == 0 {
+		if f != nil && f.twoByte {
+			return ""
+		}
+		return pdfString(s)
+	}
+	var out []rune
+	for len(s) > 0 {
+		matched := false
+		for _, n := range f.lengths {
+			if n <= len(s) {
+				if u, ok := f.cmap[string(s[:n])]; ok {
+					out = append(out, []rune(u)...)
+					s = s[n:]
+					matched = true
+					break
+				}
+			}
+		}
+		if matched {
+			continue
+		}
+		if f.twoByte {
+			if len(s) < 2 {
+				break
+			}
+			s = s[2:]
+			continue
+		}
+		out = append(out, rune(s[0]))
+		s = s[1:]
+	}
+	return string(out)
+}
+
+// parseCMap reads bfchar and bfrange mappings of the ToUnicode character map
+func parseCMap(data []byte) map[string]string {
+	cmap := make(map[string]string)
+	l := &pdfLexer{data: data}
+	var stack []interface{}
+	var mode pdfKeyword
+	for {
+		v, err := l.value()
+		if err != nil {
+			return cmap
+		}
+		kw, ok := v.(pdfKeyword)
+		if !ok {
+			if mode != "" {
+				stack = append(stack, v)
+			}
+			if mode == "beginbfchar" && len(stack) == 2 {
+				src, ok1 := stack[0].([]byte)
+				dst, ok2 := stack[1].([]byte)
+				if ok1 && ok2 {
+					cmap[string(src)] = utf16String(dst)
+				}
+				stack = stack[:0]
+			}
+			if mode == "beginbfrange" && len(stack) == 3 {
+				cmapRange(cmap, stack)
+				stack = stack[:0]
+			}
+			continue
+		}
+		switch kw {
+		case "beginbfchar", "beginbfrange":
+			mode = kw
+		case "endbfchar", "endbfrange":
+			mode = ""
+		}
+		stack = stack[:0]
+	}
+}
+
+func cmapRange(cmap map[string]string, v []interface{}) {
+	lo, ok1 := v[0].([]byte)
+	hi, ok2 := v[1].([]byte)
+	if !ok1 || !ok2 || len(lo) != len(hi) || len(lo) == 0 || len(lo) > 4 {
+		return
+	}
+	start, end := codeValue(lo), codeValue(hi)
+	if end < start || end-start >= maxCMapRange {
+		return
+	}
+	for c := start; c <= end; c++ {
+		code := make([]byte, len(lo))
+		for i, x := len(code)-1, c; i >= 0; i, x = i-1, x>>8 {
+			code[i] = byte(x)
+		}
+		switch dst := v[2].(type) {
+		case []byte:
+			units := utf16Units(dst)
+			if len(units) == 0 {
+				return
+			}
+			units[len(units)-1] += uint16(c - start)
+			cmap[string(code)] = string(utf16.Decode(units))
+		case pdfArray:
+			if i := int(c - start); i < len(dst) {
+				if s, ok := dst[i].([]byte); ok {
+					cmap[string(code)] = utf16String(s)
+				}
+			}
+		}
+	}
+}
+
+func codeValue(b []byte) uint32 {
+	var v uint32
+	for _, c := range b {
+		v = v<<8 | uint32(c)
+	}
+	return v
+}
+
+func utf16Units(b []byte) []uint16 {
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		units[i] = uint16(b[2*i])<<8 | uint16(b[2*i+1])
+	}
+	return units
+}
+
+func utf16String(b []byte) string {
+	return string(utf16.Decode(utf16Units(b)))
+}
+
+// pdfString decodes the text string, it is UTF-16 with the byte order mark or a single-byte encoding
+func pdfString(b []byte) string {
+	if len(b) >= 2 && b[0] == 0xfe && b[1] == 0xff {
+		return utf16String(b[2:])
+	}
+	r := make([]rune, len(b))
+	for i, c := range b {
+		r[i] = rune(c)
+	}
+	return string(r)
+}
+
+// kerningSpace is the shift in thousandths of the font size which separates words in TJ arrays
+const kerningSpace = -200
+
+// showText interprets the text operators of the content stream
+func showText(content []byte, font func(pdfName) *pdfFont, text *textBuilder) {
+	l := &pdfLexer{data: content}
+	var stack []interface{}
+	var current *pdfFont
+	var lastY float64
+	for {
+		v, err := l.value()
+		if err != nil {
+			return
+		}
+		op, ok := v.(pdfKeyword)
+		if !ok {
+			stack = append(stack, v)
+			continue
+		}
+		switch op {
+		case "Tf":
+			if len(stack) >= 2 {
+				if name, ok := stack[len(stack)-2].(pdfName); ok {
+					current = font(name)
+				}
+			}
+		case "Tj":
+			if s, ok := last(stack).([]byte); ok {
+				text.text(current.decode(s))
+			}
+		case "'", "\"":
+			text.block()
+			if s, ok := last(stack).([]byte); ok {
+				text.text(current.decode(s))
+			}
+		case "TJ":
+			arr, _ := last(stack).(pdfArray)
+			for _, item := range arr {
+				switch x := item.(type) {
+				case []byte:
+					text.text(current.decode(x))
+				case float64:
+					if x <= kerningSpace {
+						text.text(" ")
+					}
+				}
+			}
+		case "Td", "TD":
+			if len(stack) >= 2 {
+				if y, ok := stack[len(stack)-1].(float64); ok && y != 0 {
+					text.block()
+				}
+			}
+		case "Tm":
+			if len(stack) >= 6 {
+				if y, ok := last(stack).(float64); ok {
+					if y != lastY {
+						text.block()
+					} else {
+						text.text(" ")
+					}
+					lastY = y
+				}
+			}
+		case "T*":
+			text.block()
+		case "ET":
+			text.text(" ")
+		case "BI":
+			l.skipInlineImage()
+		}
+		stack = stack[:0]
+	}
+}
+
+func last(stack []interface{}) interface{} {
+	if len(stack) == 0 {
+		return nil
+	}
+	return stack[len(stack)-1]
+}
+
+// decodeStream returns the decoded content of the stream, ok is false for unsupported filters
+func decodeStream(d pdfDict, raw []byte) ([]byte, bool) {
+	if raw == nil {
+		return nil, false
+	}
+	var filters []interface{}
+	switch f := d["Filter"].(type) {
+	case nil:
+	case pdfName:
+		filters = []interface{}{f}
+	case pdfArray:
+		filters = f
+	default:
+		return nil, false
+	}
+	data := raw
+	for _, f := range filters {
+		if f != pdfName("FlateDecode") && f != pdfName("Fl") {
+			return nil, false
+		}
+		r, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, false
+		}
+		// streams are often followed by garbage, the data read before the error is kept
+		out, err := ioutil.ReadAll(r)
+		if err != nil && len(out) == 0 {
+			return nil, false
+		}
+		data = out
+	}
+	return data, true
+}
+
+// pdfLexer reads values of the pdf syntax
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+var errEnd = errors.New("pdf: unexpected end of data")
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return bytes.IndexByte([]byte("()<>[]{}/%"), c) >= 0
+}
+
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		switch {
+		case isPDFSpace(c):
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func mustValue(l *pdfLexer) interface{} {
+	v, _ := l.value()
+	return v
+}
+
+// value reads the next value, keywords and operators are returned as pdfKeyword
+func (l *pdfLexer) value() (interface{}, error) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, errEnd
+	}
+	c := l.data[l.pos]
+	switch {
+	case c == '/':
+		l.pos++
+		return pdfName(l.regular()), nil
+	case c == '(':
+		return l.literal(), nil
+	case c == '<' && l.peek(1) == '<':
+		l.pos += 2
+		return l.dict()
+	case c == '<':
+		return l.hex(), nil
+	case c == '[':
+		l.pos++
+		var arr pdfArray
+		for {
+			l.skipSpace()
+			if l.pos >= len(l.data) {
+				return arr, errEnd
+			}
+			if l.data[l.pos] == ']' {
+				l.pos++
+				return arr, nil
+			}
+			v, err := l.value()
+			if err != nil {
+				return arr, err
+			}
+			arr = append(arr, v)
+		}
+	case c == ']' || c == '>' || c == ')' || c == '{' || c == '}':
+		l.pos++
+		return pdfKeyword(c), nil
+	}
+
+	word := l.regular()
+	if n, err := strconv.ParseFloat(word, 64); err == nil {
+		// "num gen R" is a reference
+		save := l.pos
+		if gen, ok := l.integer(); ok {
+			l.skipSpace()
+			if l.peek(0) == 'R' && (l.pos+1 >= len(l.data) || isPDFSpace(l.data[l.pos+1]) || isPDFDelimiter(l.data[l.pos+1])) {
+				l.pos++
+				return pdfRef{num: int(n), gen: gen}, nil
+			}
+		}
+		l.pos = save
+		return n, nil
+	}
+	switch word {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	return pdfKeyword(word), nil
+}
+
+func (l *pdfLexer) peek(i int) byte {
+	if l.pos+i < len(l.data) {
+		return l.data[l.pos+i]
+	}
+	return 0
+}
+
+func (l *pdfLexer) regular() string {
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	if l.pos == start && l.pos < len(l.data) {
+		l.pos++
+	}
+	return string(l.data[start:l.pos])
+}
+
+func (l *pdfLexer) integer() (int, bool) {
+	l.skipSpace()
+	start := l.pos
+	for l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '9' {
+		l.pos++
+	}
+	if start == l.pos {
+		return 0, false
+	}
+	n, err := strconv.Atoi(string(l.data[start:l.pos]))
+	return n, err == nil
+}
+
+func (l *pdfLexer) dict() (interface{}, error) {
+	d := pdfDict{}
+	for {
+		l.skipSpace()
+		if l.pos >= len(l.data) {
+			return d, errEnd
+		}
+		if l.data[l.pos] == '>' && l.peek(1) == '>' {
+			l.pos += 2
+			return d, nil
+		}
+		key, err := l.value()
+		if err != nil {
+			return d, err
+		}
+		name, ok := key.(pdfName)
+		if !ok {
+			continue
+		}
+		v, err := l.value()
+		if err != nil {
+			return d, err
+		}
+		d[name] = v
+	}
+}
+
+func (l *pdfLexer) literal() []byte {
+	l.pos++
+	var out []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return out
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				return out
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if l.peek(0) == '\n' {
+					l.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && l.peek(0) >= '0' && l.peek(0) <= '7'; i++ {
+						v = v*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+func (l *pdfLexer) hex() []byte {
+	l.pos++
+	var digits []byte
+	for l.pos < len(l.data) && l.data[l.pos] != '>' {
+		if c := l.data[l.pos]; !isPDFSpace(c) {
+			digits = append(digits, c)
+		}
+		l.pos++
+	}
+	l.pos++
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, 0, len(digits)/2)
+	for i := 0; i < len(digits); i += 2 {
+		v, err := strconv.ParseUint(string(digits[i:i+2]), 16, 8)
+		if err != nil {
+			return out
+		}
+		out = append(out, byte(v))
+	}
+	return out
+}
+
+// stream returns the raw data of the stream following the dictionary, it is nil if there is no stream.
+// The lexer is moved after the data
+func (l *pdfLexer) stream(d pdfDict) []byte {
+	l.skipSpace()
+	if !bytes.HasPrefix(l.data[l.pos:], []byte("stream")) {
+		return nil
+	}
+	start := l.pos + len("stream")
+	if start < len(l.data) && l.data[start] == '\r' {
+		start++
+	}
+	if start < len(l.data) && l.data[start] == '\n' {
+		start++
+	}
+	// a broken length, like a negative or a too large one, is ignored and the end of the stream is looked up
+	if n, ok := d["Length"].(float64); ok && n >= 0 && n <= float64(len(l.data)-start) {
+		end := start + int(n)
+		if bytes.HasPrefix(bytes.TrimLeft(l.data[end:], " \r\n"), []byte("endstream")) {
+			l.pos = end
+			return l.data[start:end]
+		}
+	}
+	end := bytes.Index(l.data[start:], []byte("endstream"))
+	if end < 0 {
+		return nil
+	}
+	l.pos = start + end
+	return bytes.TrimRight(l.data[start:start+end], "\r\n")
+}
+
+// skipInlineImage skips the data of the inline image up to the EI operator
+func (l *pdfLexer) skipInlineImage() {
+	i := bytes.Index(l.data[l.pos:], []byte("ID"))
+	if i < 0 {
+		l.pos = len(l.data)
+		return
+	}
+	l.pos += i + 2
+	for l.pos < len(l.data) {
+		j := bytes.Index(l.data[l.pos:], []byte("EI"))
+		if j < 0 {
+			l.pos = len(l.data)
+			return
+		}
+		l.pos += j + 2
+		if isPDFSpace(l.data[l.pos-3]) && (l.pos >= len(l.data) || isPDFSpace(l.data[l.pos])) {
+			return
+		}
+	}
+}
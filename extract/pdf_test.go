@@ -0,0 +1,92 @@
+package extract
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// pdfDocument builds the document from the bodies of numbered objects, streams are written as given
+func pdfDocument(objects []string, trailer string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	for i, o := range objects {
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, o)
+	}
+	fmt.Fprintf(&buf, "trailer\n%s\n%%%%EOF\n", trailer)
+	return buf.Bytes()
+}
+
+func pdfStream(dict, data string) string {
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data)
+}
+
+func flate(t *testing.T, s string) string {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	_, err := w.Write([]byte(s))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.String()
+}
+
+func TestPDF(t *testing.T) {
+	cmap := `/CIDInit /ProcSet findresource begin
+begincmap
+1 begincodespacerange <0000> <FFFF> endcodespacerange
+2 beginbfchar
+<0001> <0048>
+<0002> <0069>
+endbfchar
+1 beginbfrange
+<0010> <0012> <0061>
+endbfrange
+endcmap`
+	data := pdfDocument([]string{
+		"<< /Type /Catalog /Pages 2 0 R /Outlines 9 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 /Resources << /Font << /F1 5 0 R /F2 6 0 R >> >> >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 7 0 R >>",
+		"<< /Type /Page /Parent 2 0 R /Contents [8 0 R] >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		"<< /Type /Font /Subtype /Type0 /BaseFont /Custom /ToUnicode 12 0 R >>",
+		pdfStream("", `BT /F1 12 Tf 72 720 Td (Hello \(PDF\) world) Tj 0 -14 Td [(Sec) -50 (ond) -300 (line)] TJ ET
+BT /F2 12 Tf 72 600 Td [<00010002> -250 <001000110012>] TJ ET`),
+		pdfStream("/Filter /FlateDecode", flate(t, "BT /F1 10 Tf 1 0 0 1 72 700 Tm (Next page) Tj T* (last) Tj ET")),
+		"<< /Type /Outlines /First 10 0 R /Last 11 0 R >>",
+		"<< /Title (Introduction) /Parent 9 0 R /Next 11 0 R >>",
+		"<< /Title <FEFF0053006F006D0065> /Parent 9 0 R >>",
+		pdfStream("", cmap),
+		"<< /Title (The Title) >>",
+	}, "<< /Root 1 0 R /Info 13 0 R /Size 14 >>")
+
+	doc, err := PDF.Extract(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, "The Title", doc.Title)
+	require.Equal(t, []string{"Introduction", "Some"}, doc.Headings)
+	require.Equal(t, "Hello (PDF) world\nSecond line\nHi abc\nNext page\nlast", doc.Text)
+
+	_, err = PDF.Extract(strings.NewReader("plain text"))
+	require.Equal(t, ErrNoPDF, err)
+}
+
+func TestPDF_BrokenStreamLength(t *testing.T) {
+	content := "BT /F1 12 Tf (Hello) Tj ET"
+	for _, length := range []string{"99999999999999999999", "-5", "100000"} {
+		data := pdfDocument([]string{
+			"<< /Type /Catalog /Pages 2 0 R >>",
+			"<< /Type /Pages /Kids [3 0 R] /Count 1 /Resources << /Font << /F1 5 0 R >> >> >>",
+			"<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>",
+			"<< /Length " + length + " >>\nstream\n" + content + "\nendstream",
+			"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		}, "<< /Root 1 0 R /Size 6 >>")
+		doc, err := PDF.Extract(bytes.NewReader(data))
+		require.NoError(t, err, length)
+		require.Equal(t, "Hello", doc.Text, length)
+	}
+	_, err := PDF.Extract(strings.NewReader("%PDF-1.4\n1 0 obj\n<< /Length 99999999999999999999 >>\nstream\nx\nendstream"))
+	require.NoError(t, err)
+}
@@ -3,13 +3,16 @@ module github.com/polisgo2020/search-senyast4745
 go 1.13
 
 require (
+	github.com/fsnotify/fsnotify v1.4.9
 	github.com/go-chi/chi v4.0.4+incompatible
 	github.com/go-chi/cors v1.0.1
+	github.com/klauspost/compress v1.9.5
 	github.com/reiver/go-porterstemmer v1.0.1
 	github.com/rs/zerolog v1.18.0
 	github.com/stretchr/testify v1.4.0
 	github.com/urfave/cli/v2 v2.2.0
 	github.com/xlab/closer v0.0.0-20190328110542-03326addb7c2
 	go.mongodb.org/mongo-driver v1.3.2
-	golang.org/x/net v0.0.0-20190813141303-74dc4d7220e7 // indirect
+	golang.org/x/net v0.0.0-20190813141303-74dc4d7220e7
+	golang.org/x/text v0.3.2
 )
@@ -0,0 +1,305 @@
+package index
+
+import (
+	"container/heap"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/polisgo2020/search-senyast4745/analysis"
+)
+
+// postingOverhead estimates memory used by a posting besides its positions:
+// the structure, the pointer in the postings slice and the map entry of the term
+const postingOverhead = 100
+
+// Builder builds an index which may not fit in memory.
+// Documents are collected in a partial index, which is spilled to a temporary segment
+// when its estimated size exceeds the memory budget. Merge joins the partial indexes term by term.
+type Builder struct {
+	m         sync.Mutex
+	maxMemory int64
+	dir       string
+	current   *Index
+	analyzer  *analysis.Analyzer
+	size      int64
+	docs      map[string]int
+	spills    []string
+	// terms is counted by the last complete merge
+	terms int
+}
+
+// NewBuilder creates the builder keeping about maxMemory bytes of postings in memory,
+// temporary segments are created in a new directory inside dir or in the default directory for temporary files.
+// maxMemory of 0 means the whole index is kept in memory
+func NewBuilder(maxMemory int64, dir string) (*Builder, error) {
+	tmp, err := ioutil.TempDir(dir, "index-build")
+	if err != nil {
+		return nil, err
+	}
+	return &Builder{maxMemory: maxMemory, dir: tmp, current: NewIndex(), analyzer: analysis.Default(),
+		docs: make(map[string]int)}, nil
+}
+
+// SetAnalyzer sets the analyzer of the documents, it has to be set before the documents are added
+func (b *Builder) SetAnalyzer(a *analysis.Analyzer) {
+	b.m.Lock()
+	defer b.m.Unlock()
+	b.analyzer = a
+}
+
+// Analyzer returns the analyzer of the documents
+func (b *Builder) Analyzer() *analysis.Analyzer {
+	b.m.Lock()
+	defer b.m.Unlock()
+	return b.analyzer
+}
+
+// AddDocument indexes the document, it may be called concurrently.
+// The postings are kept even if the reader fails, the error is returned
+func (b *Builder) AddDocument(file string, reader io.Reader) error {
+	data, readErr := mapWords(b.Analyzer(), reader, file)
+
+	var size int64
+	length := data.length()
+	for word, p := range data {
+		size += int64(len(word)+postingOverhead) + 16*int64(len(p.Position))
+	}
+	size += int64(len(file))
+
+	b.m.Lock()
+	defer b.m.Unlock()
+	b.current.apply(data)
+	if length > 0 {
+		b.docs[file] += length
+	}
+	b.size += size
+	if b.maxMemory > 0 && b.size > b.maxMemory {
+		if err := b.spill(); err != nil {
+			return err
+		}
+	}
+	return readErr
+}
+
+// Docs returns lengths of all added files
+func (b *Builder) Docs() map[string]int {
+	b.m.Lock()
+	defer b.m.Unlock()
+	docs := make(map[string]int, len(b.docs))
+	for file, l := range b.docs {
+		docs[file] = l
+	}
+	return docs
+}
+
+// Spills returns number of the partial indexes saved to disk
+func (b *Builder) Spills() int {
+	b.m.Lock()
+	defer b.m.Unlock()
+	return len(b.spills)
+}
+
+// spill saves the partial index to a new segment and starts the next one, the caller must hold the lock
+func (b *Builder) spill() error {
+	if len(b.current.Data) == 0 {
+		return nil
+	}
+	path := filepath.Join(b.dir, fmt.Sprintf("spill-%04d.seg", len(b.spills)))
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = writeSegment(f, b.analyzer.Name, b.current.Data, b.current.Docs)
+	if cErr := f.Close(); err == nil {
+		err = cErr
+	}
+	if err != nil {
+		return fmt.Errorf("can not spill partial index: %w", err)
+	}
+	b.spills = append(b.spills, path)
+	b.current = NewIndex()
+	b.size = 0
+	return nil
+}
+
+// Merge passes postings of every term to the function in sorted order.
+// Postings of a term are joined from all partial indexes, only a term per segment is kept in memory.
+// No documents may be added while and after the merge
+func (b *Builder) Merge(fn TermFunc) error {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	var terms int
+	err := b.merge(func(word string, postings []*FileStruct) error {
+		terms++
+		return fn(word, postings)
+	})
+	if err == nil {
+		b.terms = terms
+	}
+	return err
+}
+
+// Terms returns number of the unique terms, it is known after the terms are merged
+func (b *Builder) Terms() int {
+	b.m.Lock()
+	defer b.m.Unlock()
+	return b.terms
+}
+
+func (b *Builder) merge(fn TermFunc) error {
+	if len(b.spills) == 0 {
+		return termsOf(b.current.Data)(fn)
+	}
+	if err := b.spill(); err != nil {
+		return err
+	}
+
+	segments := make([]*Segment, 0, len(b.spills))
+	defer func() {
+		for _, s := range segments {
+			s.Close()
+		}
+	}()
+	var h cursorHeap
+	for _, path := range b.spills {
+		s, err := OpenSegment(path)
+		if err != nil {
+			return err
+		}
+		segments = append(segments, s)
+		c := &cursor{segment: s, n: len(segments) - 1}
+		if err := c.next(); err != nil {
+			return err
+		}
+		if !c.done {
+			h = append(h, c)
+		}
+	}
+	heap.Init(&h)
+
+	for len(h) > 0 {
+		term := h[0].term
+		var postings []*FileStruct
+		for len(h) > 0 && h[0].term == term {
+			c := h[0]
+			postings = append(postings, c.postings...)
+			if err := c.next(); err != nil {
+				return err
+			}
+			if c.done {
+				heap.Pop(&h)
+			} else {
+				heap.Fix(&h, 0)
+			}
+		}
+		if err := fn(term, postings); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ToFile saves the built index using the specified encoder as Index.ToFile does.
+// The binary encoder writes the segment while the terms are merged, other encoders
+// need two passes over the terms, the first one computes the header
+func (b *Builder) ToFile(encoder Encoder, opts ...FileOption) error {
+	o := newFileOptions(opts)
+	docs := b.Docs()
+	analyzer := b.Analyzer().Name
+
+	if enc, ok := encoder.(*BinaryEncoder); ok {
+		files := make([]string, 0, len(docs))
+		for file := range docs {
+			files = append(files, file)
+		}
+		var n int
+		return writeSegmentTerms(enc.writer, analyzer, files, docs, func(fn TermFunc) error {
+			return b.Merge(func(word string, postings []*FileStruct) error {
+				if err := o.ctx.Err(); err != nil {
+					return err
+				}
+				n++
+				o.progress(n)
+				return fn(word, postings)
+			})
+		})
+	}
+	return writeRows(encoder, o, analyzer, docs, b.Merge)
+}
+
+// Close removes the temporary segments
+func (b *Builder) Close() error {
+	return os.RemoveAll(b.dir)
+}
+
+// termsOf returns the function producing the terms of the index data in sorted order
+func termsOf(data map[string][]*FileStruct) func(TermFunc) error {
+	words := make([]string, 0, len(data))
+	for word := range data {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+
+	return func(fn TermFunc) error {
+		for _, word := range words {
+			if err := fn(word, data[word]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// cursor reads terms of the segment one by one
+type cursor struct {
+	segment  *Segment
+	n        int
+	i        int
+	term     string
+	postings []*FileStruct
+	done     bool
+}
+
+func (c *cursor) next() error {
+	if c.i >= c.segment.Len() {
+		c.done, c.term, c.postings = true, "", nil
+		return nil
+	}
+	term, postings, err := c.segment.entry(c.i)
+	if err != nil {
+		return err
+	}
+	c.i++
+	c.term, c.postings = term, postings
+	return nil
+}
+
+// cursorHeap orders cursors by their current term and then by the number of the segment,
+// so postings of a term keep the order in which the documents were spilled
+type cursorHeap []*cursor
+
+func (h cursorHeap) Len() int { return len(h) }
+
+func (h cursorHeap) Less(i, j int) bool {
+	if h[i].term != h[j].term {
+		return h[i].term < h[j].term
+	}
+	return h[i].n < h[j].n
+}
+
+func (h cursorHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *cursorHeap) Push(x interface{}) { *h = append(*h, x.(*cursor)) }
+
+func (h *cursorHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
@@ -0,0 +1,82 @@
+package index
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var builderTestDocs = []string{
+	"hello world, the world is big",
+	"big data needs big indexes",
+	"hello again",
+	"",
+	"world of data",
+}
+
+func buildTestIndex(t *testing.T, maxMemory int64) *Builder {
+	b, err := NewBuilder(maxMemory, "")
+	require.NoError(t, err)
+	for i, doc := range builderTestDocs {
+		require.NoError(t, b.AddDocument(fmt.Sprintf("file%d", i), strings.NewReader(doc)))
+	}
+	return b
+}
+
+func TestBuilder_Spill(t *testing.T) {
+	ind := NewIndex()
+	for i, doc := range builderTestDocs {
+		require.NoError(t, ind.AddDocument(fmt.Sprintf("file%d", i), strings.NewReader(doc)))
+	}
+
+	for _, maxMemory := range []int64{0, 1, 500} {
+		t.Run(fmt.Sprint(maxMemory), func(t *testing.T) {
+			b := buildTestIndex(t, maxMemory)
+			defer b.Close()
+			if maxMemory > 0 {
+				require.NotZero(t, b.Spills())
+			}
+
+			for _, format := range Formats {
+				var expected, actual bytes.Buffer
+				encoder, err := NewEncoder(format, &expected)
+				require.NoError(t, err)
+				require.NoError(t, ind.ToFile(encoder))
+				encoder, err = NewEncoder(format, &actual)
+				require.NoError(t, err)
+				require.NoError(t, b.ToFile(encoder))
+				require.Equal(t, expected.String(), actual.String(), "format %s", format)
+			}
+		})
+	}
+}
+
+func TestBuilder_Merge(t *testing.T) {
+	b := buildTestIndex(t, 1)
+	defer b.Close()
+	require.Equal(t, 4, b.Spills())
+
+	var terms []string
+	require.NoError(t, b.Merge(func(word string, postings []*FileStruct) error {
+		terms = append(terms, word)
+		if word == "world" {
+			require.Equal(t, []*FileStruct{
+				{File: "file0", Position: []int{1, 2}, Offsets: []int{6, 17}},
+				{File: "file4", Position: []int{0}, Offsets: []int{0}},
+			}, postings)
+		}
+		return nil
+	}))
+	require.Equal(t, []string{"big", "data", "hello", "index", "need", "world"}, terms)
+	require.Equal(t, len(terms), b.Terms())
+	require.Equal(t, map[string]int{"file0": 4, "file1": 5, "file2": 1, "file4": 2}, b.Docs())
+
+	dir := b.dir
+	require.NoError(t, b.Close())
+	_, err := os.Stat(dir)
+	require.True(t, os.IsNotExist(err))
+}
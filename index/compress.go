@@ -0,0 +1,95 @@
+package index
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression names the compression of the index file
+type Compression string
+
+const (
+	CompressionNone Compression = "none"
+	CompressionGzip Compression = "gzip"
+	CompressionZstd Compression = "zstd"
+)
+
+// Compressions lists all supported compressions
+var Compressions = []Compression{CompressionNone, CompressionGzip, CompressionZstd}
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// ParseCompression returns the compression with the given name
+func ParseCompression(name string) (Compression, error) {
+	for _, c := range Compressions {
+		if string(c) == name {
+			return c, nil
+		}
+	}
+	return "", fmt.Errorf("unknown compression %q, expected one of %v", name, Compressions)
+}
+
+// CompressionFromPath chooses the compression by the extension of the file
+func CompressionFromPath(path string) Compression {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gz", ".gzip":
+		return CompressionGzip
+	case ".zst", ".zstd":
+		return CompressionZstd
+	}
+	return CompressionNone
+}
+
+// NewCompressWriter wraps the writer with the compression, the returned writer must be closed to flush the data.
+// Both gzip and zstd streams carry a checksum of the content
+func NewCompressWriter(c Compression, writer io.Writer) (io.WriteCloser, error) {
+	switch c {
+	case CompressionNone:
+		return nopWriteCloser{writer}, nil
+	case CompressionGzip:
+		return gzip.NewWriter(writer), nil
+	case CompressionZstd:
+		return zstd.NewWriter(writer, zstd.WithEncoderCRC(true))
+	}
+	return nil, fmt.Errorf("unknown compression %q", c)
+}
+
+// NewDecompressReader detects the compression by its magic bytes and unwraps the reader.
+// The checksum of the content is validated when the returned reader reaches the end of the stream
+func NewDecompressReader(reader *bufio.Reader) (io.ReadCloser, Compression, error) {
+	header, err := reader.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return nil, "", err
+	}
+	switch {
+	case bytes.HasPrefix(header, gzipMagic):
+		r, err := gzip.NewReader(reader)
+		return r, CompressionGzip, err
+	case bytes.HasPrefix(header, zstdMagic):
+		r, err := zstd.NewReader(reader)
+		if err != nil {
+			return nil, "", err
+		}
+		return r.IOReadCloser(), CompressionZstd, nil
+	}
+	return ioutil.NopCloser(reader), CompressionNone, nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,56 @@
+package index
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func compressTestIndex(t *testing.T, c Compression) []byte {
+	var buf bytes.Buffer
+	w, err := NewCompressWriter(c, &buf)
+	require.NoError(t, err)
+	require.NoError(t, defaultTestIndex().ToFile(NewCsvEncoder(w)))
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestCompression_RoundTrip(t *testing.T) {
+	for _, c := range Compressions {
+		t.Run(string(c), func(t *testing.T) {
+			r, detected, err := NewDecompressReader(bufio.NewReader(bytes.NewReader(compressTestIndex(t, c))))
+			require.NoError(t, err)
+			defer r.Close()
+			require.Equal(t, c, detected)
+
+			res := NewIndex()
+			require.NoError(t, res.FromFile(NewCsvDecoder(r)))
+			require.Equal(t, defaultTestIndex(), res)
+		})
+	}
+}
+
+func TestCompression_Checksum(t *testing.T) {
+	for _, c := range []Compression{CompressionGzip, CompressionZstd} {
+		t.Run(string(c), func(t *testing.T) {
+			data := compressTestIndex(t, c)
+			// the checksum is stored at the end of both streams
+			data[len(data)-2] ^= 0xff
+
+			r, _, err := NewDecompressReader(bufio.NewReader(bytes.NewReader(data)))
+			require.NoError(t, err)
+			defer r.Close()
+			_, err = ioutil.ReadAll(r)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestCompressionFromPath(t *testing.T) {
+	require.Equal(t, CompressionGzip, CompressionFromPath("index.csv.gz"))
+	require.Equal(t, CompressionZstd, CompressionFromPath("index.seg.ZST"))
+	require.Equal(t, CompressionNone, CompressionFromPath("index.csv"))
+}
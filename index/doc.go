@@ -6,5 +6,8 @@
 // clearing each file of stop words and using stemming for each token.
 // The search is also made taking into account the proximity of the search tokens.
 //
+// The index is saved as csv, json lines, gob or a compact binary segment.
+// The binary segment is searched through a memory mapping without loading the whole file.
+//
 // For information about inverted index see https://habr.com/ru/post/53987/
 package index
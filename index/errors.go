@@ -0,0 +1,77 @@
+package index
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxErrors limits the number of errors kept by Errors, a damaged file may have a broken row after another
+const maxErrors = 10
+
+// Errors combines errors found while the index file is read or written
+type Errors struct {
+	// Errs holds the first errors
+	Errs []error
+	// Total counts all added errors
+	Total int
+}
+
+// Add appends the error, nil errors are skipped
+func (e *Errors) Add(err error) {
+	if err == nil {
+		return
+	}
+	e.Total++
+	if len(e.Errs) < maxErrors {
+		e.Errs = append(e.Errs, err)
+	}
+}
+
+// merge adds all errors of the other ones
+func (e *Errors) merge(other *Errors) {
+	for _, err := range other.Errs {
+		e.Add(err)
+	}
+	e.Total += other.Total - len(other.Errs)
+}
+
+// Err returns nil if no errors are added, the only error itself or the combined error
+func (e *Errors) Err() error {
+	switch e.Total {
+	case 0:
+		return nil
+	case 1:
+		return e.Errs[0]
+	}
+	return e
+}
+
+func (e *Errors) Error() string {
+	msgs := make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		msgs[i] = err.Error()
+	}
+	msg := strings.Join(msgs, "; ")
+	if e.Total > len(e.Errs) {
+		msg += fmt.Sprintf("; and %d more errors", e.Total-len(e.Errs))
+	}
+	return msg
+}
+
+// Unwrap returns the first error, so errors.Is and errors.As look at it
+func (e *Errors) Unwrap() error {
+	if len(e.Errs) == 0 {
+		return nil
+	}
+	return e.Errs[0]
+}
+
+// RowErrors is returned by FromFile when only damaged rows are skipped, the index keeps all other rows and can be used
+type RowErrors struct {
+	Errors
+}
+
+// Unwrap returns the combined errors of the rows
+func (e *RowErrors) Unwrap() error {
+	return &e.Errors
+}
@@ -1,60 +1,294 @@
 package index
 
 import (
+	"context"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"sort"
+	"strconv"
 	"sync"
 
+	"github.com/polisgo2020/search-senyast4745/analysis"
 	"github.com/rs/zerolog/log"
 )
 
-// FromFile with the help of a given decoder reads and decodes the index file and translates it into an index structure
-func (ind *Index) FromFile(decoder Decoder) error {
+// docKey marks index file rows with the file length instead of the word postings.
+// Terms equal to the row keys are escaped by escapeTerm, so they never clash
+const docKey = "#doc"
+
+// FileOption changes how FromFile and ToFile process the index file
+type FileOption func(*fileOptions)
+
+type fileOptions struct {
+	ctx      context.Context
+	strict   bool
+	progress func(rows int)
+}
+
+func newFileOptions(opts []FileOption) *fileOptions {
+	o := &fileOptions{ctx: context.Background(), progress: func(int) {}}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// Strict makes FromFile fail on the first malformed row, on postings with negative or unsorted positions
+// and on a missing or mismatching header. By default malformed rows are skipped and reported after the whole file is read
+func Strict() FileOption {
+	return func(o *fileOptions) {
+		o.strict = true
+	}
+}
+
+// Context stops reading or writing of the index file when the context is done
+func Context(ctx context.Context) FileOption {
+	return func(o *fileOptions) {
+		o.ctx = ctx
+	}
+}
+
+// Progress sets the function called with the number of processed rows after every row
+func Progress(progress func(rows int)) FileOption {
+	return func(o *fileOptions) {
+		o.progress = progress
+	}
+}
+
+// strictDecoder is implemented by decoders which skip malformed records unless they are strict
+type strictDecoder interface {
+	setStrict(strict bool)
+}
+
+// FromFile with the help of a given decoder reads and decodes the index file and translates it into an index structure.
+// The returned error combines errors of the decoder and of the rows, the index keeps all rows which are read successfully.
+// If only damaged rows are skipped, the error is *RowErrors. If the context is done, FromFile returns its error at once
+// while the decoder is drained in the background
+func (ind *Index) FromFile(decoder Decoder, opts ...FileOption) error {
+	o := newFileOptions(opts)
+	if d, ok := decoder.(strictDecoder); ok {
+		d.setStrict(o.strict)
+	}
 
 	dataChannel := make(chan []FileData, 10)
+	done := make(chan struct{})
+	decoded := make(chan error, 1)
 
+	var header *Header
+	var read Header
+	var errs Errors
 	go func(dataCh <-chan []FileData) {
+		defer close(done)
+		var n int
 		for data := range dataCh {
-			var tmp []*FileStruct
-			if err := json.Unmarshal([]byte(data[1].ToString()), &tmp); err != nil {
-				log.Err(err).Str("json data", data[1].ToString()).Msg("can not parse json data")
+			n++
+			// the rest of the rows is drained, so the decoder is never blocked
+			if o.ctx.Err() != nil || o.strict && errs.Total > 0 {
+				continue
+			}
+			r, err := parseRow(data)
+			if err == nil && o.strict {
+				err = checkRecord(r, n, header)
+			}
+			o.progress(n)
+			if err != nil {
+				errs.Add(fmt.Errorf("row %d: %w", n, err))
 				continue
 			}
-			ind.add(data[0].ToString(), tmp)
+			switch {
+			case r.Header != nil:
+				header = r.Header
+				continue
+			case r.Doc != "":
+				ind.addDoc(r.Doc, r.Length)
+			default:
+				ind.add(r.Term, r.Postings)
+			}
+			read.add(r)
 		}
 	}(dataChannel)
 
-	return decoder.Decode(dataChannel, func() FileData {
-		return &simpleFileData{}
-	})
+	go func() {
+		decoded <- decoder.Decode(dataChannel, func() FileData {
+			return &simpleFileData{}
+		})
+	}()
+
+	// res holds the errors which make the index unusable, skipped holds the damaged rows
+	var res, skipped Errors
+	select {
+	case err := <-decoded:
+		var rowErrs *RowErrors
+		if errors.As(err, &rowErrs) {
+			skipped.merge(&rowErrs.Errors)
+		} else {
+			res.Add(err)
+		}
+	case <-o.ctx.Done():
+		return o.ctx.Err()
+	}
+	<-done
+	if o.ctx.Err() != nil {
+		return o.ctx.Err()
+	}
+
+	skipped.merge(&errs)
+	if o.strict {
+		res.merge(&skipped)
+		skipped = Errors{}
+	}
+	switch {
+	case o.strict && res.Total > 0:
+	case header == nil && o.strict:
+		res.Add(errors.New("index file has no header"))
+	case header != nil:
+		if err := header.check(&read); err != nil {
+			// the skipped rows are missing from the counts and the checksum
+			if skipped.Total > 0 && header.Version == HeaderVersion {
+				skipped.Add(err)
+			} else {
+				res.Add(err)
+			}
+		}
+	}
+	if header != nil && header.Analyzer != "" {
+		if a, err := analysis.Get(header.Analyzer); err != nil {
+			res.Add(err)
+		} else {
+			ind.SetAnalyzer(a)
+		}
+	}
+	if res.Total > 0 {
+		res.merge(&skipped)
+		return res.Err()
+	}
+	if skipped.Total > 0 {
+		return &RowErrors{Errors: skipped}
+	}
+	return nil
+}
+
+// checkRecord validates the n-th row of the index file in strict mode
+func checkRecord(r *record, n int, header *Header) error {
+	switch {
+	case r.Header != nil && (n != 1 || header != nil):
+		return errors.New("header must be the first row")
+	case r.Header == nil && n == 1:
+		return errors.New("index file has no header")
+	case r.Header == nil && r.Doc == "":
+		return checkPostings(r.Postings)
+	}
+	return nil
 }
 
-// ToFile using the specified encoder saves data to the specified writer
-func (ind *Index) ToFile(encoder Encoder) error {
+// TermFunc receives postings of the terms one by one
+type TermFunc func(word string, postings []*FileStruct) error
+
+// errStopped stops producing of the terms when the encoder returns or the context is done
+var errStopped = errors.New("stopped")
+
+// ToFile using the specified encoder saves data to the specified writer.
+// The snapshot of the index is saved, so the index may be changed while it is written.
+// The header goes first, then lengths of the files and postings of the words, both sorted by name.
+// The returned error combines errors of the encoder and of the rows, the written file is incomplete if it is not nil
+func (ind *Index) ToFile(encoder Encoder, opts ...FileOption) error {
+	snapshot := ind.Snapshot()
+	return writeRows(encoder, newFileOptions(opts), snapshot.analyzer.Name, snapshot.Docs, termsOf(snapshot.Data))
+}
+
+// writeRows passes the header, lengths of the files and postings of the terms to the encoder.
+// Terms are produced twice: the first time for the header and the second time for the rows
+func writeRows(encoder Encoder, o *fileOptions, analyzer string, docs map[string]int,
+	terms func(TermFunc) error) error {
+	files := make([]string, 0, len(docs))
+	for file := range docs {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	header := Header{Version: HeaderVersion, Analyzer: analyzer}
+	for _, file := range files {
+		header.add(&record{Doc: file, Length: docs[file]})
+	}
+	err := terms(func(word string, postings []*FileStruct) error {
+		header.add(&record{Term: word, Postings: postings})
+		return o.ctx.Err()
+	})
+	if err != nil {
+		return err
+	}
 
 	dataChannel := make(chan []FileData, 10)
+	// stop is closed when the encoder returns, it may not read the whole channel after an error
+	stop := make(chan struct{})
+	produced := make(chan error, 1)
 
 	go func(dataCh chan<- []FileData) {
-		for i := range ind.Data {
-			rawData, err := json.Marshal(ind.Data[i])
-			if err != nil {
-				log.Err(err).Interface("data", ind.Data[i]).Msg("Error while marshalling data")
-				continue
+		var errs Errors
+		defer func() {
+			close(dataCh)
+			produced <- errs.Err()
+		}()
+
+		var n int
+		send := func(str ...string) error {
+			row := make([]FileData, len(str))
+			for i := range str {
+				row[i] = newSimpleFileData(str[i])
 			}
-			dataCh <- []FileData{newSimpleFileData(i), newSimpleFileData(string(rawData))}
+			select {
+			case dataCh <- row:
+				n++
+				o.progress(n)
+				return nil
+			case <-stop:
+			case <-o.ctx.Done():
+			}
+			return errStopped
 		}
-		close(dataCh)
 
+		if send(header.strings()...) != nil {
+			return
+		}
+		for _, file := range files {
+			if send(docKey, file, strconv.Itoa(docs[file])) != nil {
+				return
+			}
+		}
+		err := terms(func(word string, postings []*FileStruct) error {
+			rawData, err := json.Marshal(postings)
+			if err != nil {
+				errs.Add(fmt.Errorf("term %q: %w", word, err))
+				return nil
+			}
+			return send(escapeTerm(word), string(rawData))
+		})
+		if err != errStopped {
+			errs.Add(err)
+		}
 	}(dataChannel)
 
-	return encoder.Encode(dataChannel)
+	err = encoder.Encode(dataChannel)
+	close(stop)
+	rowErr := <-produced
+	if o.ctx.Err() != nil {
+		return o.ctx.Err()
+	}
+
+	var res Errors
+	res.Add(err)
+	res.Add(rowErr)
+	return res.Err()
 }
 
 // CsvDecoder structure for reading and decoding csv file index
 type CsvDecoder struct {
 	m      *sync.RWMutex
 	reader io.Reader
+	strict bool
 }
 
 // CsvEncoder structure for writing an index to a csv file
@@ -101,10 +335,13 @@ func (c *CsvEncoder) Encode(dataChannel <-chan []FileData) error {
 	return nil
 }
 
-// Decode reads line-by-line data from a csv file and writes it to the channel
+// Decode reads line-by-line data from a csv file and writes it to the channel.
+// Malformed lines are skipped and returned as *RowErrors after the whole file is read,
+// read errors and too many malformed lines stop the decoding
 func (c *CsvDecoder) Decode(dataChannel chan<- []FileData, constructor func() FileData) error {
 	r := csv.NewReader(c.reader)
-	var errCount int
+	r.FieldsPerRecord = -1
+	var errs Errors
 	defer close(dataChannel)
 
 	for {
@@ -117,10 +354,14 @@ func (c *CsvDecoder) Decode(dataChannel chan<- []FileData, constructor func() Fi
 		}
 		if err != nil {
 			log.Warn().Interface("error", err).Msg("can not read csv line")
-			errCount++
-			if errCount > 100 {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
 				return err
 			}
+			errs.Add(err)
+			if errs.Total > 100 || c.strict {
+				return errs.Err()
+			}
 			continue
 		}
 		log.Debug().Strs("csv data", record).Msg("reading data from csv")
@@ -134,7 +375,14 @@ func (c *CsvDecoder) Decode(dataChannel chan<- []FileData, constructor func() Fi
 		}
 		dataChannel <- rawData
 	}
-	return nil
+	if errs.Total == 0 {
+		return nil
+	}
+	return &RowErrors{Errors: errs}
+}
+
+func (c *CsvDecoder) setStrict(strict bool) {
+	c.strict = strict
 }
 
 // NewCsvDecoder default constructor to CsvDecoder with reader
@@ -2,13 +2,19 @@ package index
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"reflect"
 	"strings"
 	"sync"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -170,10 +176,37 @@ func (f *fileTestSuite) TestIndex_ToFile() {
 	f.SimpleIndex()
 
 	err := f.index.ToFile(f.encoder)
-	assert.Equal(f.T(), f.wbuffer, bytes.NewBufferString(f.defaultStrIndex))
+	header, rows := splitHeader(f.wbuffer.String())
+	assert.True(f.T(), strings.HasPrefix(header, "#index,1,1,0,"), header)
+	assert.Equal(f.T(), f.defaultStrIndex, rows)
 	assert.Nil(f.T(), err)
 }
 
+func (f *fileTestSuite) TestIndex_FileDocs() {
+	f.SimpleIndex()
+	f.index.Docs["file1"] = 6
+
+	require.NoError(f.T(), f.index.ToFile(f.encoder))
+	header, rows := splitHeader(f.wbuffer.String())
+	require.True(f.T(), strings.HasPrefix(header, "#index,1,1,1,"), header)
+	require.Equal(f.T(), "#doc,file1,6\n"+f.defaultStrIndex, rows)
+
+	ind := NewIndex()
+	require.NoError(f.T(), ind.FromFile(NewCsvDecoder(f.wbuffer), Strict()))
+	require.Eventually(f.T(), func() bool {
+		return len(ind.Data) == 1 && len(ind.Docs) == 1
+	}, time.Second, time.Millisecond)
+	require.Equal(f.T(), 6, ind.Docs["file1"])
+}
+
+func splitHeader(str string) (string, string) {
+	lines := strings.SplitN(str, "\n", 2)
+	if len(lines) < 2 {
+		return lines[0], ""
+	}
+	return lines[0], lines[1]
+}
+
 func TestNewCsvDecoder(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -319,3 +352,93 @@ func TestSimpleFileData_FromString(t *testing.T) {
 		})
 	}
 }
+
+func TestIndex_FromFileErrors(t *testing.T) {
+	file := `hello,"[{""file"":""file1"",""position"":[0,5]}]"
+broken,"[{""file"":"
+world,"[{""file"":""file1"",""position"":[3]}]"
+bad"quote,[]
+`
+	ind := NewIndex()
+	err := ind.FromFile(NewCsvDecoder(strings.NewReader(file)))
+	require.Error(t, err)
+	var errs *Errors
+	require.True(t, errors.As(err, &errs))
+	require.Equal(t, 2, errs.Total, err.Error())
+	require.Contains(t, err.Error(), "row 2: unexpected end of JSON input")
+	require.Len(t, ind.Data, 2, "correct rows must be loaded")
+}
+
+func TestIndex_FromFileRowErrors(t *testing.T) {
+	ind := NewIndex()
+	FillDefaultIndex(ind)
+	var buf bytes.Buffer
+	require.NoError(t, ind.ToFile(NewCsvEncoder(&buf)))
+	rows := strings.Split(buf.String(), "\n")
+	rows[2] = `world,"[{""file"":broken"`
+	damaged := strings.Join(rows, "\n")
+
+	res := NewIndex()
+	err := res.FromFile(NewCsvDecoder(strings.NewReader(damaged)))
+	var rowErrs *RowErrors
+	require.True(t, errors.As(err, &rowErrs), err.Error())
+	require.Equal(t, 2, rowErrs.Total, "the header mismatch is caused by the skipped row")
+	require.Contains(t, err.Error(), "row 3: invalid character")
+	require.Len(t, res.Data, len(ind.Data)-1, "correct rows must be loaded")
+
+	err = NewIndex().FromFile(NewCsvDecoder(strings.NewReader(damaged)), Strict())
+	require.Error(t, err)
+	require.False(t, errors.As(err, &rowErrs), "damaged rows are fatal in strict mode")
+
+	unsupported := strings.Replace(damaged, "#index,1,", "#index,2,", 1)
+	err = NewIndex().FromFile(NewCsvDecoder(strings.NewReader(unsupported)))
+	require.Error(t, err)
+	require.False(t, errors.As(err, &rowErrs), "unsupported version is fatal")
+	require.Contains(t, err.Error(), "unsupported index version 2")
+
+	err = NewIndex().FromFile(NewCsvDecoder(iotest.TimeoutReader(strings.NewReader(buf.String()))))
+	require.Equal(t, iotest.ErrTimeout, err, "read errors are fatal")
+
+	truncated := strings.Join(rows[:2], "\n")
+	err = NewIndex().FromFile(NewCsvDecoder(strings.NewReader(truncated)))
+	require.Error(t, err)
+	require.False(t, errors.As(err, &rowErrs), "missing rows without damaged ones are fatal")
+}
+
+func TestIndex_FileProgress(t *testing.T) {
+	ind := NewIndex()
+	FillDefaultIndex(ind)
+
+	var written, read int
+	var buf bytes.Buffer
+	require.NoError(t, ind.ToFile(NewCsvEncoder(&buf), Progress(func(rows int) { written = rows })))
+	require.NoError(t, NewIndex().FromFile(NewCsvDecoder(&buf), Progress(func(rows int) { read = rows })))
+	require.Equal(t, 4, written)
+	require.Equal(t, 4, read)
+}
+
+func TestIndex_FileContext(t *testing.T) {
+	ind := NewIndex()
+	for i := 0; i < 100; i++ {
+		ind.add(fmt.Sprintf("word%d", i), []*FileStruct{{File: "file1", Position: []int{i}}})
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var buf bytes.Buffer
+	err := ind.ToFile(NewCsvEncoder(&buf), Context(ctx), Progress(func(rows int) {
+		if rows == 10 {
+			cancel()
+		}
+	}))
+	require.Equal(t, context.Canceled, err)
+
+	var full bytes.Buffer
+	require.NoError(t, ind.ToFile(NewCsvEncoder(&full)))
+	ctx, cancel = context.WithCancel(context.Background())
+	err = NewIndex().FromFile(NewCsvDecoder(&full), Context(ctx), Progress(func(rows int) {
+		if rows == 10 {
+			cancel()
+		}
+	}))
+	require.Equal(t, context.Canceled, err)
+}
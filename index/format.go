@@ -0,0 +1,300 @@
+package index
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Format names the encoding of the index file
+type Format string
+
+const (
+	FormatCSV    Format = "csv"
+	FormatJSONL  Format = "jsonl"
+	FormatGob    Format = "gob"
+	FormatBinary Format = "binary"
+)
+
+// gobMagic starts gob index files, gob streams have no header of their own
+const gobMagic = "IVXG"
+
+// Formats lists all supported formats
+var Formats = []Format{FormatCSV, FormatJSONL, FormatGob, FormatBinary}
+
+// ParseFormat returns the format with the given name
+func ParseFormat(name string) (Format, error) {
+	for _, f := range Formats {
+		if string(f) == name {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown index format %q, expected one of %v", name, Formats)
+}
+
+// NewEncoder returns the encoder writing the index in the format
+func NewEncoder(format Format, writer io.Writer) (Encoder, error) {
+	switch format {
+	case FormatCSV:
+		return NewCsvEncoder(writer), nil
+	case FormatJSONL:
+		return NewJSONLEncoder(writer), nil
+	case FormatGob:
+		return NewGobEncoder(writer), nil
+	case FormatBinary:
+		return NewBinaryEncoder(writer), nil
+	}
+	return nil, fmt.Errorf("unknown index format %q", format)
+}
+
+// NewDecoder returns the decoder reading the index in the format
+func NewDecoder(format Format, reader io.Reader) (Decoder, error) {
+	switch format {
+	case FormatCSV:
+		return NewCsvDecoder(reader), nil
+	case FormatJSONL:
+		return NewJSONLDecoder(reader), nil
+	case FormatGob:
+		return NewGobDecoder(reader), nil
+	case FormatBinary:
+		return NewBinaryDecoder(reader), nil
+	}
+	return nil, fmt.Errorf("unknown index format %q", format)
+}
+
+// DetectFormat guesses the format of the index by its first bytes without consuming them.
+// Binary and gob files start with magic bytes, every jsonl line is an object and anything else is read as csv
+func DetectFormat(reader *bufio.Reader) (Format, error) {
+	header, err := reader.Peek(len(SegmentMagic))
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	switch {
+	case IsSegment(header):
+		return FormatBinary, nil
+	case bytes.HasPrefix(header, []byte(gobMagic)):
+		return FormatGob, nil
+	case bytes.HasPrefix(header, []byte("{")):
+		return FormatJSONL, nil
+	}
+	return FormatCSV, nil
+}
+
+// record is a single row of the index, it holds the header, postings of the term or length of the document
+type record struct {
+	Header   *Header       `json:"header,omitempty"`
+	Term     string        `json:"term,omitempty"`
+	Postings []*FileStruct `json:"postings,omitempty"`
+	Doc      string        `json:"doc,omitempty"`
+	Length   int           `json:"length,omitempty"`
+}
+
+// parseRow converts the row made by ToFile to the record
+func parseRow(row []FileData) (*record, error) {
+	if len(row) < 2 {
+		return nil, fmt.Errorf("row of length %d", len(row))
+	}
+	if row[0].ToString() == headerKey {
+		str := make([]string, len(row))
+		for i := range row {
+			str[i] = row[i].ToString()
+		}
+		h, err := parseHeader(str)
+		if err != nil {
+			return nil, err
+		}
+		return &record{Header: h}, nil
+	}
+	if row[0].ToString() == docKey {
+		if len(row) < 3 {
+			return nil, fmt.Errorf("document row of length %d", len(row))
+		}
+		l, err := strconv.Atoi(row[2].ToString())
+		if err != nil {
+			return nil, err
+		}
+		return &record{Doc: row[1].ToString(), Length: l}, nil
+	}
+	r := &record{Term: unescapeTerm(row[0].ToString())}
+	if err := json.Unmarshal([]byte(row[1].ToString()), &r.Postings); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// reservedLike reports if the key is a row key or a row key with more leading '#'
+func reservedLike(key string) bool {
+	trimmed := strings.TrimLeft(key, "#")
+	if len(trimmed) == len(key) {
+		return false
+	}
+	return "#"+trimmed == docKey || "#"+trimmed == headerKey
+}
+
+// escapeTerm adds one more '#' to the terms looking like row keys, analyzers may keep '#' in the terms
+func escapeTerm(term string) string {
+	if reservedLike(term) {
+		return "#" + term
+	}
+	return term
+}
+
+// unescapeTerm returns the term written by escapeTerm, the row keys themselves are never passed to it
+func unescapeTerm(key string) string {
+	if reservedLike(key) {
+		return key[1:]
+	}
+	return key
+}
+
+// row converts the record to the row read by FromFile
+func (r *record) row(constructor func() FileData) ([]FileData, error) {
+	var str []string
+	if r.Header != nil {
+		str = r.Header.strings()
+	} else if r.Doc != "" {
+		str = []string{docKey, r.Doc, strconv.Itoa(r.Length)}
+	} else {
+		rawData, err := json.Marshal(r.Postings)
+		if err != nil {
+			return nil, err
+		}
+		str = []string{escapeTerm(r.Term), string(rawData)}
+	}
+	res := make([]FileData, len(str))
+	for i := range str {
+		res[i] = constructor()
+		res[i].FromString(str[i])
+	}
+	return res, nil
+}
+
+// encodeRecords passes every row of the channel to the function as a record.
+// The channel is drained after an error, so the writer of the channel is never blocked
+func encodeRecords(dataChannel <-chan []FileData, encode func(*record) error) error {
+	var err error
+	for row := range dataChannel {
+		if err != nil {
+			continue
+		}
+		var r *record
+		if r, err = parseRow(row); err == nil {
+			err = encode(r)
+		}
+	}
+	return err
+}
+
+// JSONLEncoder structure for writing an index as json lines
+type JSONLEncoder struct {
+	writer io.Writer
+}
+
+// NewJSONLEncoder default constructor to JSONLEncoder with writer
+func NewJSONLEncoder(writer io.Writer) *JSONLEncoder {
+	return &JSONLEncoder{writer: writer}
+}
+
+// Encode saves every row from a channel as a json object on its own line
+func (j *JSONLEncoder) Encode(dataChannel <-chan []FileData) error {
+	w := bufio.NewWriter(j.writer)
+	e := json.NewEncoder(w)
+	if err := encodeRecords(dataChannel, func(r *record) error { return e.Encode(r) }); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// JSONLDecoder structure for reading an index saved as json lines
+type JSONLDecoder struct {
+	reader io.Reader
+}
+
+// NewJSONLDecoder default constructor to JSONLDecoder with reader
+func NewJSONLDecoder(reader io.Reader) *JSONLDecoder {
+	return &JSONLDecoder{reader: reader}
+}
+
+// Decode reads json objects one by one and writes them to the channel
+func (j *JSONLDecoder) Decode(dataChannel chan<- []FileData, constructor func() FileData) error {
+	defer close(dataChannel)
+	d := json.NewDecoder(j.reader)
+	for {
+		var r record
+		if err := d.Decode(&r); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		row, err := r.row(constructor)
+		if err != nil {
+			return err
+		}
+		dataChannel <- row
+	}
+}
+
+// GobEncoder structure for writing an index as a gob stream
+type GobEncoder struct {
+	writer io.Writer
+}
+
+// NewGobEncoder default constructor to GobEncoder with writer
+func NewGobEncoder(writer io.Writer) *GobEncoder {
+	return &GobEncoder{writer: writer}
+}
+
+// Encode saves the magic bytes and then every row from a channel as a gob value
+func (g *GobEncoder) Encode(dataChannel <-chan []FileData) error {
+	w := bufio.NewWriter(g.writer)
+	_, err := w.WriteString(gobMagic)
+	if err != nil {
+		for range dataChannel {
+		}
+		return err
+	}
+	e := gob.NewEncoder(w)
+	if err := encodeRecords(dataChannel, func(r *record) error { return e.Encode(r) }); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// GobDecoder structure for reading an index saved as a gob stream
+type GobDecoder struct {
+	reader io.Reader
+}
+
+// NewGobDecoder default constructor to GobDecoder with reader
+func NewGobDecoder(reader io.Reader) *GobDecoder {
+	return &GobDecoder{reader: reader}
+}
+
+// Decode checks the magic bytes and writes every gob value to the channel
+func (g *GobDecoder) Decode(dataChannel chan<- []FileData, constructor func() FileData) error {
+	defer close(dataChannel)
+	r := bufio.NewReader(g.reader)
+	header := make([]byte, len(gobMagic))
+	if _, err := io.ReadFull(r, header); err != nil || string(header) != gobMagic {
+		return fmt.Errorf("not a gob index file")
+	}
+	d := gob.NewDecoder(r)
+	for {
+		var rec record
+		if err := d.Decode(&rec); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		row, err := rec.row(constructor)
+		if err != nil {
+			return err
+		}
+		dataChannel <- row
+	}
+}
@@ -0,0 +1,157 @@
+package index
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/polisgo2020/search-senyast4745/analysis"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormats_RoundTrip(t *testing.T) {
+	for _, format := range Formats {
+		t.Run(string(format), func(t *testing.T) {
+			ind := defaultTestIndex()
+
+			var buf bytes.Buffer
+			encoder, err := NewEncoder(format, &buf)
+			require.NoError(t, err)
+			require.NoError(t, ind.ToFile(encoder))
+
+			reader := bufio.NewReader(&buf)
+			detected, err := DetectFormat(reader)
+			require.NoError(t, err)
+			require.Equal(t, format, detected)
+
+			decoder, err := NewDecoder(detected, reader)
+			require.NoError(t, err)
+			res := NewIndex()
+			require.NoError(t, res.FromFile(decoder))
+			require.Equal(t, ind, res)
+		})
+	}
+}
+
+func TestFormats_Analyzer(t *testing.T) {
+	a, err := analysis.Get("simple")
+	require.NoError(t, err)
+	for _, format := range Formats {
+		t.Run(string(format), func(t *testing.T) {
+			ind := NewIndex()
+			ind.SetAnalyzer(a)
+			require.NoError(t, ind.AddDocument("file1", strings.NewReader("The Hello, world")))
+			require.ElementsMatch(t, []string{"hello", "the", "world"}, keys(ind.Data))
+
+			var buf bytes.Buffer
+			encoder, err := NewEncoder(format, &buf)
+			require.NoError(t, err)
+			require.NoError(t, ind.ToFile(encoder))
+			decoder, err := NewDecoder(format, &buf)
+			require.NoError(t, err)
+			res := NewIndex()
+			require.NoError(t, res.FromFile(decoder))
+			require.Equal(t, a, res.Analyzer())
+			require.Equal(t, ind, res)
+		})
+	}
+
+	csv := "#index,1,0,0,0,unknown\n"
+	require.Error(t, NewIndex().FromFile(NewCsvDecoder(strings.NewReader(csv))))
+}
+
+func TestFormats_StopListFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stop")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "stop.txt")
+	require.NoError(t, ioutil.WriteFile(file, []byte("the, a|b\n"), 0644))
+	a, err := analysis.Get("whitespace|lowercase|stop(" + file + ")")
+	require.NoError(t, err)
+	require.NoError(t, os.Remove(file))
+
+	for _, format := range Formats {
+		t.Run(string(format), func(t *testing.T) {
+			ind := NewIndex()
+			ind.SetAnalyzer(a)
+			require.NoError(t, ind.AddDocument("file1", strings.NewReader("The, hello a|b world")))
+			require.ElementsMatch(t, []string{"hello", "world"}, keys(ind.Data))
+
+			var buf bytes.Buffer
+			encoder, err := NewEncoder(format, &buf)
+			require.NoError(t, err)
+			require.NoError(t, ind.ToFile(encoder))
+			decoder, err := NewDecoder(format, &buf)
+			require.NoError(t, err)
+			res := NewIndex()
+			require.NoError(t, res.FromFile(decoder), "the removed stop list is not read")
+			require.Equal(t, a.Name, res.Analyzer().Name)
+			require.Equal(t, []string{"hello"}, res.Analyzer().Terms("the, hello"))
+		})
+	}
+}
+
+func TestFormats_ReservedTerms(t *testing.T) {
+	a, err := analysis.Get("whitespace|lowercase")
+	require.NoError(t, err)
+	for _, format := range Formats {
+		t.Run(string(format), func(t *testing.T) {
+			ind := NewIndex()
+			ind.SetAnalyzer(a)
+			require.NoError(t, ind.AddDocument("#doc", strings.NewReader("#doc #index ##doc #tag ###index")))
+			require.ElementsMatch(t, []string{"#doc", "#index", "##doc", "#tag", "###index"}, keys(ind.Data))
+
+			var buf bytes.Buffer
+			encoder, err := NewEncoder(format, &buf)
+			require.NoError(t, err)
+			require.NoError(t, ind.ToFile(encoder))
+			decoder, err := NewDecoder(format, &buf)
+			require.NoError(t, err)
+			res := NewIndex()
+			require.NoError(t, res.FromFile(decoder, Strict()))
+			require.Equal(t, ind, res)
+		})
+	}
+}
+
+func TestEscapeTerm(t *testing.T) {
+	for term, escaped := range map[string]string{
+		"#doc": "##doc", "##index": "###index", "#tag": "#tag", "doc": "doc", "#docs": "#docs", "": "",
+	} {
+		require.Equal(t, escaped, escapeTerm(term), term)
+		require.Equal(t, term, unescapeTerm(escaped), escaped)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	f, err := ParseFormat("jsonl")
+	require.NoError(t, err)
+	require.Equal(t, FormatJSONL, f)
+
+	_, err = ParseFormat("xml")
+	require.Error(t, err)
+}
+
+func TestDetectFormat_Empty(t *testing.T) {
+	f, err := DetectFormat(bufio.NewReader(&bytes.Buffer{}))
+	require.NoError(t, err)
+	require.Equal(t, FormatCSV, f)
+}
+
+func TestGobDecoder_BadMagic(t *testing.T) {
+	ch := make(chan []FileData, 10)
+	require.Error(t, NewGobDecoder(bytes.NewBufferString("hello,[]")).Decode(ch, newEmptyFileData))
+}
+
+func TestJSONLDecoder_Malformed(t *testing.T) {
+	ch := make(chan []FileData, 10)
+	require.Error(t, NewJSONLDecoder(bytes.NewBufferString(`{"term":"hello","postings":`)).Decode(ch, newEmptyFileData))
+}
+
+func newEmptyFileData() FileData {
+	return &simpleFileData{}
+}
@@ -0,0 +1,185 @@
+package index
+
+import (
+	"sort"
+	"unicode/utf8"
+)
+
+// MaxExpansions limits the number of terms a fuzzy word is expanded to, the nearest terms are kept
+const MaxExpansions = 50
+
+// FuzzyTerm is a term of the dictionary within the edit distance of the searched word
+type FuzzyTerm struct {
+	Term     string
+	Distance int
+}
+
+// levenshtein is the automaton accepting words within max edits of the word.
+// Its state is the row of edit distances between the read prefix and all prefixes of the word
+type levenshtein struct {
+	word []rune
+	max  int
+}
+
+func newLevenshtein(word string, max int) *levenshtein {
+	return &levenshtein{word: []rune(word), max: max}
+}
+
+func (l *levenshtein) start() []int {
+	row := make([]int, len(l.word)+1)
+	for i := range row {
+		row[i] = i
+	}
+	return row
+}
+
+func (l *levenshtein) step(row []int, r rune) []int {
+	next := make([]int, len(row))
+	next[0] = row[0] + 1
+	for i := 1; i < len(row); i++ {
+		cost := 1
+		if l.word[i-1] == r {
+			cost = 0
+		}
+		next[i] = minInt(row[i]+1, next[i-1]+1, row[i-1]+cost)
+	}
+	return next
+}
+
+// canMatch reports if some continuation of the read prefix is accepted
+func (l *levenshtein) canMatch(row []int) bool {
+	for _, d := range row {
+		if d <= l.max {
+			return true
+		}
+	}
+	return false
+}
+
+// match returns the distance between the term and the word if it is accepted.
+// Otherwise dead is the length in bytes of the shortest prefix of the term which no accepted word starts with,
+// or -1 if there is no such prefix
+func (l *levenshtein) match(term string) (distance int, ok bool, dead int) {
+	row := l.start()
+	for i, r := range term {
+		row = l.step(row, r)
+		if !l.canMatch(row) {
+			return 0, false, i + utf8.RuneLen(r)
+		}
+	}
+	d := row[len(row)-1]
+	return d, d <= l.max, -1
+}
+
+func minInt(a int, rest ...int) int {
+	for _, b := range rest {
+		if b < a {
+			a = b
+		}
+	}
+	return a
+}
+
+// FuzzyScan returns terms within the edit distance of the word checking every term given to fn,
+// it is used by storages which can not seek in their dictionaries
+func FuzzyScan(word string, distance int, terms func(fn func(term string))) []FuzzyTerm {
+	l := newLevenshtein(word, distance)
+	n := len(l.word)
+	var res []FuzzyTerm
+	terms(func(term string) {
+		if c := utf8.RuneCountInString(term); c < n-distance || c > n+distance {
+			return
+		}
+		if d, ok, _ := l.match(term); ok {
+			res = append(res, FuzzyTerm{Term: term, Distance: d})
+		}
+	})
+	return nearest(res)
+}
+
+// fuzzySeek returns terms within the distance of the word from the sorted dictionary,
+// seek returns the first term not less than the key. Prefixes which can not match are skipped
+func fuzzySeek(word string, distance int, seek func(key string) (string, bool, error)) ([]FuzzyTerm, error) {
+	l := newLevenshtein(word, distance)
+	var res []FuzzyTerm
+	term, ok, err := seek("")
+	for ok && err == nil {
+		d, matched, dead := l.match(term)
+		if matched {
+			res = append(res, FuzzyTerm{Term: term, Distance: d})
+		}
+		if dead < 0 {
+			// the smallest string greater than the term
+			term, ok, err = seek(term + "\x00")
+			continue
+		}
+		next, more := successor(term[:dead])
+		if !more {
+			break
+		}
+		term, ok, err = seek(next)
+	}
+	return nearest(res), err
+}
+
+// successor returns the smallest string greater than all strings starting with the prefix
+func successor(prefix string) (string, bool) {
+	for prefix != "" {
+		r, size := utf8.DecodeLastRuneInString(prefix)
+		prefix = prefix[:len(prefix)-size]
+		switch {
+		case r == utf8.MaxRune:
+			continue
+		case r+1 == 0xD800:
+			// surrogates are not valid runes
+			r = 0xDFFF
+		}
+		return prefix + string(r+1), true
+	}
+	return "", false
+}
+
+// nearest sorts the terms by distance and cuts them to MaxExpansions
+func nearest(terms []FuzzyTerm) []FuzzyTerm {
+	sort.Slice(terms, func(i, j int) bool {
+		if terms[i].Distance != terms[j].Distance {
+			return terms[i].Distance < terms[j].Distance
+		}
+		return terms[i].Term < terms[j].Term
+	})
+	if len(terms) > MaxExpansions {
+		terms = terms[:MaxExpansions]
+	}
+	return terms
+}
+
+// FuzzyTerms returns terms of the index within the edit distance of the word, the nearest first
+func (ind *Index) FuzzyTerms(word string, distance int) ([]FuzzyTerm, error) {
+	ind.m.RLock()
+	defer ind.m.RUnlock()
+	return FuzzyScan(word, distance, func(fn func(term string)) {
+		for term := range ind.Data {
+			fn(term)
+		}
+	}), nil
+}
+
+// FuzzyTerms returns terms of the segment within the edit distance of the word, the nearest first
+func (s *Segment) FuzzyTerms(word string, distance int) ([]FuzzyTerm, error) {
+	return fuzzySeek(word, distance, func(key string) (string, bool, error) {
+		var err error
+		i := sort.Search(s.terms, func(i int) bool {
+			term, e := s.term(i)
+			if e != nil {
+				err = e
+				return true
+			}
+			return term >= key
+		})
+		if err != nil || i == s.terms {
+			return "", false, err
+		}
+		term, err := s.term(i)
+		return term, err == nil, err
+	})
+}
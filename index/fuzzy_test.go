@@ -0,0 +1,121 @@
+package index
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLevenshtein_Match(t *testing.T) {
+	tests := []struct {
+		word, term string
+		max        int
+		distance   int
+		ok         bool
+		dead       int
+	}{
+		{word: "golang", term: "golang", max: 1, distance: 0, ok: true, dead: -1},
+		{word: "golang", term: "golng", max: 1, distance: 1, ok: true, dead: -1},
+		{word: "golang", term: "gulang", max: 1, distance: 1, ok: true, dead: -1},
+		{word: "golang", term: "golangs", max: 1, distance: 1, ok: true, dead: -1},
+		{word: "golang", term: "glng", max: 2, distance: 2, ok: true, dead: -1},
+		{word: "golang", term: "glng", max: 1, ok: false, dead: 3},
+		{word: "golang", term: "xyzlang", max: 1, ok: false, dead: 2},
+		{word: "ёлка", term: "елка", max: 1, distance: 1, ok: true, dead: -1},
+		{word: "ёлка", term: "жжж", max: 1, ok: false, dead: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.word+"/"+tt.term, func(t *testing.T) {
+			d, ok, dead := newLevenshtein(tt.word, tt.max).match(tt.term)
+			require.Equal(t, tt.ok, ok)
+			require.Equal(t, tt.dead, dead)
+			if ok {
+				require.Equal(t, tt.distance, d)
+			}
+		})
+	}
+}
+
+func TestSuccessor(t *testing.T) {
+	next, ok := successor("ab")
+	require.True(t, ok)
+	require.Equal(t, "ac", next)
+
+	next, ok = successor("жа")
+	require.True(t, ok)
+	require.Equal(t, "жб", next)
+
+	next, ok = successor("a\U0010FFFF")
+	require.True(t, ok)
+	require.Equal(t, "b", next)
+
+	next, ok = successor("퟿")
+	require.True(t, ok)
+	require.Equal(t, "", next)
+
+	_, ok = successor("\U0010FFFF")
+	require.False(t, ok)
+}
+
+func fuzzyTestIndex() *Index {
+	ind := NewIndex()
+	for _, term := range []string{"golang", "golan", "gola", "go", "google", "goal", "slang", "mongo", "lang",
+		"ёлка", "елка", "елки", "полка", "galang", "golangs", "xgolang"} {
+		ind.Data[term] = []*FileStruct{{File: "file1", Position: []int{0}}}
+	}
+	return ind
+}
+
+func TestIndex_FuzzyTerms(t *testing.T) {
+	ind := fuzzyTestIndex()
+	terms, err := ind.FuzzyTerms("golang", 1)
+	require.NoError(t, err)
+	require.Equal(t, []FuzzyTerm{
+		{Term: "golang", Distance: 0},
+		{Term: "galang", Distance: 1}, {Term: "golan", Distance: 1},
+		{Term: "golangs", Distance: 1}, {Term: "xgolang", Distance: 1},
+	}, terms)
+
+	terms, err = ind.FuzzyTerms("елка", 1)
+	require.NoError(t, err)
+	require.Equal(t, []FuzzyTerm{{Term: "елка", Distance: 0}, {Term: "елки", Distance: 1}, {Term: "ёлка", Distance: 1}},
+		terms)
+
+	terms, err = ind.FuzzyTerms("rust", 2)
+	require.NoError(t, err)
+	require.Empty(t, terms)
+}
+
+func TestIndex_FuzzyTermsLimit(t *testing.T) {
+	ind := NewIndex()
+	for i := 0; i < 2*MaxExpansions; i++ {
+		ind.Data[fmt.Sprintf("w%03d", i)] = []*FileStruct{{File: "file1", Position: []int{0}}}
+	}
+	terms, err := ind.FuzzyTerms("w000", 2)
+	require.NoError(t, err)
+	require.Len(t, terms, MaxExpansions)
+	require.Equal(t, FuzzyTerm{Term: "w000", Distance: 0}, terms[0])
+}
+
+func TestSegment_FuzzyTerms(t *testing.T) {
+	ind := fuzzyTestIndex()
+	path := writeTestSegment(t, ind)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	s, err := OpenSegment(path)
+	require.NoError(t, err)
+	defer s.Close()
+
+	for _, word := range []string{"golang", "gol", "lang", "елка", "ёлк", "zzz", "g"} {
+		for distance := 0; distance <= 2; distance++ {
+			want, err := ind.FuzzyTerms(word, distance)
+			require.NoError(t, err)
+			got, err := s.FuzzyTerms(word, distance)
+			require.NoError(t, err)
+			require.Equal(t, want, got, "%s~%d", word, distance)
+		}
+	}
+}
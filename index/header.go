@@ -0,0 +1,145 @@
+package index
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+)
+
+// headerKey marks the first row of the index file with its header
+const headerKey = "#index"
+
+// HeaderVersion is the version of the index rows written by ToFile
+const HeaderVersion = 1
+
+// Header describes the content of the index file, so a truncated or damaged file can be noticed when it is read.
+// Checksum does not depend on the order of rows and postings, so it is the same for every format.
+// Analyzer is the name of the analyzer which made the terms, files written before it was recorded have none
+type Header struct {
+	Version  int    `json:"version"`
+	Terms    int    `json:"terms"`
+	Docs     int    `json:"docs"`
+	Checksum uint64 `json:"checksum"`
+	Analyzer string `json:"analyzer,omitempty"`
+}
+
+func (h *Header) strings() []string {
+	str := []string{headerKey, strconv.Itoa(h.Version), strconv.Itoa(h.Terms), strconv.Itoa(h.Docs),
+		strconv.FormatUint(h.Checksum, 16)}
+	if h.Analyzer != "" {
+		str = append(str, h.Analyzer)
+	}
+	return str
+}
+
+func parseHeader(str []string) (*Header, error) {
+	if len(str) < 5 {
+		return nil, fmt.Errorf("header row of length %d", len(str))
+	}
+	var h Header
+	var err error
+	if h.Version, err = strconv.Atoi(str[1]); err != nil {
+		return nil, fmt.Errorf("header version: %w", err)
+	}
+	if h.Terms, err = strconv.Atoi(str[2]); err != nil {
+		return nil, fmt.Errorf("header term count: %w", err)
+	}
+	if h.Docs, err = strconv.Atoi(str[3]); err != nil {
+		return nil, fmt.Errorf("header document count: %w", err)
+	}
+	if h.Checksum, err = strconv.ParseUint(str[4], 16, 64); err != nil {
+		return nil, fmt.Errorf("header checksum: %w", err)
+	}
+	if len(str) > 5 {
+		h.Analyzer = str[5]
+	}
+	return &h, nil
+}
+
+// check compares the header with the content which was actually read
+func (h *Header) check(read *Header) error {
+	if h.Version != HeaderVersion {
+		return fmt.Errorf("unsupported index version %d", h.Version)
+	}
+	if h.Terms != read.Terms || h.Docs != read.Docs {
+		return fmt.Errorf("index must contain %d terms and %d documents, but %d terms and %d documents are read",
+			h.Terms, h.Docs, read.Terms, read.Docs)
+	}
+	if h.Checksum != read.Checksum {
+		return fmt.Errorf("index checksum mismatch: expected %x, got %x", h.Checksum, read.Checksum)
+	}
+	return nil
+}
+
+// add counts the record in the header
+func (h *Header) add(r *record) {
+	if r.Doc != "" {
+		h.Docs++
+		h.Checksum += docChecksum(r.Doc, r.Length)
+		return
+	}
+	h.Terms++
+	for _, p := range r.Postings {
+		h.Checksum += postingChecksum(r.Term, p)
+	}
+}
+
+func docChecksum(file string, length int) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(docKey))
+	h.Write([]byte{0})
+	h.Write([]byte(file))
+	writeInts(h, []int{length})
+	return h.Sum64()
+}
+
+func postingChecksum(term string, p *FileStruct) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(term))
+	h.Write([]byte{0})
+	h.Write([]byte(p.File))
+	writeInts(h, p.Position)
+	writeInts(h, p.Offsets)
+	return h.Sum64()
+}
+
+func writeInts(w interface{ Write([]byte) (int, error) }, values []int) {
+	var buf [binary.MaxVarintLen64]byte
+	w.Write(buf[:binary.PutUvarint(buf[:], uint64(len(values)))])
+	for _, v := range values {
+		w.Write(buf[:binary.PutVarint(buf[:], int64(v))])
+	}
+}
+
+// checkPostings reports postings with negative or unsorted positions and offsets
+func checkPostings(postings []*FileStruct) error {
+	for _, p := range postings {
+		if p.File == "" {
+			return fmt.Errorf("posting without file")
+		}
+		if err := checkSorted(p.Position, true); err != nil {
+			return fmt.Errorf("positions in %s: %w", p.File, err)
+		}
+		// several words cut from one token share its offset
+		if err := checkSorted(p.Offsets, false); err != nil {
+			return fmt.Errorf("offsets in %s: %w", p.File, err)
+		}
+		if len(p.Offsets) != 0 && len(p.Offsets) != len(p.Position) {
+			return fmt.Errorf("%d offsets for %d positions in %s", len(p.Offsets), len(p.Position), p.File)
+		}
+	}
+	return nil
+}
+
+func checkSorted(values []int, unique bool) error {
+	for i, v := range values {
+		if v < 0 {
+			return fmt.Errorf("negative value %d", v)
+		}
+		if i > 0 && (v < values[i-1] || unique && v == values[i-1]) {
+			return fmt.Errorf("unsorted values %d and %d", values[i-1], v)
+		}
+	}
+	return nil
+}
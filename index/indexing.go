@@ -1,37 +1,204 @@
 package index
 
 import (
-	"bufio"
 	"io"
 	"sync"
 
-	"github.com/polisgo2020/search-senyast4745/util"
+	"github.com/polisgo2020/search-senyast4745/analysis"
 )
 
-// FileStruct describes the frequency structure of the token in the file
+// FileStruct describes the frequency structure of the token in the file.
+// Offsets contains byte offset in the source file for every position
 type FileStruct struct {
 	File     string `json:"file"`
 	Position []int  `json:"position"`
+	Offsets  []int  `json:"offsets,omitempty"`
 }
 
 type fileWordMap map[string]*FileStruct
 
-// Index describes search inverted index
+// length returns the number of tokens of the file, typed terms share positions with their tokens
+func (m fileWordMap) length() int {
+	var res int
+	for _, p := range m {
+		if n := len(p.Position); n > 0 && p.Position[n-1]+1 > res {
+			res = p.Position[n-1] + 1
+		}
+	}
+	return res
+}
+
+// Corpus describes statistics of the whole indexed collection
+type Corpus struct {
+	Documents int
+	Tokens    int
+}
+
+// Index describes search inverted index.
+//
+// Index is safe for concurrent use: readers may search while a writer changes it.
+// Postings are never changed in place: writers either append past the end of the slice
+// or replace it with a new one, so Snapshot can share the capped slices with the copy of the index.
 type Index struct {
-	Data        map[string][]*FileStruct
+	Data map[string][]*FileStruct
+	// Docs contains length of every indexed file in tokens
+	Docs        map[string]int
+	tokens      int
+	corpus      *Corpus
+	analyzer    *analysis.Analyzer
+	m           *sync.RWMutex
 	dataChannel chan fileWordMap
+	// changed is called with the files changed by AddDocument, RemoveFiles and Replace
+	changed func(files []string)
 }
 
+// NewIndex creates an empty index using the default analyzer
 func NewIndex() *Index {
-	return &Index{Data: make(map[string][]*FileStruct)}
+	return &Index{Data: make(map[string][]*FileStruct), Docs: make(map[string]int), analyzer: analysis.Default(),
+		m: &sync.RWMutex{}}
+}
+
+// SetAnalyzer sets the analyzer of the documents, it has to be set before the documents are added
+func (ind *Index) SetAnalyzer(a *analysis.Analyzer) {
+	ind.m.Lock()
+	defer ind.m.Unlock()
+	ind.analyzer = a
+}
+
+// OnChange sets the function called with the files changed by AddDocument, RemoveFiles and Replace
+// after the change is applied, e.g. to drop cached texts of the files
+func (ind *Index) OnChange(fn func(files []string)) {
+	ind.m.Lock()
+	defer ind.m.Unlock()
+	ind.changed = fn
+}
+
+// notify calls the change function without the lock held
+func (ind *Index) notify(files []string) {
+	ind.m.RLock()
+	fn := ind.changed
+	ind.m.RUnlock()
+	if fn != nil && len(files) > 0 {
+		fn(files)
+	}
+}
+
+// Analyzer returns the analyzer which made the terms of the index, queries have to be analyzed with it
+func (ind *Index) Analyzer() *analysis.Analyzer {
+	ind.m.RLock()
+	defer ind.m.RUnlock()
+	return ind.analyzer
 }
 
 func (ind *Index) add(word string, data []*FileStruct) {
+	ind.m.Lock()
+	defer ind.m.Unlock()
 	ind.Data[word] = data
 }
 
+func (ind *Index) addDoc(file string, length int) {
+	ind.m.Lock()
+	defer ind.m.Unlock()
+	ind.addDocLocked(file, length)
+}
+
+func (ind *Index) addDocLocked(file string, length int) {
+	ind.Docs[file] += length
+	ind.tokens += length
+}
+
+// SetCorpus overrides collection statistics for indexes which contain only a part of the collection
+func (ind *Index) SetCorpus(c Corpus) {
+	ind.m.Lock()
+	defer ind.m.Unlock()
+	ind.corpus = &c
+}
+
+// Corpus returns statistics of the indexed collection
+func (ind *Index) Corpus() Corpus {
+	ind.m.RLock()
+	defer ind.m.RUnlock()
+	return ind.corpusLocked()
+}
+
+func (ind *Index) corpusLocked() Corpus {
+	if ind.corpus != nil {
+		return *ind.corpus
+	}
+	return Corpus{Documents: len(ind.Docs), Tokens: ind.tokens}
+}
+
+// DocFreq returns number of files containing the word
+func (ind *Index) DocFreq(word string) int {
+	ind.m.RLock()
+	defer ind.m.RUnlock()
+	return len(ind.Data[word])
+}
+
+// DocLength returns length of the file in tokens
+func (ind *Index) DocLength(file string) (int, bool) {
+	ind.m.RLock()
+	defer ind.m.RUnlock()
+	l, ok := ind.Docs[file]
+	return l, ok
+}
+
+// Postings returns postings of the word, the returned slice must not be changed
+func (ind *Index) Postings(word string) []*FileStruct {
+	ind.m.RLock()
+	defer ind.m.RUnlock()
+	return ind.Data[word]
+}
+
+// DocLengths returns lengths of all documents of the index
+func (ind *Index) DocLengths() map[string]int {
+	ind.m.RLock()
+	defer ind.m.RUnlock()
+	res := make(map[string]int, len(ind.Docs))
+	for file, l := range ind.Docs {
+		res[file] = l
+	}
+	return res
+}
+
+// AddDocLengths adds the documents missing in the part of the index, so queries excluding words
+// are evaluated over all documents, not only over those holding the words
+func (ind *Index) AddDocLengths(docs map[string]int) {
+	ind.m.Lock()
+	defer ind.m.Unlock()
+	for file, l := range docs {
+		if _, ok := ind.Docs[file]; !ok {
+			ind.addDocLocked(file, l)
+		}
+	}
+}
+
+// Files returns names of all files known to the index
+func (ind *Index) Files() []string {
+	ind.m.RLock()
+	defer ind.m.RUnlock()
+	files := make(map[string]bool, len(ind.Docs))
+	for file := range ind.Docs {
+		files[file] = true
+	}
+	for _, postings := range ind.Data {
+		for _, p := range postings {
+			files[p.File] = true
+		}
+	}
+	res := make([]string, 0, len(files))
+	for file := range files {
+		res = append(res, file)
+	}
+	return res
+}
+
+// dataChannelSize limits postings of the files waiting to be applied,
+// readers block when the index is slower than them
+const dataChannelSize = 64
+
 func (ind *Index) OpenApplyAndListenChannel(consumer func(wg *sync.WaitGroup)) {
-	ind.dataChannel = make(chan fileWordMap, 1000)
+	ind.dataChannel = make(chan fileWordMap, dataChannelSize)
 	var wg sync.WaitGroup
 	consumer(&wg)
 
@@ -41,32 +208,179 @@ func (ind *Index) OpenApplyAndListenChannel(consumer func(wg *sync.WaitGroup)) {
 	}(&wg, ind.dataChannel)
 
 	for data := range ind.dataChannel {
-		for j := range data {
-			if ind.Data[j] == nil {
-				ind.Data[j] = []*FileStruct{data[j]}
-			} else {
-				ind.Data[j] = append(ind.Data[j], data[j])
-			}
-		}
+		ind.m.Lock()
+		ind.apply(data)
+		ind.m.Unlock()
+	}
+}
+
+// apply adds postings of a single file to the index, the caller must hold the write lock
+func (ind *Index) apply(data fileWordMap) {
+	var file string
+	for j := range data {
+		ind.Data[j] = append(ind.Data[j], data[j])
+		file = data[j].File
+	}
+	if file != "" {
+		ind.addDocLocked(file, data.length())
 	}
 }
 
-// MapAndCleanWords creates an inverted index for a given word slice from a given file
-func (ind *Index) MapAndCleanWords(reader io.Reader, fn string) {
-	sc := bufio.NewScanner(reader)
-	sc.Split(bufio.ScanWords)
+// MapAndCleanWords creates an inverted index for a given word slice from a given file.
+// Words read before an error are still indexed, the error is returned
+func (ind *Index) MapAndCleanWords(reader io.Reader, fn string) error {
+	data, err := mapWords(ind.Analyzer(), reader, fn)
+	ind.dataChannel <- data
+	return err
+}
+
+// AddDocument indexes the document replacing its previous postings
+func (ind *Index) AddDocument(file string, reader io.Reader) error {
+	data, err := mapWords(ind.Analyzer(), reader, file)
+	if err != nil {
+		return err
+	}
+	ind.m.Lock()
+	ind.removeFiles(file)
+	ind.apply(data)
+	ind.m.Unlock()
+	ind.notify([]string{file})
+	return nil
+}
 
+// RemoveDocument deletes all postings of the document
+func (ind *Index) RemoveDocument(file string) {
+	ind.RemoveFiles(file)
+}
+
+// mapWords splits text of the file into terms with the analyzer and collects their positions
+func mapWords(a *analysis.Analyzer, reader io.Reader, fn string) (fileWordMap, error) {
 	var position int
 	data := make(fileWordMap)
-	for sc.Scan() {
-		util.CleanUserInput(sc.Text(), func(input string) {
-			if data[input] == nil {
-				data[input] = &FileStruct{File: fn, Position: []int{position}}
-			} else {
-				data[input].Position = append(data[input].Position, position)
+	add := func(term string, offset int) {
+		if data[term] == nil {
+			data[term] = &FileStruct{File: fn, Position: []int{position}, Offsets: []int{offset}}
+		} else {
+			data[term].Position = append(data[term].Position, position)
+			data[term].Offsets = append(data[term].Offsets, offset)
+		}
+	}
+	err := a.Analyze(reader, func(t analysis.Token) {
+		add(t.Term, t.Offset)
+		// numbers, emails, urls and identifiers are also indexed with their type at the same position
+		if t.Type != analysis.Word {
+			add(analysis.TypedTerm(t.Type, t.Term), t.Offset)
+		}
+		position++
+	})
+	return data, err
+}
+
+// RemoveFiles deletes postings and lengths of the files from the index
+func (ind *Index) RemoveFiles(files ...string) {
+	ind.m.Lock()
+	ind.removeFiles(files...)
+	ind.m.Unlock()
+	ind.notify(files)
+}
+
+func (ind *Index) removeFiles(files ...string) {
+	if len(files) == 0 {
+		return
+	}
+	removed := make(map[string]bool, len(files))
+	for _, f := range files {
+		removed[f] = true
+		ind.tokens -= ind.Docs[f]
+		delete(ind.Docs, f)
+	}
+	for word, postings := range ind.Data {
+		var kept []*FileStruct
+		for i, p := range postings {
+			if removed[p.File] {
+				if kept == nil {
+					kept = make([]*FileStruct, i, len(postings))
+					copy(kept, postings[:i])
+				}
+			} else if kept != nil {
+				kept = append(kept, p)
 			}
-			position++
-		})
+		}
+		switch {
+		case kept == nil:
+		case len(kept) == 0:
+			delete(ind.Data, word)
+		default:
+			ind.Data[word] = kept
+		}
 	}
-	ind.dataChannel <- data
+}
+
+// Merge adds postings and lengths of the files from the other index
+func (ind *Index) Merge(other *Index) {
+	ind.Replace(nil, other)
+}
+
+// Replace atomically removes the files and adds postings and lengths of the files from the other index
+func (ind *Index) Replace(files []string, other *Index) {
+	other.m.RLock()
+	ind.m.Lock()
+	ind.removeFiles(files...)
+	for word, postings := range other.Data {
+		ind.Data[word] = append(ind.Data[word], postings...)
+	}
+	changed := append([]string(nil), files...)
+	for file, l := range other.Docs {
+		ind.addDocLocked(file, l)
+		changed = append(changed, file)
+	}
+	ind.m.Unlock()
+	other.m.RUnlock()
+	ind.notify(changed)
+}
+
+// Snapshot returns a copy of the index with the postings of the given words
+// and lengths of the files containing them, the whole index is copied if no words are given.
+// The copy keeps statistics of the whole collection and does not change when the index changes
+func (ind *Index) Snapshot(words ...string) *Index {
+	ind.m.RLock()
+	defer ind.m.RUnlock()
+
+	s := NewIndex()
+	s.analyzer = ind.analyzer
+	if len(words) == 0 {
+		for word, postings := range ind.Data {
+			s.Data[word] = postings[:len(postings):len(postings)]
+		}
+		for file, l := range ind.Docs {
+			s.Docs[file] = l
+		}
+		s.tokens = ind.tokens
+		if ind.corpus != nil {
+			c := *ind.corpus
+			s.corpus = &c
+		}
+		return s
+	}
+
+	for _, word := range words {
+		postings, ok := ind.Data[word]
+		if !ok {
+			continue
+		}
+		s.Data[word] = postings[:len(postings):len(postings)]
+		for _, p := range postings {
+			if l, ok := ind.Docs[p.File]; ok {
+				s.Docs[p.File] = l
+			}
+		}
+	}
+	c := ind.corpusLocked()
+	s.corpus = &c
+	return s
+}
+
+// Clone returns a copy of the whole index which can be changed without affecting the original one
+func (ind *Index) Clone() *Index {
+	return ind.Snapshot()
 }
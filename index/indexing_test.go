@@ -2,9 +2,11 @@ package index
 
 import (
 	"bytes"
+	"fmt"
 	"sync"
 	"testing"
 
+	"github.com/polisgo2020/search-senyast4745/analysis"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
@@ -46,13 +48,13 @@ func setupDataToFileMap(filename string) fileWordMap {
 
 	fileWordMap := make(fileWordMap)
 	fileWordMap["hello"] = &FileStruct{
-		File: filename, Position: []int{0, 2},
+		File: filename, Position: []int{0, 2}, Offsets: []int{0, 12},
 	}
 	fileWordMap["world"] = &FileStruct{
-		File: filename, Position: []int{1},
+		File: filename, Position: []int{1}, Offsets: []int{6},
 	}
 	fileWordMap["golang"] = &FileStruct{
-		File: filename, Position: []int{3, 4},
+		File: filename, Position: []int{3, 4}, Offsets: []int{18, 25},
 	}
 	return fileWordMap
 }
@@ -85,6 +87,7 @@ func (i *indexTestSuite) TestIndex_MapAndCleanWords_WithStopWords() {
 func (i *indexTestSuite) TestIndex_MapAndCleanWords_WithNewData() {
 	i.input += " world"
 	i.fileWorldMaps[0]["world"].Position = append(i.fileWorldMaps[0]["world"].Position, 5)
+	i.fileWorldMaps[0]["world"].Offsets = append(i.fileWorldMaps[0]["world"].Offsets, 32)
 	i.index.MapAndCleanWords(bytes.NewBufferString(i.input), i.fileNames[0])
 	actual := <-i.index.dataChannel
 	require.Equal(i.T(), i.fileWorldMaps[0], actual)
@@ -93,13 +96,22 @@ func (i *indexTestSuite) TestIndex_MapAndCleanWords_WithNewData() {
 func (i *indexTestSuite) TestIndex_MapAndCleanWords_WithNewData2() {
 	i.input += " test"
 	i.fileWorldMaps[0]["test"] = &FileStruct{
-		File: i.fileNames[0], Position: []int{5},
+		File: i.fileNames[0], Position: []int{5}, Offsets: []int{32},
 	}
 	i.index.MapAndCleanWords(bytes.NewBufferString(i.input), i.fileNames[0])
 	actual := <-i.index.dataChannel
 	require.Equal(i.T(), i.fileWorldMaps[0], actual)
 }
 
+func (i *indexTestSuite) TestIndex_MapAndCleanWords_Offsets() {
+	i.index.MapAndCleanWords(bytes.NewBufferString("  (Hello),\n\tмир the  world!"), i.fileNames[0])
+	actual := <-i.index.dataChannel
+	require.Equal(i.T(), []int{3}, actual["hello"].Offsets)
+	require.Equal(i.T(), []int{12}, actual["мир"].Offsets)
+	require.Equal(i.T(), []int{24}, actual["world"].Offsets)
+	require.Equal(i.T(), []int{2}, actual["world"].Position)
+}
+
 func (i *indexTestSuite) TestIndex_SimpleOpenApplyAndListenChannel() {
 	i.index.OpenApplyAndListenChannel(func(wg *sync.WaitGroup) {
 		i.called = true
@@ -133,6 +145,156 @@ func (i *indexTestSuite) TestIndex_OpenApplyAndListenChannel2() {
 	require.NotEmpty(i.T(), i.index.Data, "data must not be written")
 	require.Equal(i.T(), len(i.fileWorldMaps[0]), len(i.index.Data))
 	require.Equal(i.T(), 2, len(i.index.Data["hello"]))
+	require.Equal(i.T(), map[string]int{"file1": 5, "file2": 5}, i.index.Docs)
+	require.Equal(i.T(), Corpus{Documents: 2, Tokens: 10}, i.index.Corpus())
+}
+
+func TestIndex_RemoveFiles(t *testing.T) {
+	ind := defaultTestIndex()
+
+	ind.RemoveFiles("file2", "file3")
+	require.Equal(t, map[string][]*FileStruct{
+		"hello": {{File: "file1", Position: []int{0, 5}}},
+		"world": {{File: "file1", Position: []int{3}, Offsets: []int{17}}},
+	}, ind.Data)
+	require.Equal(t, map[string]int{"file1": 6}, ind.Docs)
+	require.Equal(t, Corpus{Documents: 1, Tokens: 6}, ind.Corpus())
+
+	ind.RemoveFiles()
+	require.Len(t, ind.Data, 2)
+}
+
+func TestIndex_Merge(t *testing.T) {
+	ind := NewIndex()
+	FillDefaultIndex(ind)
+	ind.RemoveFiles("file3")
+
+	other := NewIndex()
+	FillDefaultIndex(other)
+	other.RemoveFiles("file1", "file2")
+	other.addDoc("file3", 7)
+
+	ind.Merge(other)
+	expected := NewIndex()
+	FillDefaultIndex(expected)
+	expected.addDoc("file3", 7)
+	require.Equal(t, expected, ind)
+}
+
+func TestIndex_AddDocument(t *testing.T) {
+	ind := NewIndex()
+	require.NoError(t, ind.AddDocument("file1", bytes.NewBufferString("hello world")))
+	require.NoError(t, ind.AddDocument("file2", bytes.NewBufferString("hello golang")))
+	require.Equal(t, map[string]int{"file1": 2, "file2": 2}, ind.Docs)
+
+	require.NoError(t, ind.AddDocument("file1", bytes.NewBufferString("golang golang")))
+	require.Equal(t, []*FileStruct{{File: "file2", Position: []int{0}, Offsets: []int{0}}}, ind.Data["hello"])
+	require.Len(t, ind.Data["golang"], 2)
+	_, ok := ind.Data["world"]
+	require.False(t, ok, "old postings must be removed")
+
+	ind.RemoveDocument("file2")
+	require.Equal(t, map[string][]*FileStruct{
+		"golang": {{File: "file1", Position: []int{0, 1}, Offsets: []int{0, 7}}},
+	}, ind.Data)
+	require.Equal(t, map[string]int{"file1": 2}, ind.Docs)
+}
+
+func TestIndex_TypedTerms(t *testing.T) {
+	a, err := analysis.Get("unicode")
+	require.NoError(t, err)
+	ind := NewIndex()
+	ind.SetAnalyzer(a)
+	require.NoError(t, ind.AddDocument("file1", bytes.NewBufferString("Mail me@example.com in 2020")))
+	require.Equal(t, map[string]int{"file1": 3}, ind.Docs, "typed terms are not counted")
+	require.Equal(t, []*FileStruct{{File: "file1", Position: []int{2}, Offsets: []int{23}}}, ind.Data["2020"])
+	require.Equal(t, ind.Data["2020"], ind.Data["number:2020"])
+	require.Equal(t, []*FileStruct{{File: "file1", Position: []int{1}, Offsets: []int{5}}},
+		ind.Data["email:me@example.com"])
+	_, ok := ind.Data["word:mail"]
+	require.False(t, ok, "words are not typed")
+}
+
+func TestIndex_Clone(t *testing.T) {
+	ind := NewIndex()
+	FillDefaultIndex(ind)
+	ind.addDoc("file1", 6)
+
+	c := ind.Clone()
+	require.Equal(t, ind, c)
+
+	c.RemoveFiles("file1")
+	require.NoError(t, c.AddDocument("file4", bytes.NewBufferString("hello")))
+	expected := NewIndex()
+	FillDefaultIndex(expected)
+	expected.addDoc("file1", 6)
+	require.Equal(t, expected, ind, "original index must not be changed")
+}
+
+func TestIndex_Snapshot(t *testing.T) {
+	ind := NewIndex()
+	require.NoError(t, ind.AddDocument("file1", bytes.NewBufferString("hello world")))
+	require.NoError(t, ind.AddDocument("file2", bytes.NewBufferString("hello golang golang")))
+
+	s := ind.Snapshot("golang")
+	require.Equal(t, []string{"golang"}, keys(s.Data))
+	require.Equal(t, map[string]int{"file2": 3}, s.Docs)
+	require.Equal(t, Corpus{Documents: 2, Tokens: 5}, s.Corpus())
+
+	ind.RemoveDocument("file2")
+	require.Len(t, s.Postings("golang"), 1, "snapshot must not see later changes")
+	require.Empty(t, ind.Postings("golang"))
+}
+
+func TestIndex_Concurrent(t *testing.T) {
+	ind := NewIndex()
+	require.NoError(t, ind.AddDocument("file0", bytes.NewBufferString("hello world")))
+
+	// require stops only the goroutine it is called in, the errors are checked after the wait
+	errs := make(chan error, 4*2*50)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				file := fmt.Sprintf("file%d-%d", i, j)
+				if err := ind.AddDocument(file, bytes.NewBufferString("hello golang world")); err != nil {
+					errs <- err
+					continue
+				}
+				if j%2 == 0 {
+					ind.RemoveFiles(file)
+				}
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				ind.Search([]string{"hello", "world"})
+				ind.Snapshot("golang").SearchPage([]string{"golang"}, NewBM25Scorer(), 0, 10)
+				if err := ind.ToFile(NewCsvEncoder(&bytes.Buffer{})); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	require.Len(t, ind.Postings("golang"), 100)
+	require.Equal(t, Corpus{Documents: 101, Tokens: 302}, ind.Corpus())
+}
+
+func keys(m map[string][]*FileStruct) []string {
+	res := make([]string, 0, len(m))
+	for k := range m {
+		res = append(res, k)
+	}
+	return res
 }
 
 func isClosed(ch <-chan fileWordMap) bool {
@@ -146,3 +308,20 @@ func isClosed(ch <-chan fileWordMap) bool {
 	}
 	return false
 }
+
+func TestIndex_OnChange(t *testing.T) {
+	ind := NewIndex()
+	var changed [][]string
+	ind.OnChange(func(files []string) {
+		changed = append(changed, files)
+	})
+
+	require.NoError(t, ind.AddDocument("file1", bytes.NewBufferString("hello world")))
+	ind.RemoveDocument("file1")
+	ind.RemoveFiles()
+
+	other := NewIndex()
+	require.NoError(t, other.AddDocument("file2", bytes.NewBufferString("hello golang")))
+	ind.Replace([]string{"file3"}, other)
+	require.Equal(t, [][]string{{"file1"}, {"file1"}, {"file3", "file2"}}, changed)
+}
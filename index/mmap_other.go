@@ -0,0 +1,18 @@
+//go:build windows || plan9 || js
+// +build windows plan9 js
+
+package index
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+// mmapFile reads the whole file into memory where mapping is not supported
+func mmapFile(f *os.File) ([]byte, func() error, error) {
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return nil }, nil
+}
@@ -0,0 +1,25 @@
+//go:build !windows && !plan9 && !js
+// +build !windows,!plan9,!js
+
+package index
+
+import (
+	"os"
+	"syscall"
+)
+
+// mmapFile maps the whole file into memory for reading
+func mmapFile(f *os.File) ([]byte, func() error, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	if fi.Size() == 0 {
+		return nil, func() error { return nil }, nil
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(fi.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
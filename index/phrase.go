@@ -0,0 +1,156 @@
+package index
+
+import "sort"
+
+// Phrase returns start positions of the consecutive occurrences of the words for every file
+// containing the whole phrase. Positions are the token ordinals stored in FileStruct
+func (ind *Index) Phrase(words []string) map[string][]int {
+	ind.m.RLock()
+	defer ind.m.RUnlock()
+	return ind.phrase(words)
+}
+
+func (ind *Index) phrase(words []string) map[string][]int {
+	res := make(map[string][]int)
+	if len(words) == 0 {
+		return res
+	}
+	for _, fileStr := range ind.Data[words[0]] {
+		res[fileStr.File] = append([]int(nil), fileStr.Position...)
+	}
+	for shift := 1; shift < len(words) && len(res) > 0; shift++ {
+		positions := make(map[string][]int)
+		for _, fileStr := range ind.Data[words[shift]] {
+			positions[fileStr.File] = fileStr.Position
+		}
+		for file, starts := range res {
+			var kept []int
+			for _, start := range starts {
+				if containsInt(positions[file], start+shift) {
+					kept = append(kept, start)
+				}
+			}
+			if len(kept) == 0 {
+				delete(res, file)
+			} else {
+				res[file] = kept
+			}
+		}
+	}
+	return res
+}
+
+// FilterPhrases keeps in data only the files containing all the phrases
+// and stores phrase start positions in Data.Offsets
+func (ind *Index) FilterPhrases(data map[string]*Data, phrases [][]string) map[string]*Data {
+	for _, phrase := range phrases {
+		if len(phrase) == 0 {
+			continue
+		}
+		matched := ind.Phrase(phrase)
+		for file, d := range data {
+			starts, ok := matched[file]
+			if !ok {
+				delete(data, file)
+				continue
+			}
+			d.Offsets = append(d.Offsets, starts...)
+		}
+	}
+	for _, d := range data {
+		sort.Ints(d.Offsets)
+	}
+	return data
+}
+
+func containsInt(sorted []int, key int) bool {
+	i := sort.SearchInts(sorted, key)
+	return i < len(sorted) && sorted[i] == key
+}
+
+// Near returns files where occurrences of the left and right phrases are within distance tokens.
+// The distance is counted from the end of the first phrase to the start of the second one.
+// If ordered is true the right phrase must follow the left one.
+// Start positions of the matched occurrences of both phrases are returned
+func (ind *Index) Near(left, right []string, distance int, ordered bool) map[string][]int {
+	res := make(map[string][]int)
+	if len(left) == 0 || len(right) == 0 {
+		return res
+	}
+	ind.m.RLock()
+	leftStarts := ind.phrase(left)
+	rightStarts := ind.phrase(right)
+	ind.m.RUnlock()
+	for file, ls := range leftStarts {
+		rs, ok := rightStarts[file]
+		if !ok {
+			continue
+		}
+		var offsets []int
+		if !ordered && len(left) == 1 && len(right) == 1 && left[0] != right[0] {
+			// different words share a position if one is the typed term of the other,
+			// such occurrences are not near each other, so the closest other position is enough
+			for _, l := range ls {
+				if diff, ok := minOtherDiff(rs, l); ok && diff <= distance {
+					offsets = append(offsets, l)
+				}
+			}
+			for _, r := range rs {
+				if diff, ok := minOtherDiff(ls, r); ok && diff <= distance {
+					offsets = append(offsets, r)
+				}
+			}
+		} else {
+			offsets = followWithin(ls, len(left), rs, distance)
+			if !ordered {
+				offsets = append(offsets, followWithin(rs, len(right), ls, distance)...)
+			}
+		}
+		if len(offsets) > 0 {
+			res[file] = uniqueInts(offsets)
+		}
+	}
+	return res
+}
+
+// minOtherDiff returns the distance from the key to the closest of the sorted positions other than the key itself
+func minOtherDiff(sorted []int, key int) (int, bool) {
+	i := sort.SearchInts(sorted, key)
+	diff, ok := 0, false
+	if i > 0 {
+		diff, ok = key-sorted[i-1], true
+	}
+	if i < len(sorted) && sorted[i] == key {
+		i++
+	}
+	if i < len(sorted) && (!ok || sorted[i]-key < diff) {
+		diff, ok = sorted[i]-key, true
+	}
+	return diff, ok
+}
+
+// followWithin returns starts of the first and second occurrences
+// where the second one starts at most distance tokens after the end of the first one
+func followWithin(first []int, firstLen int, second []int, distance int) []int {
+	var res []int
+	for _, f := range first {
+		end := f + firstLen - 1
+		i := sort.SearchInts(second, end+1)
+		if i < len(second) && second[i]-end <= distance {
+			res = append(res, f, second[i])
+		}
+	}
+	return res
+}
+
+// uniqueInts sorts the slice and removes repeated values
+func uniqueInts(arr []int) []int {
+	sort.Ints(arr)
+	res := arr[:0]
+	for i := range arr {
+		if i == 0 || arr[i] != arr[i-1] {
+			res = append(res, arr[i])
+		}
+	}
+	return res
+}
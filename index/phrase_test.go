@@ -0,0 +1,97 @@
+package index
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/polisgo2020/search-senyast4745/analysis"
+	"github.com/stretchr/testify/require"
+)
+
+func phraseTestIndex() *Index {
+	ind := NewIndex()
+	ind.Data["invert"] = []*FileStruct{
+		{File: "file1", Position: []int{1, 4, 7}},
+		{File: "file2", Position: []int{3}},
+	}
+	ind.Data["index"] = []*FileStruct{
+		{File: "file1", Position: []int{2, 8}},
+		{File: "file2", Position: []int{0}},
+	}
+	ind.Data["search"] = []*FileStruct{
+		{File: "file1", Position: []int{9}},
+		{File: "file2", Position: []int{1}},
+	}
+	return ind
+}
+
+func TestIndex_Phrase(t *testing.T) {
+	ind := phraseTestIndex()
+
+	require.Equal(t, map[string][]int{"file1": {1, 7}}, ind.Phrase([]string{"invert", "index"}))
+	require.Equal(t, map[string][]int{"file1": {7}}, ind.Phrase([]string{"invert", "index", "search"}))
+	require.Equal(t, map[string][]int{"file1": {8}, "file2": {0}}, ind.Phrase([]string{"index", "search"}))
+	require.Equal(t, map[string][]int{"file1": {1, 4, 7}, "file2": {3}}, ind.Phrase([]string{"invert"}))
+	require.Empty(t, ind.Phrase([]string{"search", "invert"}))
+	require.Empty(t, ind.Phrase([]string{"unknown"}))
+	require.Empty(t, ind.Phrase(nil))
+}
+
+func TestIndex_FilterPhrases(t *testing.T) {
+	ind := phraseTestIndex()
+
+	data := ind.FilterPhrases(ind.Search([]string{"invert", "index", "search"}),
+		[][]string{{"invert", "index"}, {}})
+	require.Len(t, data, 1)
+	require.Equal(t, []int{1, 7}, data["file1"].Offsets)
+
+	data = ind.FilterPhrases(ind.Search([]string{"invert", "index", "search"}),
+		[][]string{{"index", "search"}, {"invert", "index"}})
+	require.Len(t, data, 1)
+	require.Equal(t, []int{1, 7, 8}, data["file1"].Offsets)
+
+	data = ind.FilterPhrases(ind.Search([]string{"search", "invert"}), [][]string{{"search", "invert"}})
+	require.Empty(t, data)
+}
+
+func TestIndex_Near(t *testing.T) {
+	ind := phraseTestIndex()
+
+	require.Equal(t, map[string][]int{"file1": {1, 2, 7, 8}}, ind.Near([]string{"invert"}, []string{"index"}, 1, true))
+	require.Equal(t, map[string][]int{"file1": {1, 2, 4, 7, 8}, "file2": {0, 3}},
+		ind.Near([]string{"invert"}, []string{"index"}, 3, false))
+	require.Equal(t, map[string][]int{"file1": {1, 2, 7, 8}},
+		ind.Near([]string{"invert"}, []string{"index"}, 3, true))
+	require.Equal(t, map[string][]int{"file1": {2, 4}, "file2": {0, 3}},
+		ind.Near([]string{"index"}, []string{"invert"}, 3, true))
+	require.Empty(t, ind.Near([]string{"search"}, []string{"invert"}, 1, true))
+	require.Empty(t, ind.Near(nil, []string{"invert"}, 1, false))
+}
+
+func TestIndex_NearTypedTerms(t *testing.T) {
+	a, err := analysis.Get("unicode")
+	require.NoError(t, err)
+	ind := NewIndex()
+	ind.SetAnalyzer(a)
+	require.NoError(t, ind.AddDocument("file1", bytes.NewBufferString("released golang 2020")))
+	require.NoError(t, ind.AddDocument("file2", bytes.NewBufferString("2020 golang 2020")))
+
+	for _, ordered := range []bool{false, true} {
+		res := ind.Near([]string{"2020"}, []string{"number:2020"}, 1, ordered)
+		require.Empty(t, res, "the typed term at the same position is not near")
+		res = ind.Near([]string{"2020"}, []string{"number:2020"}, 2, ordered)
+		require.Equal(t, map[string][]int{"file2": {0, 2}}, res)
+	}
+	require.Equal(t, map[string][]int{"file1": {1, 2}, "file2": {0, 1, 2}},
+		ind.Near([]string{"golang"}, []string{"number:2020"}, 1, false))
+}
+
+func TestIndex_NearPhrase(t *testing.T) {
+	ind := phraseTestIndex()
+
+	require.Equal(t, map[string][]int{"file1": {7, 9}},
+		ind.Near([]string{"invert", "index"}, []string{"search"}, 1, true))
+	require.Equal(t, map[string][]int{"file1": {1, 4, 7}},
+		ind.Near([]string{"invert"}, []string{"invert"}, 3, false))
+	require.Empty(t, ind.Near([]string{"invert", "index"}, []string{"search"}, 1, true)["file2"])
+}
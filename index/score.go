@@ -0,0 +1,90 @@
+package index
+
+import (
+	"fmt"
+	"math"
+)
+
+// Scorer calculates relevance of the found file for the search words
+type Scorer interface {
+	Score(ind *Index, file string, d *Data) float64
+}
+
+// NewScorer returns scorer by its name: proximity, bm25 or tfidf
+func NewScorer(name string) (Scorer, error) {
+	switch name {
+	case "", "proximity":
+		return ProximityScorer{}, nil
+	case "bm25":
+		return NewBM25Scorer(), nil
+	case "tfidf":
+		return TFIDFScorer{}, nil
+	}
+	return nil, fmt.Errorf("unknown scorer %q", name)
+}
+
+// UsesCorpus tells if the scorer needs statistics of the whole collection
+func UsesCorpus(s Scorer) bool {
+	_, ok := s.(ProximityScorer)
+	return !ok
+}
+
+// ProximityScorer combines the number of matched words and their proximity.
+// The number of matched words always dominates, the proximity only orders files
+// with the same number of matched words
+type ProximityScorer struct{}
+
+func (ProximityScorer) Score(_ *Index, _ string, d *Data) float64 {
+	return d.matched() + 1/float64(d.Weight+1)
+}
+
+// BM25Scorer implements Okapi BM25 ranking function
+type BM25Scorer struct {
+	K1 float64
+	B  float64
+}
+
+// NewBM25Scorer returns BM25Scorer with commonly used parameters
+func NewBM25Scorer() *BM25Scorer {
+	return &BM25Scorer{K1: 1.2, B: 0.75}
+}
+
+func (s *BM25Scorer) Score(ind *Index, file string, d *Data) float64 {
+	c := ind.Corpus()
+	norm := 1.0
+	if dl, ok := ind.DocLength(file); ok && c.Documents > 0 && c.Tokens > 0 {
+		norm = float64(dl) / (float64(c.Tokens) / float64(c.Documents))
+	}
+
+	var score float64
+	for word, f := range d.Freq {
+		df := ind.DocFreq(word)
+		n := c.Documents
+		if n < df {
+			n = df
+		}
+		idf := math.Log(1 + (float64(n-df)+0.5)/(float64(df)+0.5))
+		tf := float64(f) * (s.K1 + 1) / (float64(f) + s.K1*(1-s.B+s.B*norm))
+		score += d.weight(word) * idf * tf
+	}
+	return score
+}
+
+// TFIDFScorer sums logarithmic term frequency multiplied by inverse document frequency
+type TFIDFScorer struct{}
+
+func (TFIDFScorer) Score(ind *Index, _ string, d *Data) float64 {
+	n := ind.Corpus().Documents
+	var score float64
+	for word, f := range d.Freq {
+		df := ind.DocFreq(word)
+		if df == 0 || f == 0 {
+			continue
+		}
+		if n < df {
+			n = df
+		}
+		score += d.weight(word) * (1 + math.Log(float64(f))) * math.Log(1+float64(n)/float64(df))
+	}
+	return score
+}
@@ -0,0 +1,73 @@
+package index
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewScorer(t *testing.T) {
+	for _, name := range []string{"", "proximity", "bm25", "tfidf"} {
+		s, err := NewScorer(name)
+		require.NoError(t, err, name)
+		require.NotNil(t, s, name)
+	}
+	_, err := NewScorer("unknown")
+	require.Error(t, err)
+}
+
+func TestUsesCorpus(t *testing.T) {
+	for name, uses := range map[string]bool{"proximity": false, "bm25": true, "tfidf": true} {
+		s, err := NewScorer(name)
+		require.NoError(t, err, name)
+		require.Equal(t, uses, UsesCorpus(s), name)
+	}
+}
+
+func TestBM25Scorer_RareWordWins(t *testing.T) {
+	ind := defaultTestIndex()
+	page := ind.SearchPage([]string{"hello", "golang"}, NewBM25Scorer(), 0, 0)
+	require.Equal(t, 3, page.Total)
+	// file2 contains both words, file1 contains rare hello twice in short document
+	require.Equal(t, "file2", page.Results[0].File)
+	require.Equal(t, "file1", page.Results[1].File)
+	require.Equal(t, "file3", page.Results[2].File)
+}
+
+func TestBM25Scorer_DocumentLength(t *testing.T) {
+	ind := defaultTestIndex()
+	s := NewBM25Scorer()
+	short := s.Score(ind, "file1", &Data{Freq: map[string]int{"world": 1}})
+	long := s.Score(ind, "file2", &Data{Freq: map[string]int{"world": 1}})
+	require.True(t, short > long, "shorter document must be scored higher")
+}
+
+func TestBM25Scorer_PartialCorpus(t *testing.T) {
+	ind := defaultTestIndex()
+	ind.SetCorpus(Corpus{Documents: 100, Tokens: 1000})
+	s := NewBM25Scorer()
+	common := defaultTestIndex()
+	require.True(t, s.Score(ind, "file1", &Data{Freq: map[string]int{"world": 1}}) >
+		s.Score(common, "file1", &Data{Freq: map[string]int{"world": 1}}),
+		"word must be rarer in the bigger collection")
+}
+
+func TestTFIDFScorer(t *testing.T) {
+	ind := defaultTestIndex()
+	s := TFIDFScorer{}
+	require.True(t, s.Score(ind, "file2", &Data{Freq: map[string]int{"golang": 2}}) >
+		s.Score(ind, "file3", &Data{Freq: map[string]int{"golang": 1}}))
+	require.True(t, s.Score(ind, "file1", &Data{Freq: map[string]int{"golang": 1}}) >
+		s.Score(ind, "file1", &Data{Freq: map[string]int{"world": 1}}))
+	require.Zero(t, s.Score(ind, "file1", &Data{Freq: map[string]int{"unknown": 1}}))
+}
+
+func TestScorer_Weights(t *testing.T) {
+	ind := defaultTestIndex()
+	exact := &Data{Path: 1, Freq: map[string]int{"golang": 1}}
+	fuzzy := &Data{Path: 1, Freq: map[string]int{"golang": 1}, Weights: map[string]float64{"golang": 0.5}}
+	for _, s := range []Scorer{ProximityScorer{}, NewBM25Scorer(), TFIDFScorer{}} {
+		require.True(t, s.Score(ind, "file3", exact) > s.Score(ind, "file3", fuzzy), "%T", s)
+	}
+	require.Equal(t, 1.5, ProximityScorer{}.Score(ind, "file3", fuzzy))
+}
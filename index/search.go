@@ -10,10 +10,36 @@ import (
 type Data struct {
 	Weight int
 	Path   int
+	// Freq contains frequency of every matched word in the file
+	Freq map[string]int
+	// Offsets contains start positions of the matched phrases
+	Offsets []int
+	// Weights of the matched words lower their contribution to the score, words without weights have weight 1
+	Weights map[string]float64
+}
+
+// weight returns the weight of the matched word
+func (d *Data) weight(word string) float64 {
+	if w, ok := d.Weights[word]; ok {
+		return w
+	}
+	return 1
+}
+
+// matched returns the number of matched words counted with their weights
+func (d *Data) matched() float64 {
+	res := float64(d.Path)
+	for word, w := range d.Weights {
+		if _, ok := d.Freq[word]; ok {
+			res -= 1 - w
+		}
+	}
+	return res
 }
 
 type dynamicData struct {
 	Path  int
+	Freq  map[string]int
 	DPVar []*dynamicVar
 }
 
@@ -33,16 +59,22 @@ func makeDynamicVar(pos []int) []*dynamicVar {
 // Search sorting Index data by number of occurrences of words and distance between words in the source file
 // use dynamic programming as search algorithm
 func (ind *Index) Search(searchWords []string) map[string]*Data {
+	ind.m.RLock()
+	defer ind.m.RUnlock()
 
 	data := make(map[string]*dynamicData)
 	for _, word := range searchWords {
 		for _, fileStr := range ind.Data[word] {
 			if data[fileStr.File] == nil {
-				data[fileStr.File] = &dynamicData{DPVar: makeDynamicVar(fileStr.Position)}
+				data[fileStr.File] = &dynamicData{
+					DPVar: makeDynamicVar(fileStr.Position),
+					Freq:  make(map[string]int),
+				}
 			} else {
 				data[fileStr.File].DPVar = dynamicMinPosition(data[fileStr.File].DPVar, fileStr.Position)
 			}
 			data[fileStr.File].Path++
+			data[fileStr.File].Freq[word] = len(fileStr.Position)
 		}
 	}
 	res := make(map[string]*Data)
@@ -81,7 +113,7 @@ func findMinDiffPos(pos []int, key int) int {
 }
 
 func transform(dd *dynamicData) *Data {
-	data := &Data{Path: dd.Path}
+	data := &Data{Path: dd.Path, Freq: dd.Freq}
 	min := math.MaxInt32
 	for i := range dd.DPVar {
 		if dd.DPVar[i].Weight < min {
@@ -91,3 +123,54 @@ func transform(dd *dynamicData) *Data {
 	data.Weight = min
 	return data
 }
+
+// Result describes a single ranked search result
+type Result struct {
+	File  string
+	Score float64
+	Data  *Data
+}
+
+// Page describes one page of search results sorted by score
+type Page struct {
+	Total   int
+	Results []*Result
+}
+
+// Rank scores search data with the given scorer, sorts it in descending order and cuts the page
+// starting from offset with at most limit results. Non-positive limit means no limit,
+// nil scorer means ProximityScorer
+func (ind *Index) Rank(data map[string]*Data, scorer Scorer, offset, limit int) *Page {
+	if scorer == nil {
+		scorer = ProximityScorer{}
+	}
+	res := make([]*Result, 0, len(data))
+	for file, d := range data {
+		res = append(res, &Result{File: file, Score: scorer.Score(ind, file, d), Data: d})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Score != res[j].Score {
+			return res[i].Score > res[j].Score
+		}
+		return res[i].File < res[j].File
+	})
+
+	page := &Page{Total: len(res)}
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > len(res) {
+		offset = len(res)
+	}
+	end := len(res)
+	if limit > 0 && offset+limit < end {
+		end = offset + limit
+	}
+	page.Results = res[offset:end]
+	return page
+}
+
+// SearchPage searches the index and returns ranked page of results
+func (ind *Index) SearchPage(searchWords []string, scorer Scorer, offset, limit int) *Page {
+	return ind.Rank(ind.Search(searchWords), scorer, offset, limit)
+}
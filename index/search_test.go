@@ -46,6 +46,17 @@ func FillDefaultIndex(i *Index) {
 	}}
 }
 
+// defaultTestIndex is the default index with the lengths of its documents and the offsets of a term
+func defaultTestIndex() *Index {
+	ind := NewIndex()
+	FillDefaultIndex(ind)
+	ind.addDoc("file1", 6)
+	ind.addDoc("file2", 12)
+	ind.addDoc("file3", 7)
+	ind.Data["world"][0].Offsets = []int{17}
+	return ind
+}
+
 func (i *searchTestSuite) TestIndex_SimpleSearch() {
 
 	require.Equal(i.T(), 2, len(i.index.Search([]string{"hello"})))
@@ -57,14 +68,17 @@ func (i *searchTestSuite) TestIndex_Search() {
 		"file1": {
 			Weight: 2,
 			Path:   2,
+			Freq:   map[string]int{"hello": 2, "world": 1},
 		},
 		"file2": {
 			Weight: 6,
 			Path:   2,
+			Freq:   map[string]int{"hello": 1, "world": 1},
 		},
 		"file3": {
 			Weight: 0,
 			Path:   1,
+			Freq:   map[string]int{"world": 1},
 		},
 	}
 
@@ -77,16 +91,51 @@ func (i *searchTestSuite) TestIndex_Search2() {
 		"file2": {
 			Weight: 0,
 			Path:   1,
+			Freq:   map[string]int{"golang": 2},
 		},
 		"file3": {
 			Weight: 0,
 			Path:   1,
+			Freq:   map[string]int{"golang": 1},
 		},
 	}
 
 	require.Equal(i.T(), expected, i.index.Search([]string{"golang"}))
 }
 
+func (i *searchTestSuite) TestIndex_SearchPage() {
+
+	page := i.index.SearchPage([]string{"hello", "world"}, nil, 0, 0)
+
+	require.Equal(i.T(), 3, page.Total)
+	require.Len(i.T(), page.Results, 3)
+	require.Equal(i.T(), "file1", page.Results[0].File)
+	require.Equal(i.T(), "file2", page.Results[1].File)
+	require.Equal(i.T(), "file3", page.Results[2].File)
+	require.True(i.T(), page.Results[0].Score > page.Results[1].Score)
+}
+
+func (i *searchTestSuite) TestIndex_SearchPageLimits() {
+
+	page := i.index.SearchPage([]string{"hello", "world"}, nil, 1, 1)
+	require.Equal(i.T(), 3, page.Total)
+	require.Len(i.T(), page.Results, 1)
+	require.Equal(i.T(), "file2", page.Results[0].File)
+
+	page = i.index.SearchPage([]string{"hello", "world"}, nil, 5, 10)
+	require.Equal(i.T(), 3, page.Total)
+	require.Empty(i.T(), page.Results)
+}
+
+func TestRank_EqualScore(t *testing.T) {
+	page := NewIndex().Rank(map[string]*Data{
+		"b": {Weight: 0, Path: 1},
+		"a": {Weight: 0, Path: 1},
+	}, nil, 0, 0)
+	require.Equal(t, "a", page.Results[0].File)
+	require.Equal(t, "b", page.Results[1].File)
+}
+
 func TestSearchSuitStart(t *testing.T) {
 	suite.Run(t, new(searchTestSuite))
 }
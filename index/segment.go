@@ -0,0 +1,611 @@
+package index
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/polisgo2020/search-senyast4745/analysis"
+	"github.com/rs/zerolog/log"
+)
+
+// Segment file layout, all offsets are absolute and fixed-width numbers are little-endian:
+//
+//	header      magic "IVXB", uint32 version, name of the analyzer
+//	documents   uvarint count, then for every file: name, known flag byte, uvarint length
+//	postings    for every term: uvarint count, then for every file: uvarint delta of the document number,
+//	            uvarint count and deltas of positions, uvarint count and deltas of offsets
+//	dictionary  for every term in sorted order: name, uvarint postings offset, uvarint document frequency
+//	term table  uint64 offset of every dictionary entry
+//	footer      uint64 offsets of documents, dictionary and term table, uint64 term count,
+//	            uint64 checksum of the content as in the header of other formats, magic "IVXB"
+//
+// Strings are written as uvarint length followed by the bytes.
+// Documents are numbered in sorted order of their names.
+const (
+	SegmentMagic   = "IVXB"
+	segmentVersion = 3
+	// segmentVersionNoAnalyzer segments have no analyzer in the header, they are made by the default one
+	segmentVersionNoAnalyzer = 2
+
+	segmentHeaderSize = len(SegmentMagic) + 4
+	segmentFooterSize = 5*8 + len(SegmentMagic)
+)
+
+var ErrBadSegment = errors.New("malformed segment file")
+
+// BinaryEncoder structure for writing an index to a binary segment file
+type BinaryEncoder struct {
+	writer io.Writer
+}
+
+// NewBinaryEncoder default constructor to BinaryEncoder with writer
+func NewBinaryEncoder(writer io.Writer) *BinaryEncoder {
+	return &BinaryEncoder{writer: writer}
+}
+
+// Encode collects the whole index from a channel and saves it as a segment.
+// Terms have to be sorted, so nothing is written until the channel is closed.
+// The header row is not kept, the segment has its own term count and checksum
+func (b *BinaryEncoder) Encode(dataChannel <-chan []FileData) error {
+	data := make(map[string][]*FileStruct)
+	docs := make(map[string]int)
+
+	var analyzer string
+	err := encodeRecords(dataChannel, func(r *record) error {
+		switch {
+		case r.Header != nil:
+			analyzer = r.Header.Analyzer
+		case r.Doc != "":
+			docs[r.Doc] += r.Length
+		default:
+			data[r.Term] = append(data[r.Term], r.Postings...)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return writeSegment(b.writer, analyzer, data, docs)
+}
+
+// BinaryDecoder structure for reading and decoding a binary segment file
+type BinaryDecoder struct {
+	reader io.Reader
+}
+
+// NewBinaryDecoder default constructor to BinaryDecoder with reader
+func NewBinaryDecoder(reader io.Reader) *BinaryDecoder {
+	return &BinaryDecoder{reader: reader}
+}
+
+// Decode reads the whole segment and writes its header, documents and terms to the channel
+func (b *BinaryDecoder) Decode(dataChannel chan<- []FileData, constructor func() FileData) error {
+	defer close(dataChannel)
+
+	raw, err := ioutil.ReadAll(b.reader)
+	if err != nil {
+		return err
+	}
+	s, err := newSegment(raw)
+	if err != nil {
+		return err
+	}
+
+	row, err := (&record{Header: &Header{
+		Version:  HeaderVersion,
+		Terms:    s.terms,
+		Docs:     s.corpus.Documents,
+		Checksum: s.checksum,
+		Analyzer: s.analyzer.Name,
+	}}).row(constructor)
+	if err != nil {
+		return err
+	}
+	dataChannel <- row
+
+	for _, d := range s.docs {
+		if !d.known {
+			continue
+		}
+		row, err := (&record{Doc: d.name, Length: d.length}).row(constructor)
+		if err != nil {
+			return err
+		}
+		dataChannel <- row
+	}
+	for i := 0; i < s.terms; i++ {
+		word, postings, err := s.entry(i)
+		if err != nil {
+			return err
+		}
+		row, err := (&record{Term: word, Postings: postings}).row(constructor)
+		if err != nil {
+			return err
+		}
+		dataChannel <- row
+	}
+	return nil
+}
+
+type segmentDoc struct {
+	name   string
+	length int
+	known  bool
+}
+
+// Segment is a read-only index stored in a segment file.
+// Only the document table is loaded, postings are read from the mapped file when they are requested.
+// Segment is safe for concurrent use
+type Segment struct {
+	data   []byte
+	unmap  func() error
+	docs   []segmentDoc
+	dict   uint64
+	table  uint64
+	terms  int
+	corpus Corpus
+	// checksum of the content written by the encoder
+	checksum uint64
+	analyzer *analysis.Analyzer
+}
+
+// OpenSegment maps the segment file into memory
+func OpenSegment(path string) (*Segment, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, unmap, err := mmapFile(f)
+	if err != nil {
+		return nil, err
+	}
+	s, err := newSegment(data)
+	if err != nil {
+		if err := unmap(); err != nil {
+			log.Err(err).Str("file", path).Msg("can not unmap segment")
+		}
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	s.unmap = unmap
+	return s, nil
+}
+
+func newSegment(data []byte) (*Segment, error) {
+	size := uint64(len(data))
+	if len(data) < segmentHeaderSize+segmentFooterSize ||
+		string(data[:len(SegmentMagic)]) != SegmentMagic ||
+		string(data[len(data)-len(SegmentMagic):]) != SegmentMagic {
+		return nil, ErrBadSegment
+	}
+	v := binary.LittleEndian.Uint32(data[len(SegmentMagic):])
+	if v != segmentVersion && v != segmentVersionNoAnalyzer {
+		return nil, fmt.Errorf("unsupported segment version %d", v)
+	}
+
+	footer := data[len(data)-segmentFooterSize:]
+	docs := binary.LittleEndian.Uint64(footer)
+	s := &Segment{
+		data:  data,
+		dict:  binary.LittleEndian.Uint64(footer[8:]),
+		table: binary.LittleEndian.Uint64(footer[16:]),
+	}
+	terms := binary.LittleEndian.Uint64(footer[24:])
+	s.checksum = binary.LittleEndian.Uint64(footer[32:])
+	if docs < uint64(segmentHeaderSize) || docs > s.dict || s.dict > s.table ||
+		terms > size/8 || s.table+terms*8 != size-uint64(segmentFooterSize) {
+		return nil, ErrBadSegment
+	}
+	s.terms = int(terms)
+
+	var name string
+	if v == segmentVersion {
+		r := &segmentReader{data: data[:docs], pos: uint64(segmentHeaderSize)}
+		if name = r.string(); r.err != nil {
+			return nil, r.err
+		}
+	}
+	a, err := analysis.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	s.analyzer = a
+
+	r := &segmentReader{data: data[:s.dict], pos: docs}
+	s.docs = make([]segmentDoc, r.count())
+	for i := range s.docs {
+		s.docs[i] = segmentDoc{name: r.string(), known: r.byte() == 1, length: int(r.uvarint())}
+		if s.docs[i].known {
+			s.corpus.Documents++
+			s.corpus.Tokens += s.docs[i].length
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return s, nil
+}
+
+// Close unmaps the segment file, postings returned before stay valid
+func (s *Segment) Close() error {
+	if s.unmap == nil {
+		return nil
+	}
+	return s.unmap()
+}
+
+// Corpus returns statistics of the indexed collection
+func (s *Segment) Corpus() Corpus {
+	return s.corpus
+}
+
+// Analyzer returns the analyzer which made the terms of the segment
+func (s *Segment) Analyzer() *analysis.Analyzer {
+	return s.analyzer
+}
+
+// Len returns number of terms in the segment
+func (s *Segment) Len() int {
+	return s.terms
+}
+
+// Postings returns postings of the word or nil if the word is not indexed
+func (s *Segment) Postings(word string) ([]*FileStruct, error) {
+	var err error
+	i := sort.Search(s.terms, func(i int) bool {
+		term, e := s.term(i)
+		if e != nil {
+			err = e
+			return true
+		}
+		return term >= word
+	})
+	if err != nil || i == s.terms {
+		return nil, err
+	}
+	term, postings, err := s.entry(i)
+	if err != nil || term != word {
+		return nil, err
+	}
+	return postings, nil
+}
+
+// DocLengths returns lengths of all documents of the segment
+func (s *Segment) DocLengths() (map[string]int, error) {
+	lengths := make(map[string]int, len(s.docs))
+	for _, d := range s.docs {
+		if d.known {
+			lengths[d.name] = d.length
+		}
+	}
+	return lengths, nil
+}
+
+// GetIndex returns an index with the postings of the given words and lengths of the files containing them,
+// the whole segment is loaded if no words are given
+func (s *Segment) GetIndex(words ...string) (*Index, error) {
+	ind := NewIndex()
+	ind.analyzer = s.analyzer
+	if len(words) == 0 {
+		for _, d := range s.docs {
+			if d.known {
+				ind.addDocLocked(d.name, d.length)
+			}
+		}
+		for i := 0; i < s.terms; i++ {
+			word, postings, err := s.entry(i)
+			if err != nil {
+				return nil, err
+			}
+			ind.Data[word] = postings
+		}
+		return ind, nil
+	}
+
+	lengths := make(map[string]int, len(s.docs))
+	for _, d := range s.docs {
+		if d.known {
+			lengths[d.name] = d.length
+		}
+	}
+	for _, word := range words {
+		postings, err := s.Postings(word)
+		if err != nil {
+			return nil, err
+		}
+		if postings == nil {
+			continue
+		}
+		ind.Data[word] = postings
+		for _, p := range postings {
+			if l, ok := lengths[p.File]; ok {
+				ind.Docs[p.File] = l
+			}
+		}
+	}
+	c := s.corpus
+	ind.corpus = &c
+	return ind, nil
+}
+
+// term reads the name of the i-th dictionary entry
+func (s *Segment) term(i int) (string, error) {
+	r := s.dictReader(i)
+	term := r.string()
+	return term, r.err
+}
+
+// entry reads the name and the postings of the i-th dictionary entry
+func (s *Segment) entry(i int) (string, []*FileStruct, error) {
+	r := s.dictReader(i)
+	term := r.string()
+	off := r.uvarint()
+	if r.err != nil {
+		return "", nil, r.err
+	}
+	if off < uint64(segmentHeaderSize) || off >= s.dict {
+		return "", nil, ErrBadSegment
+	}
+
+	r = &segmentReader{data: s.data[:s.dict], pos: off}
+	postings := make([]*FileStruct, r.count())
+	var doc uint64
+	for j := range postings {
+		doc += r.uvarint()
+		if doc >= uint64(len(s.docs)) {
+			return "", nil, ErrBadSegment
+		}
+		postings[j] = &FileStruct{File: s.docs[doc].name, Position: r.deltas(), Offsets: r.deltas()}
+	}
+	return term, postings, r.err
+}
+
+func (s *Segment) dictReader(i int) *segmentReader {
+	off := binary.LittleEndian.Uint64(s.data[s.table+uint64(i)*8:])
+	if off < s.dict || off >= s.table {
+		return &segmentReader{err: ErrBadSegment}
+	}
+	return &segmentReader{data: s.data[:s.table], pos: off}
+}
+
+// segmentReader decodes values from the segment, the first error stops reading
+type segmentReader struct {
+	data []byte
+	pos  uint64
+	err  error
+}
+
+func (r *segmentReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	if r.pos >= uint64(len(r.data)) {
+		r.err = ErrBadSegment
+		return 0
+	}
+	v, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		r.err = ErrBadSegment
+		return 0
+	}
+	r.pos += uint64(n)
+	return v
+}
+
+// count reads the number of following items, every item takes at least one byte
+func (r *segmentReader) count() int {
+	n := r.uvarint()
+	if n > uint64(len(r.data))-r.pos {
+		r.err = ErrBadSegment
+		return 0
+	}
+	return int(n)
+}
+
+func (r *segmentReader) byte() byte {
+	if r.err == nil && r.pos >= uint64(len(r.data)) {
+		r.err = ErrBadSegment
+	}
+	if r.err != nil {
+		return 0
+	}
+	r.pos++
+	return r.data[r.pos-1]
+}
+
+func (r *segmentReader) string() string {
+	n := r.count()
+	if r.err != nil {
+		return ""
+	}
+	r.pos += uint64(n)
+	return string(r.data[r.pos-uint64(n) : r.pos])
+}
+
+func (r *segmentReader) deltas() []int {
+	n := r.count()
+	if n == 0 {
+		return nil
+	}
+	res := make([]int, n)
+	var v uint64
+	for i := range res {
+		v += r.uvarint()
+		res[i] = int(v)
+	}
+	return res
+}
+
+// segmentWriter encodes values to the segment keeping track of the written size
+type segmentWriter struct {
+	w   *bufio.Writer
+	off uint64
+	buf [binary.MaxVarintLen64]byte
+	err error
+}
+
+func (w *segmentWriter) write(p []byte) {
+	if w.err != nil {
+		return
+	}
+	_, w.err = w.w.Write(p)
+	w.off += uint64(len(p))
+}
+
+func (w *segmentWriter) uvarint(v uint64) {
+	w.write(w.buf[:binary.PutUvarint(w.buf[:], v)])
+}
+
+func (w *segmentWriter) uint64(v uint64) {
+	binary.LittleEndian.PutUint64(w.buf[:], v)
+	w.write(w.buf[:8])
+}
+
+func (w *segmentWriter) string(s string) {
+	w.uvarint(uint64(len(s)))
+	w.write([]byte(s))
+}
+
+func (w *segmentWriter) deltas(values []int) error {
+	w.uvarint(uint64(len(values)))
+	var prev int
+	for _, v := range values {
+		if v < prev {
+			return fmt.Errorf("values are not sorted: %v", values)
+		}
+		w.uvarint(uint64(v - prev))
+		prev = v
+	}
+	return nil
+}
+
+// writeSegment saves postings and lengths of the files in the segment format
+func writeSegment(writer io.Writer, analyzer string, data map[string][]*FileStruct, docs map[string]int) error {
+	known := make(map[string]bool, len(docs))
+	for file := range docs {
+		known[file] = true
+	}
+	for _, postings := range data {
+		for _, p := range postings {
+			known[p.File] = true
+		}
+	}
+	files := make([]string, 0, len(known))
+	for file := range known {
+		files = append(files, file)
+	}
+
+	return writeSegmentTerms(writer, analyzer, files, docs, termsOf(data))
+}
+
+// writeSegmentTerms saves the segment while the terms are produced in sorted order,
+// only names and offsets of the terms are kept in memory. The files must include every file of the postings
+func writeSegmentTerms(writer io.Writer, analyzer string, files []string, docs map[string]int,
+	terms func(TermFunc) error) error {
+	files = append([]string(nil), files...)
+	sort.Strings(files)
+	ids := make(map[string]uint64, len(files))
+	for i, file := range files {
+		ids[file] = uint64(i)
+	}
+
+	w := &segmentWriter{w: bufio.NewWriter(writer)}
+	w.write([]byte(SegmentMagic))
+	binary.LittleEndian.PutUint32(w.buf[:], segmentVersion)
+	w.write(w.buf[:4])
+	w.string(analyzer)
+
+	var checksum uint64
+	docsOff := w.off
+	w.uvarint(uint64(len(files)))
+	for _, file := range files {
+		w.string(file)
+		l, ok := docs[file]
+		if ok {
+			checksum += docChecksum(file, l)
+			w.write([]byte{1})
+		} else {
+			w.write([]byte{0})
+		}
+		w.uvarint(uint64(l))
+	}
+
+	var names []string
+	var postingsOff, freqs []uint64
+	err := terms(func(word string, data []*FileStruct) error {
+		if len(names) > 0 && word <= names[len(names)-1] {
+			return fmt.Errorf("term %q goes after %q", word, names[len(names)-1])
+		}
+		names = append(names, word)
+		postingsOff = append(postingsOff, w.off)
+		freqs = append(freqs, uint64(len(data)))
+
+		postings := make([]*FileStruct, len(data))
+		copy(postings, data)
+		for _, p := range postings {
+			if _, ok := ids[p.File]; !ok {
+				return fmt.Errorf("postings of %q point at unknown file %s", word, p.File)
+			}
+		}
+		sort.SliceStable(postings, func(i, j int) bool {
+			return ids[postings[i].File] < ids[postings[j].File]
+		})
+
+		w.uvarint(uint64(len(postings)))
+		var prev uint64
+		for _, p := range postings {
+			checksum += postingChecksum(word, p)
+			w.uvarint(ids[p.File] - prev)
+			prev = ids[p.File]
+			if err := w.deltas(p.Position); err != nil {
+				return fmt.Errorf("positions of %q in %s: %w", word, p.File, err)
+			}
+			if err := w.deltas(p.Offsets); err != nil {
+				return fmt.Errorf("offsets of %q in %s: %w", word, p.File, err)
+			}
+		}
+		return w.err
+	})
+	if err != nil {
+		return err
+	}
+
+	dictOff := w.off
+	entries := make([]uint64, len(names))
+	for i, word := range names {
+		entries[i] = w.off
+		w.string(word)
+		w.uvarint(postingsOff[i])
+		w.uvarint(freqs[i])
+	}
+
+	tableOff := w.off
+	for _, off := range entries {
+		w.uint64(off)
+	}
+
+	w.uint64(docsOff)
+	w.uint64(dictOff)
+	w.uint64(tableOff)
+	w.uint64(uint64(len(names)))
+	w.uint64(checksum)
+	w.write([]byte(SegmentMagic))
+
+	if w.err != nil {
+		return w.err
+	}
+	return w.w.Flush()
+}
+
+// IsSegment reports whether the data starts with the segment magic bytes
+func IsSegment(header []byte) bool {
+	return bytes.HasPrefix(header, []byte(SegmentMagic))
+}
@@ -0,0 +1,109 @@
+package index
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestSegment(t *testing.T, ind *Index) string {
+	dir, err := ioutil.TempDir("", "segment")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, ind.ToFile(NewBinaryEncoder(&buf)))
+	require.True(t, IsSegment(buf.Bytes()))
+
+	path := filepath.Join(dir, "index.seg")
+	require.NoError(t, ioutil.WriteFile(path, buf.Bytes(), 0644))
+	return path
+}
+
+func TestBinaryEncoder_RoundTrip(t *testing.T) {
+	ind := defaultTestIndex()
+
+	var buf bytes.Buffer
+	require.NoError(t, ind.ToFile(NewBinaryEncoder(&buf)))
+
+	res := NewIndex()
+	require.NoError(t, res.FromFile(NewBinaryDecoder(&buf)))
+	require.Equal(t, ind, res)
+}
+
+func TestBinaryEncoder_Sorted(t *testing.T) {
+	var a, b bytes.Buffer
+	require.NoError(t, defaultTestIndex().ToFile(NewBinaryEncoder(&a)))
+	require.NoError(t, defaultTestIndex().ToFile(NewBinaryEncoder(&b)))
+	require.Equal(t, a.Bytes(), b.Bytes(), "the same index must give the same segment")
+}
+
+func TestOpenSegment(t *testing.T) {
+	path := writeTestSegment(t, defaultTestIndex())
+	defer os.RemoveAll(filepath.Dir(path))
+
+	s, err := OpenSegment(path)
+	require.NoError(t, err)
+	defer s.Close()
+
+	require.Equal(t, 3, s.Len())
+	require.Equal(t, Corpus{Documents: 3, Tokens: 25}, s.Corpus())
+
+	postings, err := s.Postings("world")
+	require.NoError(t, err)
+	require.Equal(t, []*FileStruct{
+		{File: "file1", Position: []int{3}, Offsets: []int{17}},
+		{File: "file2", Position: []int{0}},
+		{File: "file3", Position: []int{3}},
+	}, postings)
+
+	postings, err = s.Postings("missing")
+	require.NoError(t, err)
+	require.Nil(t, postings)
+
+	ind, err := s.GetIndex("golang", "missing")
+	require.NoError(t, err)
+	require.Equal(t, []string{"golang"}, keys(ind.Data))
+	require.Equal(t, map[string]int{"file2": 12, "file3": 7}, ind.Docs)
+	require.Equal(t, Corpus{Documents: 3, Tokens: 25}, ind.Corpus())
+
+	docs, err := s.DocLengths()
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"file1": 6, "file2": 12, "file3": 7}, docs)
+	ind.AddDocLengths(docs)
+	require.Equal(t, docs, ind.Docs)
+	require.Equal(t, Corpus{Documents: 3, Tokens: 25}, ind.Corpus(), "the corpus of the part is kept")
+
+	ind, err = s.GetIndex()
+	require.NoError(t, err)
+	require.Equal(t, defaultTestIndex(), ind)
+}
+
+func TestOpenSegment_Malformed(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, defaultTestIndex().ToFile(NewBinaryEncoder(&buf)))
+	data := buf.Bytes()
+
+	for name, corrupt := range map[string][]byte{
+		"empty":     {},
+		"truncated": data[:len(data)-10],
+		"csv":       []byte("hello,[]\n"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := newSegment(corrupt)
+			require.Error(t, err)
+		})
+	}
+
+	broken := append([]byte{}, data...)
+	// point the first dictionary entry outside the term table
+	table := len(broken) - segmentFooterSize - 3*8
+	copy(broken[table:], bytes.Repeat([]byte{0xff}, 8))
+	s, err := newSegment(broken)
+	require.NoError(t, err)
+	_, err = s.Postings("golang")
+	require.Equal(t, ErrBadSegment, err)
+}
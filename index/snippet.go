@@ -0,0 +1,285 @@
+package index
+
+import (
+	"container/list"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// snippetWindow is the maximal distance in tokens between the words of one snippet
+	snippetWindow = 10
+	// snippetContext is the number of bytes of the source text around the matched words
+	snippetContext = 60
+)
+
+// Snippet describes a piece of the source text around the matched words.
+// Matches contains byte ranges of the matched words inside Text
+type Snippet struct {
+	Text    string
+	Matches [][2]int
+}
+
+// SourceReader returns original text of the indexed file
+type SourceReader interface {
+	Source(file string) ([]byte, error)
+}
+
+// SourceCache reads indexed files from disk and keeps the most recently used of them in memory
+type SourceCache struct {
+	m     sync.Mutex
+	size  int
+	read  func(file string) ([]byte, error)
+	items map[string]*list.Element
+	order *list.List
+}
+
+type sourceItem struct {
+	file string
+	text []byte
+}
+
+// NewSourceCache creates cache keeping at most size files in memory
+func NewSourceCache(size int) *SourceCache {
+	return NewSourceCacheReader(size, ioutil.ReadFile)
+}
+
+// NewSourceCacheReader creates cache reading the text of the files with read,
+// it has to return the same text the file was indexed from
+func NewSourceCacheReader(size int, read func(file string) ([]byte, error)) *SourceCache {
+	return &SourceCache{size: size, read: read, items: make(map[string]*list.Element), order: list.New()}
+}
+
+// Source returns text of the file from cache or reads it from disk
+func (c *SourceCache) Source(file string) ([]byte, error) {
+	c.m.Lock()
+	if el, ok := c.items[file]; ok {
+		c.order.MoveToFront(el)
+		c.m.Unlock()
+		return el.Value.(*sourceItem).text, nil
+	}
+	c.m.Unlock()
+
+	text, err := c.read(file)
+	if err != nil {
+		return nil, err
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+	if _, ok := c.items[file]; !ok && c.size > 0 {
+		c.items[file] = c.order.PushFront(&sourceItem{file: file, text: text})
+		for c.order.Len() > c.size {
+			last := c.order.Back()
+			c.order.Remove(last)
+			delete(c.items, last.Value.(*sourceItem).file)
+		}
+	}
+	return text, nil
+}
+
+// Invalidate drops the cached text of the files, so it is read again when it is needed
+func (c *SourceCache) Invalidate(files []string) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	for _, file := range files {
+		if el, ok := c.items[file]; ok {
+			c.order.Remove(el)
+			delete(c.items, file)
+		}
+	}
+}
+
+type hit struct {
+	word     string
+	position int
+	offset   int
+}
+
+// Snippets returns at most count pieces of the file text around the windows
+// containing the most of the given words. Nothing is returned for indexes built without byte offsets
+func (ind *Index) Snippets(file string, words []string, src SourceReader, count int) ([]*Snippet, error) {
+	var hits []hit
+	ind.m.RLock()
+	for _, word := range words {
+		for _, fileStr := range ind.Data[word] {
+			if fileStr.File != file || len(fileStr.Offsets) != len(fileStr.Position) {
+				continue
+			}
+			for i := range fileStr.Position {
+				hits = append(hits, hit{word: word, position: fileStr.Position[i], offset: fileStr.Offsets[i]})
+			}
+		}
+	}
+	ind.m.RUnlock()
+	if len(hits) == 0 || count <= 0 {
+		return nil, nil
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		return hits[i].position < hits[j].position
+	})
+
+	text, err := src.Source(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []*Snippet
+	for _, w := range bestWindows(hits, count) {
+		res = append(res, makeSnippet(text, w))
+	}
+	return res, nil
+}
+
+// bestWindows chooses at most count non-overlapping windows of hits
+// with the largest number of distinct words and then the largest number of hits
+func bestWindows(hits []hit, count int) [][]hit {
+	type window struct {
+		from, to int
+		distinct int
+	}
+	var windows []window
+	for i := range hits {
+		words := make(map[string]bool)
+		j := i
+		for ; j < len(hits) && hits[j].position-hits[i].position < snippetWindow; j++ {
+			words[hits[j].word] = true
+		}
+		windows = append(windows, window{from: i, to: j, distinct: len(words)})
+	}
+	sort.SliceStable(windows, func(i, j int) bool {
+		if windows[i].distinct != windows[j].distinct {
+			return windows[i].distinct > windows[j].distinct
+		}
+		return windows[i].to-windows[i].from > windows[j].to-windows[j].from
+	})
+
+	var chosen []window
+	for _, w := range windows {
+		if len(chosen) == count {
+			break
+		}
+		overlaps := false
+		for _, c := range chosen {
+			if w.from < c.to && c.from < w.to {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			chosen = append(chosen, w)
+		}
+	}
+	sort.Slice(chosen, func(i, j int) bool {
+		return chosen[i].from < chosen[j].from
+	})
+
+	res := make([][]hit, 0, len(chosen))
+	for _, c := range chosen {
+		res = append(res, hits[c.from:c.to])
+	}
+	return res
+}
+
+// makeSnippet cuts the text around the hits and marks the words
+func makeSnippet(text []byte, hits []hit) *Snippet {
+	first := clamp(hits[0].offset, len(text))
+	last := wordEnd(text, clamp(hits[len(hits)-1].offset, len(text)))
+
+	start := first - snippetContext
+	if start <= 0 {
+		start = 0
+	} else {
+		start = nextWordStart(text, start)
+		if start > first {
+			start = first
+		}
+	}
+	end := last + snippetContext
+	if end >= len(text) {
+		end = len(text)
+	} else {
+		end = prevWordEnd(text, end)
+		if end < last {
+			end = last
+		}
+	}
+
+	s := &Snippet{Text: string(text[start:end])}
+	for _, h := range hits {
+		from := clamp(h.offset, len(text))
+		s.Matches = append(s.Matches, [2]int{from - start, wordEnd(text, from) - start})
+	}
+	return s
+}
+
+func clamp(offset, length int) int {
+	if offset < 0 {
+		return 0
+	}
+	if offset > length {
+		return length
+	}
+	return offset
+}
+
+// wordEnd returns the end of the word starting at offset without trailing runes other than letters and digits
+func wordEnd(text []byte, offset int) int {
+	end := offset
+	lastLetter := offset
+	for end < len(text) {
+		r, size := utf8.DecodeRune(text[end:])
+		if unicode.IsSpace(r) {
+			break
+		}
+		end += size
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			lastLetter = end
+		}
+	}
+	return lastLetter
+}
+
+// nextWordStart moves offset forward to the beginning of the next word
+func nextWordStart(text []byte, offset int) int {
+	for offset < len(text) {
+		r, size := utf8.DecodeRune(text[offset:])
+		if unicode.IsSpace(r) {
+			break
+		}
+		offset += size
+	}
+	for offset < len(text) {
+		r, size := utf8.DecodeRune(text[offset:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		offset += size
+	}
+	return offset
+}
+
+// prevWordEnd moves offset back to the end of the previous word
+func prevWordEnd(text []byte, offset int) int {
+	for offset > 0 && !utf8.RuneStart(text[offset]) {
+		offset--
+	}
+	for offset > 0 {
+		r, size := utf8.DecodeLastRune(text[:offset])
+		if unicode.IsSpace(r) {
+			break
+		}
+		offset -= size
+	}
+	for offset > 0 {
+		r, size := utf8.DecodeLastRune(text[:offset])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		offset -= size
+	}
+	return offset
+}
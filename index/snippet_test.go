@@ -0,0 +1,139 @@
+package index
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type mapSource map[string]string
+
+func (m mapSource) Source(file string) ([]byte, error) {
+	text, ok := m[file]
+	if !ok {
+		return nil, errors.New("file not found")
+	}
+	return []byte(text), nil
+}
+
+func snippetTestIndex(texts mapSource) *Index {
+	ind := NewIndex()
+	ind.OpenApplyAndListenChannel(func(wg *sync.WaitGroup) {
+		for file, text := range texts {
+			wg.Add(1)
+			go func(file, text string) {
+				defer wg.Done()
+				ind.MapAndCleanWords(bytes.NewBufferString(text), file)
+			}(file, text)
+		}
+	})
+	return ind
+}
+
+func TestIndex_Snippets(t *testing.T) {
+	texts := mapSource{
+		"file1": "Golang is great. " + strings.Repeat("Filler words go here. ", 10) +
+			"The inverted index, built in Golang, is fast.",
+	}
+	ind := snippetTestIndex(texts)
+
+	snippets, err := ind.Snippets("file1", []string{"invert", "index", "golang"}, texts, 1)
+	require.NoError(t, err)
+	require.Len(t, snippets, 1)
+
+	s := snippets[0]
+	require.True(t, strings.HasSuffix(s.Text, "The inverted index, built in Golang, is fast."), s.Text)
+	require.False(t, strings.HasPrefix(s.Text, " "))
+	var marked []string
+	for _, m := range s.Matches {
+		marked = append(marked, s.Text[m[0]:m[1]])
+	}
+	require.Equal(t, []string{"inverted", "index", "Golang"}, marked)
+}
+
+func TestIndex_SnippetsSeveral(t *testing.T) {
+	texts := mapSource{
+		"file1": "golang " + strings.Repeat("filler ", 30) + "golang " + strings.Repeat("filler ", 30) + "golang",
+	}
+	ind := snippetTestIndex(texts)
+
+	snippets, err := ind.Snippets("file1", []string{"golang"}, texts, 2)
+	require.NoError(t, err)
+	require.Len(t, snippets, 2)
+	require.Equal(t, [][2]int{{0, 6}}, snippets[0].Matches)
+
+	snippets, err = ind.Snippets("file2", []string{"golang"}, texts, 2)
+	require.NoError(t, err)
+	require.Empty(t, snippets)
+}
+
+func TestWordEnd(t *testing.T) {
+	text := []byte("(golang), 2020. v1.2")
+	require.Equal(t, 7, wordEnd(text, 1))
+	require.Equal(t, 14, wordEnd(text, 10))
+	require.Equal(t, 20, wordEnd(text, 16))
+}
+
+func TestIndex_SnippetsWithoutOffsets(t *testing.T) {
+	ind := NewIndex()
+	FillDefaultIndex(ind)
+
+	snippets, err := ind.Snippets("file1", []string{"hello"}, mapSource{}, 1)
+	require.NoError(t, err)
+	require.Empty(t, snippets)
+}
+
+func TestSourceCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snippet")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	first := filepath.Join(dir, "first")
+	second := filepath.Join(dir, "second")
+	require.NoError(t, ioutil.WriteFile(first, []byte("first text"), 0644))
+	require.NoError(t, ioutil.WriteFile(second, []byte("second text"), 0644))
+
+	c := NewSourceCache(1)
+	text, err := c.Source(first)
+	require.NoError(t, err)
+	require.Equal(t, "first text", string(text))
+
+	require.NoError(t, os.Remove(first))
+	text, err = c.Source(first)
+	require.NoError(t, err, "file must be cached")
+	require.Equal(t, "first text", string(text))
+
+	_, err = c.Source(second)
+	require.NoError(t, err)
+	_, err = c.Source(first)
+	require.Error(t, err, "file must be evicted from cache")
+}
+
+func TestSourceCache_Invalidate(t *testing.T) {
+	texts := map[string]string{"first": "old text", "second": "second text"}
+	c := NewSourceCacheReader(2, func(file string) ([]byte, error) {
+		return []byte(texts[file]), nil
+	})
+	_, err := c.Source("first")
+	require.NoError(t, err)
+	_, err = c.Source("second")
+	require.NoError(t, err)
+
+	texts["first"] = "new text"
+	texts["second"] = "changed text"
+	c.Invalidate([]string{"first", "unknown"})
+
+	text, err := c.Source("first")
+	require.NoError(t, err)
+	require.Equal(t, "new text", string(text), "invalidated file must be read again")
+	text, err = c.Source("second")
+	require.NoError(t, err)
+	require.Equal(t, "second text", string(text))
+}
@@ -0,0 +1,134 @@
+package index
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Problem describes a damaged part of the index file
+type Problem struct {
+	// Row is the number of the row starting from 1, it is 0 for problems of the whole file
+	Row  int
+	Term string
+	Msg  string
+}
+
+func (p Problem) String() string {
+	switch {
+	case p.Row == 0:
+		return p.Msg
+	case p.Term != "":
+		return fmt.Sprintf("row %d, term %q: %s", p.Row, p.Term, p.Msg)
+	}
+	return fmt.Sprintf("row %d: %s", p.Row, p.Msg)
+}
+
+// Report describes the result of the index file verification
+type Report struct {
+	Header   *Header
+	Rows     int
+	Terms    int
+	Docs     int
+	Problems []Problem
+}
+
+// OK reports whether no problems are found
+func (r *Report) OK() bool {
+	return len(r.Problems) == 0
+}
+
+func (r *Report) add(row int, term, format string, args ...interface{}) {
+	r.Problems = append(r.Problems, Problem{Row: row, Term: term, Msg: fmt.Sprintf(format, args...)})
+}
+
+// Verify reads the whole index file and reports corrupt rows, duplicate terms and documents,
+// negative or unsorted positions, postings which point at unknown files and the mismatch with the header
+func Verify(decoder Decoder) *Report {
+	if d, ok := decoder.(strictDecoder); ok {
+		d.setStrict(true)
+	}
+
+	report := &Report{}
+	dataChannel := make(chan []FileData, 10)
+	done := make(chan struct{})
+
+	var read Header
+	terms := make(map[string]bool)
+	docs := make(map[string]bool)
+	// referenced counts terms with postings in every file
+	referenced := make(map[string]int)
+
+	go func(dataCh <-chan []FileData) {
+		defer close(done)
+		for data := range dataCh {
+			report.Rows++
+			n := report.Rows
+			r, err := parseRow(data)
+			if err != nil {
+				report.add(n, "", "corrupt row: %v", err)
+				continue
+			}
+			switch {
+			case r.Header != nil:
+				if n != 1 || report.Header != nil {
+					report.add(n, "", "header must be the first row")
+					continue
+				}
+				report.Header = r.Header
+				continue
+			case r.Doc != "":
+				if docs[r.Doc] {
+					report.add(n, "", "duplicate document %s", r.Doc)
+				}
+				if r.Length < 0 {
+					report.add(n, "", "negative length %d of document %s", r.Length, r.Doc)
+				}
+				docs[r.Doc] = true
+			default:
+				if terms[r.Term] {
+					report.add(n, r.Term, "duplicate term")
+				}
+				terms[r.Term] = true
+				if err := checkPostings(r.Postings); err != nil {
+					report.add(n, r.Term, "%v", err)
+				}
+				for _, p := range r.Postings {
+					referenced[p.File]++
+				}
+			}
+			read.add(r)
+		}
+	}(dataChannel)
+
+	err := decoder.Decode(dataChannel, func() FileData {
+		return &simpleFileData{}
+	})
+	<-done
+	if err != nil {
+		report.add(report.Rows+1, "", "can not decode: %v", err)
+	}
+
+	report.Terms = read.Terms
+	report.Docs = read.Docs
+
+	// old index files have no documents at all, every file is unknown for them
+	if len(docs) > 0 {
+		var unknown []string
+		for file := range referenced {
+			if !docs[file] {
+				unknown = append(unknown, file)
+			}
+		}
+		sort.Strings(unknown)
+		for _, file := range unknown {
+			report.add(0, "", "postings of %d terms point at unknown file %s", referenced[file], file)
+		}
+	}
+
+	if report.Header == nil {
+		report.add(0, "", "index file has no header")
+	} else if err := report.Header.check(&read); err != nil {
+		report.add(0, "", "%v", err)
+	}
+	return report
+}
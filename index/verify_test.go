@@ -0,0 +1,85 @@
+package index
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func verifyTestFile(t *testing.T) string {
+	var buf bytes.Buffer
+	require.NoError(t, defaultTestIndex().ToFile(NewCsvEncoder(&buf)))
+	return buf.String()
+}
+
+func TestVerify(t *testing.T) {
+	for _, format := range Formats {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			encoder, err := NewEncoder(format, &buf)
+			require.NoError(t, err)
+			require.NoError(t, defaultTestIndex().ToFile(encoder))
+
+			decoder, err := NewDecoder(format, &buf)
+			require.NoError(t, err)
+			report := Verify(decoder)
+			require.True(t, report.OK(), "%v", report.Problems)
+			require.Equal(t, 3, report.Terms)
+			require.Equal(t, 3, report.Docs)
+		})
+	}
+}
+
+func TestVerify_Problems(t *testing.T) {
+	file := verifyTestFile(t) +
+		"hello,\"[{\"\"file\"\":\"\"file1\"\",\"\"position\"\":[1]}]\"\n" +
+		"broken,\"[{\"\"file\"\":\"\"file1\"\",\"\"position\"\":[3,2]}]\"\n" +
+		"negative,\"[{\"\"file\"\":\"\"file9\"\",\"\"position\"\":[-1]}]\"\n" +
+		"truncated,\"[{\"\"file\"\":\"\"fi\"\n"
+
+	report := Verify(NewCsvDecoder(strings.NewReader(file)))
+	var problems []string
+	for _, p := range report.Problems {
+		problems = append(problems, p.String())
+	}
+	require.Equal(t, []string{
+		`row 8, term "hello": duplicate term`,
+		`row 9, term "broken": positions in file1: unsorted values 3 and 2`,
+		`row 10, term "negative": positions in file9: negative value -1`,
+		"row 11: corrupt row: unexpected end of JSON input",
+		"postings of 1 terms point at unknown file file9",
+		"index must contain 3 terms and 3 documents, but 6 terms and 3 documents are read",
+	}, problems)
+}
+
+func TestVerify_Checksum(t *testing.T) {
+	file := strings.Replace(verifyTestFile(t), "[0,5]", "[0,6]", 1)
+
+	report := Verify(NewCsvDecoder(strings.NewReader(file)))
+	require.Len(t, report.Problems, 1)
+	require.Contains(t, report.Problems[0].Msg, "checksum mismatch")
+}
+
+func TestIndex_FromFileStrict(t *testing.T) {
+	ind := NewIndex()
+	require.NoError(t, ind.FromFile(NewCsvDecoder(strings.NewReader(verifyTestFile(t))), Strict()))
+	require.Equal(t, defaultTestIndex(), ind)
+
+	changed := strings.Replace(verifyTestFile(t), "[0,5]", "[0,6]", 1)
+	lenient := NewIndex()
+	require.Error(t, lenient.FromFile(NewCsvDecoder(strings.NewReader(changed))))
+	require.Len(t, lenient.Data, 3, "lenient mode loads the whole file")
+	require.Error(t, NewIndex().FromFile(NewCsvDecoder(strings.NewReader(changed)), Strict()))
+
+	truncated := verifyTestFile(t)
+	truncated = truncated[:strings.LastIndex(truncated, "\n")]
+	truncated = truncated[:strings.LastIndex(truncated, "\n")+1]
+	require.Error(t, NewIndex().FromFile(NewCsvDecoder(strings.NewReader(truncated)), Strict()))
+
+	headless := verifyTestFile(t)
+	headless = headless[strings.Index(headless, "\n")+1:]
+	require.NoError(t, NewIndex().FromFile(NewCsvDecoder(strings.NewReader(headless))), "header is optional")
+	require.Error(t, NewIndex().FromFile(NewCsvDecoder(strings.NewReader(headless)), Strict()))
+}
@@ -1,15 +1,32 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/polisgo2020/search-senyast4745/analysis"
 	"github.com/polisgo2020/search-senyast4745/config"
 	"github.com/polisgo2020/search-senyast4745/database"
+	"github.com/polisgo2020/search-senyast4745/extract"
+	"github.com/polisgo2020/search-senyast4745/manifest"
+	"github.com/polisgo2020/search-senyast4745/sources"
+	"github.com/polisgo2020/search-senyast4745/stats"
+	"github.com/polisgo2020/search-senyast4745/util"
+	"github.com/polisgo2020/search-senyast4745/watch"
 	"github.com/polisgo2020/search-senyast4745/web"
 	"github.com/urfave/cli/v2"
 
@@ -55,6 +72,24 @@ func main() {
 		DefaultText: "",
 	}
 
+	formatFlag := &cli.StringFlag{
+		Aliases: []string{"f"},
+		Name:    "format",
+		Usage:   "Index file format: csv, jsonl, gob or binary",
+		Value:   string(index.FormatCSV),
+	}
+
+	detectFormatFlag := &cli.StringFlag{
+		Aliases: []string{"f"},
+		Name:    "format",
+		Usage:   "Index file format: csv, jsonl, gob or binary, detected from the file if not set",
+	}
+
+	compressFlag := &cli.StringFlag{
+		Name:  "compress",
+		Usage: "Index file compression: none, gzip or zstd, chosen by the file extension if not set",
+	}
+
 	sourcesFlag := &cli.StringFlag{
 		Aliases:  []string{"s"},
 		Name:     "sources, s",
@@ -62,6 +97,34 @@ func main() {
 		Required: true,
 	}
 
+	includeFlag := &cli.StringSliceFlag{
+		Name:  "include",
+		Usage: "Patterns of the indexed files in .gitignore syntax, all files are indexed if not set",
+	}
+
+	excludeFlag := &cli.StringSliceFlag{
+		Name:  "exclude",
+		Usage: "Patterns of the ignored files and folders in .gitignore syntax",
+	}
+
+	maxFileSizeFlag := &cli.StringFlag{
+		Name:  "max-file-size",
+		Usage: "Size of the largest indexed file, like 10MB, 0 means unlimited",
+		Value: "0",
+	}
+
+	persistFlag := &cli.DurationFlag{
+		Name:  "persist",
+		Usage: "Interval of saving the changed index to the file",
+		Value: time.Minute,
+	}
+
+	workersFlag := &cli.IntFlag{
+		Name:  "workers",
+		Usage: "Number of files read at once",
+		Value: runtime.GOMAXPROCS(0),
+	}
+
 	app.Commands = []*cli.Command{
 		{
 			Name:    "build",
@@ -70,18 +133,107 @@ func main() {
 			Flags: []cli.Flag{
 				indexFileFlag,
 				sourcesFlag,
+				formatFlag,
+				compressFlag,
+				workersFlag,
+				includeFlag,
+				excludeFlag,
+				maxFileSizeFlag,
+				&cli.StringFlag{
+					Name:  "stats-file",
+					Usage: "File to save statistics of the build as json",
+				},
+				&cli.StringFlag{
+					Name:  "max-memory",
+					Usage: "Memory budget of the build, like 512MB, partial indexes are spilled to temporary files when it is exceeded, 0 means unlimited",
+					Value: "0",
+				},
+				&cli.StringFlag{
+					Name: "analyzer",
+					Usage: "Analyzer of the text: " + strings.Join(analysis.Names(), ", ") +
+						" or a pipeline like 'quotes|word|lowercase|ascii|length(2,40)', it is saved with the index",
+					Value: analysis.DefaultName,
+				},
+				&cli.StringSliceFlag{
+					Name:  "stop-words",
+					Usage: "Stop lists replacing the lists of the analyzer: en, ru or files with a word per line, none disables stop words",
+				},
+				&cli.BoolFlag{
+					Name:  "keep-stop-words",
+					Usage: "Index stop words, they are dropped only from search queries outside of quoted phrases",
+				},
 			},
 			Action: build,
 		},
+		{
+			Name:    "update",
+			Aliases: []string{"u"},
+			Usage:   "Update search index with added, modified and deleted files",
+			Flags: []cli.Flag{
+				indexFileFlag,
+				sourcesFlag,
+				workersFlag,
+				includeFlag,
+				excludeFlag,
+				maxFileSizeFlag,
+			},
+			Action: update,
+		},
+		{
+			Name:    "watch",
+			Aliases: []string{"w"},
+			Usage:   "Search over the index and keep it up to date with the sources",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Aliases:  []string{"i"},
+					Name:     "index",
+					Usage:    "Index file",
+					Required: true,
+				},
+				sourcesFlag,
+				workersFlag,
+				includeFlag,
+				excludeFlag,
+				maxFileSizeFlag,
+				persistFlag,
+				&cli.DurationFlag{
+					Name:  "delay",
+					Usage: "Time to wait for more file changes before applying them",
+					Value: 500 * time.Millisecond,
+				},
+			},
+			Action: watchSources,
+		},
 		{
 			Name:    "search",
 			Aliases: []string{"s"},
 			Usage:   "Search over the index",
 			Flags: []cli.Flag{
 				indexFileFlag,
+				detectFormatFlag,
+				&cli.BoolFlag{
+					Name:  "strict",
+					Usage: "Fail on a damaged index file instead of skipping damaged rows",
+				},
+				persistFlag,
 			},
 			Action: search,
 		},
+		{
+			Name:    "verify",
+			Aliases: []string{"v"},
+			Usage:   "Check the index file for damaged rows",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Aliases:  []string{"i"},
+					Name:     "index",
+					Usage:    "Index file",
+					Required: true,
+				},
+				detectFormatFlag,
+			},
+			Action: verify,
+		},
 	}
 
 	err = app.Run(os.Args)
@@ -114,73 +266,715 @@ func build(c *cli.Context) error {
 		log.Err(err).Strs("context flags", c.FlagNames()).Msg("error while checking context")
 		return nil
 	}
-	if allFiles, err := filePathWalkDir(c.String("sources")); err != nil {
+	st, err := parseStorage(c)
+	if err != nil {
+		log.Err(err).Msg("error while checking context")
+		return nil
+	}
+	maxMemory, err := util.ParseSize(c.String("max-memory"))
+	if err != nil {
+		log.Err(err).Msg("error while checking context")
+		return nil
+	}
+	analyzer, err := analysis.Get(c.String("analyzer"))
+	if err != nil {
+		log.Err(err).Msg("error while checking context")
+		return nil
+	}
+	analyzer, err = analysis.WithStopWords(analyzer, c.StringSlice("stop-words"), c.Bool("keep-stop-words"))
+	if err != nil {
+		log.Err(err).Msg("error while checking context")
+		return nil
+	}
+
+	sel, err := newSelector(c)
+	if err != nil {
+		log.Err(err).Msg("error while checking context")
+		return nil
+	}
+
+	report := &stats.Stats{}
+	end := report.Start("walk")
+	allFiles, size, skipped, err := sel.Walk()
+	end()
+	if err != nil {
 		log.Err(err).Str(" directory", c.String("sources")).Msg("can not read files list")
+		return nil
+	}
+	log.Debug().Strs("files", allFiles).Int("skipped", len(skipped)).Msg("folder parsed")
+	report.Files, report.Bytes = len(allFiles), size
+	for _, s := range skipped {
+		report.Skip(s.Path, s.Reason)
+	}
+
+	b, err := index.NewBuilder(maxMemory, "")
+	if err != nil {
+		log.Err(err).Msg("can not start index build")
+		return nil
+	}
+	defer b.Close()
+	b.SetAnalyzer(analyzer)
+	log.Debug().Str("analyzer", analyzer.Name).Msg("index analyzer")
+
+	end = report.Start("index")
+	progress := stats.NewProgress(os.Stderr, stats.IsTerminal(os.Stderr), len(allFiles), size)
+	progress.Start()
+	// entries of the files are recorded when they are read, failed files are not in the manifest,
+	// so the next update reads them again
+	mf := manifest.New()
+	failed, binary := indexFiles(allFiles, c.Int("workers"), progress, mf, b.AddDocument)
+	progress.Stop()
+	end()
+	log.Debug().Int("spills", b.Spills()).Int("failed", len(failed)).Msg("index built")
+	for _, e := range failed {
+		report.Fail(e.file, e.err)
+	}
+	for _, e := range binary {
+		report.Skip(e.file, e.err.Error())
+	}
+
+	end = report.Start("write")
+	if c.String("index") != "" {
+		if err := collectAndWriteMap(b, c.String("index"), st); err != nil {
+			log.Err(err).Str("filename", c.String("index")).Msg("can not save data to file")
+			return nil
+		}
+		log.Info().Msg("index saved")
+
+		if err := mf.Save(manifest.Path(c.String("index"))); err != nil {
+			log.Err(err).Str("filename", manifest.Path(c.String("index"))).Msg("can not save manifest")
+			return nil
+		}
+		if info, err := os.Stat(c.String("index")); err == nil {
+			report.IndexSize = info.Size()
+		}
 	} else {
-		log.Debug().Strs("files", allFiles).Msg("folder parsed")
+		repo, err := database.NewIndexRepository(context.Background(), config.Load())
+		if err != nil {
+			log.Err(err).Msg("can not open database connection")
+			return nil
+		}
 
-		m := collectWordData(allFiles)
+		if err := repo.DropIndex(context.Background()); err != nil {
+			log.Err(err).Msg("can not drop index connection")
+			return nil
+		}
 
-		log.Debug().Interface("index", m).Msg("index built")
+		if err := repo.SaveBuilder(context.Background(), b); err != nil {
+			log.Err(err).Msg("can not save index")
+			return nil
+		}
 
-		if c.String("index") != "" {
-			if err := collectAndWriteMap(m, c.String("index")); err != nil {
-				log.Err(err).Str("filename", c.String("index")).Msg("can not save data to file")
-				return nil
-			}
-			log.Info().Msg("index saved")
+		if err := repo.SaveManifest(context.Background(), mf); err != nil {
+			log.Err(err).Msg("can not save manifest")
+			return nil
+		}
+	}
+	end()
 
-		} else {
-			repo, err := database.NewIndexRepository(context.Background(), config.Load())
-			if err != nil {
-				log.Err(err).Msg("can not open database connection")
-				return nil
-			}
+	docs := b.Docs()
+	report.Documents, report.Terms = len(docs), b.Terms()
+	for _, l := range docs {
+		report.Tokens += l
+	}
+
+	log.Info().Msg("build done")
+
+	report.Print(os.Stderr)
+	if c.String("stats-file") != "" {
+		if err := report.Save(c.String("stats-file")); err != nil {
+			log.Err(err).Str("filename", c.String("stats-file")).Msg("can not save build statistics")
+		}
+	}
+
+	if len(failed) > 0 {
+		return cli.Exit(fileErrorsSummary(len(allFiles), failed), 1)
+	}
+	return nil
+}
+
+func update(c *cli.Context) error {
+
+	log.Info().Msg("update mode run")
+
+	log.Debug().
+		Str("index file", c.String("index")).
+		Str("source folder", c.String("sources")).
+		Msg("update run")
+
+	if err := checkFlags(c, "sources"); err != nil {
+		log.Err(err).Strs("context flags", c.FlagNames()).Msg("error while checking context")
+		return nil
+	}
+	sel, err := newSelector(c)
+	if err != nil {
+		log.Err(err).Msg("error while checking context")
+		return nil
+	}
+	allFiles, _, _, err := sel.Walk()
+	if err != nil {
+		log.Err(err).Str(" directory", c.String("sources")).Msg("can not read files list")
+		return nil
+	}
+
+	var failed []fileError
+	var read int
+	if c.String("index") != "" {
+		read, failed, err = updateFile(allFiles, c.String("index"), c.Int("workers"))
+	} else {
+		read, failed, err = updateDatabase(allFiles, c.Int("workers"))
+	}
+	if err != nil {
+		log.Err(err).Msg("can not update index")
+		return nil
+	}
+
+	log.Info().Msg("update done")
+
+	if len(failed) > 0 {
+		return cli.Exit(fileErrorsSummary(read, failed), 1)
+	}
+	return nil
+}
+
+// updateFile reindexes changed files of the index file,
+// it returns number of the read files and the files which can not be indexed
+func updateFile(files []string, indexFile string, workers int) (int, []fileError, error) {
+	old, err := manifest.Load(manifest.Path(indexFile))
+	if err != nil {
+		return 0, nil, fmt.Errorf("can not load manifest, run build first: %w", err)
+	}
+	mf, changes, err := manifest.Scan(files, old)
+	if err != nil {
+		return 0, nil, err
+	}
+	logChanges(changes)
+	if changes.Empty() {
+		return 0, nil, nil
+	}
+
+	ind, st, err := readIndexFile(indexFile, "")
+	if err != nil {
+		return 0, nil, err
+	}
+	read := append(changes.Added, changes.Modified...)
+	changed, failed := collectWordData(read, workers, ind.Analyzer(), mf)
+	ind.Replace(changes.Stale(), changed)
+
+	if err := collectAndWriteMap(ind, indexFile, st); err != nil {
+		return 0, nil, err
+	}
+	if err := dropTexts(indexFile, changes.Stale()); err != nil {
+		return 0, nil, err
+	}
+	skipFailed(mf, failed)
+	return len(read), failed, mf.Save(manifest.Path(indexFile))
+}
+
+// updateDatabase reindexes changed files of the database,
+// it returns number of the read files and the files which can not be indexed
+func updateDatabase(files []string, workers int) (int, []fileError, error) {
+	repo, err := database.NewIndexRepository(context.Background(), config.Load())
+	if err != nil {
+		return 0, nil, err
+	}
+	old, err := repo.LoadManifest(context.Background())
+	if err != nil {
+		return 0, nil, err
+	}
+	mf, changes, err := manifest.Scan(files, old)
+	if err != nil {
+		return 0, nil, err
+	}
+	logChanges(changes)
+	if changes.Empty() {
+		return 0, nil, nil
+	}
+
+	if err := repo.RemoveFiles(context.Background(), changes.Stale()); err != nil {
+		return 0, nil, err
+	}
+	read := append(changes.Added, changes.Modified...)
+	changed, failed := collectWordData(read, workers, repo.Analyzer(), mf)
+	if err := repo.MergeIndex(context.Background(), changed); err != nil {
+		return 0, nil, err
+	}
+	if err := repo.RecountCorpus(context.Background()); err != nil {
+		return 0, nil, err
+	}
+	skipFailed(mf, failed)
+	return len(read), failed, repo.SaveManifest(context.Background(), mf)
+}
 
-			if err := repo.DropIndex(context.Background()); err != nil {
-				log.Err(err).Msg("can not drop index connection")
-				return nil
+func logChanges(changes *manifest.Changes) {
+	log.Info().
+		Int("added", len(changes.Added)).
+		Int("modified", len(changes.Modified)).
+		Int("deleted", len(changes.Deleted)).
+		Msg("files changes found")
+	log.Debug().
+		Strs("added", changes.Added).
+		Strs("modified", changes.Modified).
+		Strs("deleted", changes.Deleted).
+		Msg("changed files")
+}
+
+// fileError describes the file which can not be indexed
+type fileError struct {
+	file string
+	err  error
+}
+
+// indexFiles passes the files to the pool of workers through a bounded queue,
+// so only a few files are open and read at once. Errors are collected for every file and sorted by its name,
+// files with binary content are returned separately as skipped. Entries of the read files are set in the manifest
+func indexFiles(fileNames []string, workers int, progress *stats.Progress, mf *manifest.Manifest,
+	add func(file string, reader io.Reader) error) ([]fileError, []fileError) {
+	if workers < 1 {
+		workers = 1
+	}
+	queue := make(chan string, workers)
+
+	var m sync.Mutex
+	var errs, skipped []fileError
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for fn := range queue {
+				err := indexFile(fn, progress, mf, add)
+				progress.FileDone()
+				if err == nil {
+					continue
+				}
+				log.Debug().Err(err).Str("filename", fn).Msg("can not index the file")
+				m.Lock()
+				if err == sources.ErrBinary {
+					skipped = append(skipped, fileError{file: fn, err: err})
+				} else {
+					errs = append(errs, fileError{file: fn, err: err})
+				}
+				m.Unlock()
 			}
+		}()
+	}
+	log.Debug().Int("workers", workers).Int("files", len(fileNames)).Msg("indexing started")
+
+	for _, fn := range fileNames {
+		queue <- fn
+	}
+	close(queue)
+	wg.Wait()
 
-			if err := repo.SaveIndex(context.Background(), m); err != nil {
-				log.Err(err).Msg("can not save index")
-				return nil
+	sortFileErrors(errs)
+	sortFileErrors(skipped)
+	return errs, skipped
+}
+
+func sortFileErrors(errs []fileError) {
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].file < errs[j].file
+	})
+}
+
+// indexFile passes the plain text of the file to the function, plain text files with binary content are not read.
+// The entry of the indexed or binary file is set in the manifest if it is given, it has the stat and the hash
+// of the content which was read, so changes made after that are found by the next update
+func indexFile(fn string, progress *stats.Progress, mf *manifest.Manifest,
+	add func(file string, reader io.Reader) error) error {
+	file, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	tracker, err := manifest.Track(file)
+	if err != nil {
+		return err
+	}
+	record := func() error {
+		if mf == nil {
+			return nil
+		}
+		e, err := tracker.Entry()
+		if err != nil {
+			return err
+		}
+		mf.Set(fn, e)
+		return nil
+	}
+
+	var reader io.Reader = tracker
+	ex := extract.ForFile(fn)
+	// documents of other types are binary themselves, only plain text is checked
+	if ex == extract.Text {
+		if reader, err = sources.Text(tracker); err == sources.ErrBinary {
+			if err := record(); err != nil {
+				return err
 			}
+			return sources.ErrBinary
 		}
+		if err != nil {
+			return err
+		}
+	}
+	text, err := extract.TextReader(ex, progress.Reader(reader))
+	if err != nil {
+		return err
+	}
+	if err := add(fn, text); err != nil {
+		return err
 	}
+	return record()
+}
 
-	log.Info().Msg("build done")
+// fileErrorsSummary describes the failed files, the indexed words of a partly read file are kept
+func fileErrorsSummary(total int, errs []fileError) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d of %d files can not be indexed:", len(errs), total)
+	for _, e := range errs {
+		fmt.Fprintf(&sb, "\n  %s: %v", e.file, e.err)
+	}
+	return sb.String()
+}
 
-	return nil
+// skipFailed removes the failed files from the manifest, so the next update reads them again
+func skipFailed(mf *manifest.Manifest, errs []fileError) {
+	for _, e := range errs {
+		mf.Delete(e.file)
+	}
 }
 
-func collectWordData(fileNames []string) *index.Index {
+// collectWordData indexes the files, entries of the read files replace their entries in the manifest
+func collectWordData(fileNames []string, workers int, analyzer *analysis.Analyzer,
+	mf *manifest.Manifest) (*index.Index, []fileError) {
 	m := index.NewIndex()
+	m.SetAnalyzer(analyzer)
 
+	var errs []fileError
 	m.OpenApplyAndListenChannel(func(wg *sync.WaitGroup) {
-		for i := range fileNames {
-			wg.Add(1)
-			go readFileByWords(wg, m, fileNames[i])
-		}
-		log.Debug().Msg(fmt.Sprintf("goroutine count %d", len(fileNames)))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs, _ = indexFiles(fileNames, workers, nil, mf, func(file string, reader io.Reader) error {
+				return m.MapAndCleanWords(reader, file)
+			})
+		}()
 	})
 
-	return m
+	return m, errs
+}
+
+// storage describes how the index file is encoded
+type storage struct {
+	format      index.Format
+	compression index.Compression
+}
+
+// parseStorage reads the format and the compression of the index file from the flags,
+// the compression is chosen by the file extension if it is not set
+func parseStorage(c *cli.Context) (storage, error) {
+	format, err := index.ParseFormat(c.String("format"))
+	if err != nil {
+		return storage{}, err
+	}
+	compression := index.CompressionFromPath(c.String("index"))
+	if c.String("compress") != "" {
+		if compression, err = index.ParseCompression(c.String("compress")); err != nil {
+			return storage{}, err
+		}
+	}
+	return storage{format: format, compression: compression}, nil
+}
+
+// indexWriter is the index or the builder which can be saved to the file
+type indexWriter interface {
+	ToFile(encoder index.Encoder, opts ...index.FileOption) error
 }
 
-func collectAndWriteMap(ind *index.Index, indexFile string) error {
-	log.Info().Str("file", indexFile).Int("index length", len(ind.Data)).
-		Msg("writing index to file in csv format")
-	recordFile, _ := os.Create(indexFile)
-	return ind.ToFile(index.NewCsvEncoder(recordFile))
+// writeIndex encodes and compresses the index while it is written, so the whole file is never kept in memory
+func writeIndex(ind indexWriter, w io.Writer, st storage) error {
+	cw, err := index.NewCompressWriter(st.compression, w)
+	if err != nil {
+		return err
+	}
+	encoder, err := index.NewEncoder(st.format, cw)
+	if err != nil {
+		return err
+	}
+	if err := ind.ToFile(encoder); err != nil {
+		return err
+	}
+	return cw.Close()
 }
 
+func collectAndWriteMap(ind indexWriter, indexFile string, st storage) error {
+	log.Info().Str("file", indexFile).Str("format", string(st.format)).Str("compression", string(st.compression)).
+		Msg("writing index to file")
+	// write to temporary file first, so the old index stays untouched if something goes wrong
+	tmpFile := indexFile + ".tmp"
+	recordFile, err := os.Create(tmpFile)
+	if err != nil {
+		return err
+	}
+	if err := writeIndex(ind, recordFile, st); err != nil {
+		recordFile.Close()
+		return err
+	}
+	if err := recordFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, indexFile)
+}
+
+// FileIndexed serves the index loaded from the file.
+// Every search gets its own snapshot of the index, so it is not affected by concurrent changes
 type FileIndexed struct {
-	i *index.Index
+	// m serializes saving of the index
+	m     sync.Mutex
+	i     *index.Index
+	file  string
+	st    storage
+	dirty int32
+	// sel selects the indexed files, their manifest is saved with the index if it is set
+	sel *sources.Selector
+	// mf describes the indexed files as they were read
+	mf *manifest.Manifest
+	// texts of the documents added through the api are saved next to the index file
+	texts *documentTexts
+}
+
+func newFileIndexed(i *index.Index, file string, st storage, sel *sources.Selector) (*FileIndexed, error) {
+	texts, err := loadTexts(file)
+	if err != nil {
+		return nil, err
+	}
+	f := &FileIndexed{i: i, file: file, st: st, sel: sel, texts: texts}
+	if sel != nil {
+		if f.mf, err = manifest.Load(manifest.Path(file)); err != nil {
+			return nil, fmt.Errorf("can not load manifest: %w", err)
+		}
+	}
+	return f, nil
+}
+
+// GetIndex returns the snapshot with postings of the words, negated words of the query have to be among them
+func (f *FileIndexed) GetIndex(str ...string) (*index.Index, error) {
+	return f.i.Snapshot(str...), nil
+}
+
+// DocLengths returns lengths of all documents of the index
+func (f *FileIndexed) DocLengths() (map[string]int, error) {
+	return f.i.DocLengths(), nil
+}
+
+// OnChange sets the function called with the files changed by the api and the watcher
+func (f *FileIndexed) OnChange(fn func(files []string)) {
+	f.i.OnChange(fn)
+}
+
+// Analyzer returns the analyzer of the index file
+func (f *FileIndexed) Analyzer() *analysis.Analyzer {
+	return f.i.Analyzer()
+}
+
+// FuzzyTerms returns terms of the index within the edit distance of the word
+func (f *FileIndexed) FuzzyTerms(word string, distance int) ([]index.FuzzyTerm, error) {
+	return f.i.FuzzyTerms(word, distance)
+}
+
+// AddDocument indexes the document and keeps its text, the changed index is saved to the file by Persist
+func (f *FileIndexed) AddDocument(_ context.Context, file string, reader io.Reader) error {
+	text, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	if err := f.i.AddDocument(file, bytes.NewReader(text)); err != nil {
+		return err
+	}
+	f.texts.set(file, text)
+	atomic.StoreInt32(&f.dirty, 1)
+	return nil
+}
+
+// RemoveDocument removes the document, the changed index is saved to the file by Persist
+func (f *FileIndexed) RemoveDocument(_ context.Context, file string) error {
+	f.i.RemoveDocument(file)
+	f.texts.remove(file)
+	atomic.StoreInt32(&f.dirty, 1)
+	return nil
+}
+
+// Text returns the text of the document added through the api
+func (f *FileIndexed) Text(file string) ([]byte, bool, error) {
+	text, ok := f.texts.get(file)
+	return text, ok, nil
+}
+
+// persistEvery saves the changed index every interval until the context is done
+func (f *FileIndexed) persistEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := f.Persist(); err != nil {
+				log.Err(err).Str("file", f.file).Msg("can not save index")
+			}
+		}
+	}
+}
+
+// ApplyBatch reindexes changed files, removed paths may be directories.
+// Changed files are read before the index is locked and all changes are applied at once
+func (f *FileIndexed) ApplyBatch(b watch.Batch) {
+	log.Info().Int("updated", len(b.Updated)).Int("removed", len(b.Removed)).Msg("apply file changes")
+
+	var removed []string
+	files := f.i.Files()
+	for _, path := range b.Removed {
+		prefix := path + string(filepath.Separator)
+		for _, file := range files {
+			if file == path || strings.HasPrefix(file, prefix) {
+				removed = append(removed, file)
+			}
+		}
+	}
+
+	added := index.NewIndex()
+	added.SetAnalyzer(f.i.Analyzer())
+	read := manifest.New()
+	for _, fn := range b.Updated {
+		removed = append(removed, fn)
+		if f.sel != nil {
+			if reason, err := f.sel.Check(fn); err != nil || reason != "" {
+				log.Debug().Err(err).Str("filename", fn).Str("reason", reason).Msg("file is skipped")
+				continue
+			}
+		}
+		if err := indexFile(fn, nil, read, added.AddDocument); err != nil {
+			log.Err(err).Str("filename", fn).Msg("can't index file")
+		}
+	}
+
+	f.i.Replace(removed, added)
+	if f.mf != nil {
+		f.mf.Delete(removed...)
+		for fn, e := range read.Files {
+			f.mf.Set(fn, e)
+		}
+	}
+	// the files on disk replace the documents added through the api
+	f.texts.remove(removed...)
+	atomic.StoreInt32(&f.dirty, 1)
+}
+
+// Persist saves the changed index and the manifest of the sources to the files
+func (f *FileIndexed) Persist() error {
+	f.m.Lock()
+	defer f.m.Unlock()
+	if !atomic.CompareAndSwapInt32(&f.dirty, 1, 0) {
+		return nil
+	}
+	if err := collectAndWriteMap(f.i, f.file, f.st); err != nil {
+		atomic.StoreInt32(&f.dirty, 1)
+		return err
+	}
+	if err := f.texts.save(f.file); err != nil {
+		atomic.StoreInt32(&f.dirty, 1)
+		return err
+	}
+	if f.mf == nil {
+		return nil
+	}
+	return f.mf.Save(manifest.Path(f.file))
+}
+
+// documentTexts keeps the extracted text of the documents added through the api
+type documentTexts struct {
+	m     sync.RWMutex
+	texts map[string][]byte
+}
+
+// textsPath returns the path of the texts file for the index file
+func textsPath(indexFile string) string {
+	return indexFile + ".texts"
+}
+
+// loadTexts reads the texts saved next to the index file, there are none if the file does not exist
+func loadTexts(indexFile string) (*documentTexts, error) {
+	t := &documentTexts{texts: make(map[string][]byte)}
+	f, err := os.Open(textsPath(indexFile))
+	if os.IsNotExist(err) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err := json.NewDecoder(f).Decode(&t.texts); err != nil {
+		return nil, fmt.Errorf("texts file %s: %w", textsPath(indexFile), err)
+	}
+	return t, nil
+}
+
+// dropTexts removes texts of the files which are indexed from disk again
+func dropTexts(indexFile string, files []string) error {
+	t, err := loadTexts(indexFile)
+	if err != nil || len(t.texts) == 0 {
+		return err
+	}
+	t.remove(files...)
+	return t.save(indexFile)
+}
+
+func (t *documentTexts) get(file string) ([]byte, bool) {
+	t.m.RLock()
+	defer t.m.RUnlock()
+	text, ok := t.texts[file]
+	return text, ok
 }
 
-func (f *FileIndexed) GetIndex(_ ...string) (*index.Index, error) {
-	return f.i, nil
+func (t *documentTexts) set(file string, text []byte) {
+	t.m.Lock()
+	defer t.m.Unlock()
+	t.texts[file] = text
+}
+
+func (t *documentTexts) remove(files ...string) {
+	t.m.Lock()
+	defer t.m.Unlock()
+	for _, file := range files {
+		delete(t.texts, file)
+	}
+}
+
+// save writes the texts next to the index file, the file is removed if there are no texts
+func (t *documentTexts) save(indexFile string) error {
+	t.m.RLock()
+	defer t.m.RUnlock()
+	path := textsPath(indexFile)
+	if len(t.texts) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	tmpFile := path + ".tmp"
+	f, err := os.Create(tmpFile)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(f).Encode(t.texts); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, path)
 }
 
 func search(c *cli.Context) error {
@@ -193,13 +987,28 @@ func search(c *cli.Context) error {
 
 	var wapp *web.App
 	if c.String("index") != "" {
-		data, err := readCSVFile(c.String("index"))
+		var opts []index.FileOption
+		if c.Bool("strict") {
+			opts = append(opts, index.Strict())
+		}
+		indexed, err := openIndexed(c.String("index"), c.String("format"), opts...)
 		if err != nil {
-			log.Err(err).Str("file", c.String("index")).Msg("couldn't open or read the csv file")
-			return nil
+			log.Err(err).Str("file", c.String("index")).Msg("couldn't open or read the index file")
+			return cli.Exit(fmt.Sprintf("can not load index %s: %v", c.String("index"), err), 1)
+		}
+		// documents changed through the api are saved in the background and on shutdown
+		if fi, ok := indexed.(*FileIndexed); ok {
+			ctx, cancel := context.WithCancel(context.Background())
+			closer.Bind(func() {
+				cancel()
+				if err := fi.Persist(); err != nil {
+					log.Err(err).Str("file", c.String("index")).Msg("can not save index")
+				}
+			})
+			go fi.persistEvery(ctx, c.Duration("persist"))
 		}
 
-		wapp, err = web.NewApp(cfg, &FileIndexed{i: data})
+		wapp, err = web.NewApp(cfg, indexed)
 		if err != nil {
 			log.Err(err).Msg("couldn't start web app")
 			return nil
@@ -221,42 +1030,248 @@ func search(c *cli.Context) error {
 	return nil
 }
 
-func readCSVFile(filePath string) (*index.Index, error) {
-	csvFile, err := os.Open(filePath)
+func verify(c *cli.Context) error {
+
+	log.Info().Msg("verify mode run")
+
+	reader, compression, closeFile, err := openIndexReader(c.String("index"))
 	if err != nil {
-		return nil, err
+		return cli.Exit(fmt.Sprintf("can not open index file: %v", err), 2)
 	}
-	data := index.NewIndex()
-	decoder := index.NewCsvDecoder(csvFile)
-	err = data.FromFile(decoder)
-	return data, err
+	defer closeFile()
+
+	var format index.Format
+	if c.String("format") != "" {
+		format, err = index.ParseFormat(c.String("format"))
+	} else {
+		format, err = index.DetectFormat(reader)
+	}
+	if err != nil {
+		return cli.Exit(err.Error(), 2)
+	}
+	decoder, err := index.NewDecoder(format, reader)
+	if err != nil {
+		return cli.Exit(err.Error(), 2)
+	}
+
+	report := index.Verify(decoder)
+	if _, err := io.Copy(ioutil.Discard, reader); err != nil {
+		report.Problems = append(report.Problems, index.Problem{Msg: fmt.Sprintf("%s stream: %v", compression, err)})
+	}
+
+	fmt.Printf("format: %s, compression: %s\n", format, compression)
+	fmt.Printf("rows: %d, terms: %d, documents: %d\n", report.Rows, report.Terms, report.Docs)
+	for _, p := range report.Problems {
+		fmt.Println(p)
+	}
+	if !report.OK() {
+		return cli.Exit(fmt.Sprintf("index file is damaged: %d problems found", len(report.Problems)), 1)
+	}
+	fmt.Println("index file is ok")
+	return nil
 }
 
-func filePathWalkDir(root string) ([]string, error) {
-	var files []string
-	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
-		if info == nil {
-			return errors.New("")
+func watchSources(c *cli.Context) error {
+
+	log.Info().Msg("watch mode run")
+
+	log.Debug().
+		Str("index file", c.String("index")).
+		Str("source folder", c.String("sources")).
+		Dur("persist", c.Duration("persist")).
+		Msg("watch run")
+
+	if err := checkFlags(c, "sources", "index"); err != nil {
+		log.Err(err).Strs("context flags", c.FlagNames()).Msg("error while checking context")
+		return nil
+	}
+
+	// catch up with the changes made while the index was not watched
+	sel, err := newSelector(c)
+	if err != nil {
+		log.Err(err).Msg("error while checking context")
+		return nil
+	}
+	allFiles, _, _, err := sel.Walk()
+	if err != nil {
+		log.Err(err).Str(" directory", c.String("sources")).Msg("can not read files list")
+		return nil
+	}
+	_, failed, err := updateFile(allFiles, c.String("index"), c.Int("workers"))
+	if err != nil {
+		log.Err(err).Msg("can not update index")
+		return nil
+	}
+	for _, e := range failed {
+		log.Warn().Err(e.err).Str("filename", e.file).Msg("can not index the file")
+	}
+
+	data, st, err := readIndexFile(c.String("index"), "")
+	if err != nil {
+		log.Err(err).Str("file", c.String("index")).Msg("couldn't open or read the index file")
+		return nil
+	}
+	fi, err := newFileIndexed(data, c.String("index"), st, sel)
+	if err != nil {
+		log.Err(err).Str("file", c.String("index")).Msg("couldn't read texts of the documents")
+		return nil
+	}
+
+	w, err := watch.New(sel, c.Duration("delay"))
+	if err != nil {
+		log.Err(err).Str("directory", c.String("sources")).Msg("can not watch files")
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	closer.Bind(func() {
+		cancel()
+		if err := w.Close(); err != nil {
+			log.Err(err).Msg("error while closing watcher")
 		}
-		if !info.IsDir() {
-			files = append(files, path)
+		if err := fi.Persist(); err != nil {
+			log.Err(err).Str("file", c.String("index")).Msg("can not save index")
 		}
-		return nil
 	})
-	return files, err
+
+	go func() {
+		if err := w.Run(ctx, fi.ApplyBatch); err != nil && err != context.Canceled {
+			log.Err(err).Msg("error while watching files")
+		}
+	}()
+
+	go fi.persistEvery(ctx, c.Duration("persist"))
+
+	wapp, err := web.NewApp(config.Load(), fi)
+	if err != nil {
+		log.Err(err).Msg("couldn't start web app")
+		return nil
+	}
+	wapp.Run()
+	return nil
 }
 
-func readFileByWords(wg *sync.WaitGroup, ind *index.Index, fn string) {
-	defer wg.Done()
-	file, err := os.Open(fn)
+// openIndexed serves uncompressed binary segments directly from the mapped file,
+// other index files are loaded into memory. The format is detected from the file if it is not set
+func openIndexed(filePath, formatName string, opts ...index.FileOption) (web.Indexed, error) {
+	var format index.Format
+	if formatName != "" {
+		var err error
+		if format, err = index.ParseFormat(formatName); err != nil {
+			return nil, err
+		}
+	}
+	st, err := detectStorage(filePath)
 	if err != nil {
-		log.Err(err).Str("filename", fn).Msg("can't open file")
-		return
+		return nil, err
+	}
+	if format != "" {
+		st.format = format
 	}
 
-	defer file.Close()
+	if st.format == index.FormatBinary && st.compression == index.CompressionNone {
+		s, err := index.OpenSegment(filePath)
+		if err != nil {
+			return nil, err
+		}
+		closer.Bind(func() {
+			if err := s.Close(); err != nil {
+				log.Err(err).Str("file", filePath).Msg("can not close segment")
+			}
+		})
+		return s, nil
+	}
 
-	ind.MapAndCleanWords(file, fn)
+	data, st, err := readIndexFile(filePath, format, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return newFileIndexed(data, filePath, st, nil)
+}
+
+// openIndexReader opens the index file and unwraps its compression
+func openIndexReader(filePath string) (*bufio.Reader, index.Compression, func(), error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	r, compression, err := index.NewDecompressReader(bufio.NewReader(f))
+	if err != nil {
+		f.Close()
+		return nil, "", nil, err
+	}
+	return bufio.NewReader(r), compression, func() {
+		r.Close()
+		f.Close()
+	}, nil
+}
+
+func detectStorage(filePath string) (storage, error) {
+	reader, compression, closeFile, err := openIndexReader(filePath)
+	if err != nil {
+		return storage{}, err
+	}
+	defer closeFile()
+	format, err := index.DetectFormat(reader)
+	return storage{format: format, compression: compression}, err
+}
+
+// progressRows is the number of rows between progress messages while the index file is read
+const progressRows = 100000
+
+// readIndexFile loads the index file, the format is detected from the file if it is not set.
+// The file is read up to the end, so the checksum of the compressed file is always validated.
+// Damaged rows are logged and skipped unless the strict option is given
+func readIndexFile(filePath string, format index.Format, opts ...index.FileOption) (*index.Index, storage, error) {
+	reader, compression, closeFile, err := openIndexReader(filePath)
+	if err != nil {
+		return nil, storage{}, err
+	}
+	defer closeFile()
+
+	if format == "" {
+		if format, err = index.DetectFormat(reader); err != nil {
+			return nil, storage{}, err
+		}
+	}
+	st := storage{format: format, compression: compression}
+	log.Debug().Str("file", filePath).Str("format", string(format)).Str("compression", string(compression)).
+		Msg("reading index file")
+
+	decoder, err := index.NewDecoder(format, reader)
+	if err != nil {
+		return nil, st, err
+	}
+	data := index.NewIndex()
+	opts = append(opts, index.Progress(func(rows int) {
+		if rows%progressRows == 0 {
+			log.Debug().Str("file", filePath).Int("rows", rows).Msg("reading index file")
+		}
+	}))
+	if err := data.FromFile(decoder, opts...); err != nil {
+		var rowErrs *index.RowErrors
+		if !errors.As(err, &rowErrs) {
+			return nil, st, err
+		}
+		// damaged rows are fatal only in strict mode
+		log.Warn().Err(err).Str("file", filePath).Int("rows", rowErrs.Total).Msg("damaged rows of the index file are skipped")
+	}
+	if _, err := io.Copy(ioutil.Discard, reader); err != nil {
+		return nil, st, err
+	}
+	return data, st, nil
+}
+
+// newSelector selects the files of the sources folder by the flags
+func newSelector(c *cli.Context) (*sources.Selector, error) {
+	maxSize, err := util.ParseSize(c.String("max-file-size"))
+	if err != nil {
+		return nil, err
+	}
+	return sources.NewSelector(c.String("sources"), sources.Filter{
+		Include: c.StringSlice("include"),
+		Exclude: c.StringSlice("exclude"),
+		MaxSize: maxSize,
+	}), nil
 }
 
 func checkFlags(c *cli.Context, str ...string) error {
@@ -0,0 +1,207 @@
+// Package manifest keeps track of the indexed files to find out
+// which of them were added, modified or deleted since the last build.
+//
+// A file is considered modified if its size or modification time changed
+// and the hash of its content differs from the stored one.
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Entry describes the state of the indexed file
+type Entry struct {
+	ModTime time.Time `json:"mod_time"`
+	Size    int64     `json:"size"`
+	Hash    string    `json:"hash"`
+}
+
+// Manifest contains states of all indexed files
+type Manifest struct {
+	// m guards the files changed by Set and Delete while the manifest is written
+	m     sync.Mutex
+	Files map[string]*Entry `json:"files"`
+}
+
+// Changes describes files changed since the manifest was saved
+type Changes struct {
+	Added    []string
+	Modified []string
+	Deleted  []string
+}
+
+// Empty reports whether there are no changes
+func (c *Changes) Empty() bool {
+	return len(c.Added)+len(c.Modified)+len(c.Deleted) == 0
+}
+
+// Stale returns the files whose postings are removed before the changed files are indexed again.
+// Added files are among them, a file which failed to be read is not in the manifest,
+// but the postings read before the failure are kept in the index
+func (c *Changes) Stale() []string {
+	res := make([]string, 0, len(c.Added)+len(c.Modified)+len(c.Deleted))
+	res = append(res, c.Added...)
+	res = append(res, c.Modified...)
+	return append(res, c.Deleted...)
+}
+
+// New creates empty manifest
+func New() *Manifest {
+	return &Manifest{Files: make(map[string]*Entry)}
+}
+
+// Path returns manifest file path for the index file
+func Path(indexFile string) string {
+	return indexFile + ".manifest"
+}
+
+// Read decodes manifest from json
+func Read(r io.Reader) (*Manifest, error) {
+	m := New()
+	if err := json.NewDecoder(r).Decode(m); err != nil {
+		return nil, err
+	}
+	if m.Files == nil {
+		m.Files = make(map[string]*Entry)
+	}
+	return m, nil
+}
+
+// Write encodes manifest to json
+func (m *Manifest) Write(w io.Writer) error {
+	m.m.Lock()
+	defer m.m.Unlock()
+	return json.NewEncoder(w).Encode(m)
+}
+
+// Set records the entry of the file, it may be called concurrently
+func (m *Manifest) Set(file string, e *Entry) {
+	m.m.Lock()
+	defer m.m.Unlock()
+	m.Files[file] = e
+}
+
+// Delete removes the files, so the next update reads them again
+func (m *Manifest) Delete(files ...string) {
+	m.m.Lock()
+	defer m.m.Unlock()
+	for _, file := range files {
+		delete(m.Files, file)
+	}
+}
+
+// Load reads manifest from the file
+func Load(path string) (*Manifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// Save writes manifest to the file
+func (m *Manifest) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := m.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Scan compares the files with the old manifest and returns the new manifest with the list of changes.
+// Content of the file is hashed only if it is new or its size or modification time changed
+func Scan(files []string, old *Manifest) (*Manifest, *Changes, error) {
+	if old == nil {
+		old = New()
+	}
+	m := New()
+	changes := &Changes{}
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		prev, ok := old.Files[file]
+		if ok && prev.Size == info.Size() && prev.ModTime.Equal(info.ModTime()) {
+			m.Files[file] = prev
+			continue
+		}
+		hash, err := hashFile(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		m.Files[file] = &Entry{ModTime: info.ModTime(), Size: info.Size(), Hash: hash}
+		switch {
+		case !ok:
+			changes.Added = append(changes.Added, file)
+		case prev.Hash != hash:
+			changes.Modified = append(changes.Modified, file)
+		}
+	}
+	for file := range old.Files {
+		if _, ok := m.Files[file]; !ok {
+			changes.Deleted = append(changes.Deleted, file)
+		}
+	}
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Modified)
+	sort.Strings(changes.Deleted)
+	return m, changes, nil
+}
+
+// Tracker hashes the content of the opened file while it is read,
+// so its entry describes the indexed content even if the file is changed later
+type Tracker struct {
+	r    io.Reader
+	h    hash.Hash
+	info os.FileInfo
+}
+
+// Track takes the stat of the opened file before its content is read
+func Track(f *os.File) (*Tracker, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	return &Tracker{r: io.TeeReader(f, h), h: h, info: info}, nil
+}
+
+func (t *Tracker) Read(p []byte) (int, error) {
+	return t.r.Read(p)
+}
+
+// Entry hashes the rest of the file and returns its entry
+func (t *Tracker) Entry() (*Entry, error) {
+	if _, err := io.Copy(ioutil.Discard, t.r); err != nil {
+		return nil, err
+	}
+	return &Entry{ModTime: t.info.ModTime(), Size: t.info.Size(), Hash: hex.EncodeToString(t.h.Sum(nil))}, nil
+}
+
+func hashFile(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
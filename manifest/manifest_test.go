@@ -0,0 +1,117 @@
+package manifest
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, path, text string) {
+	require.NoError(t, ioutil.WriteFile(path, []byte(text), 0644))
+}
+
+func TestScan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manifest")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	same := filepath.Join(dir, "same")
+	touched := filepath.Join(dir, "touched")
+	changed := filepath.Join(dir, "changed")
+	deleted := filepath.Join(dir, "deleted")
+	added := filepath.Join(dir, "added")
+	writeFile(t, same, "same text")
+	writeFile(t, touched, "touched text")
+	writeFile(t, changed, "changed text")
+	writeFile(t, deleted, "deleted text")
+
+	m, changes, err := Scan([]string{same, touched, changed, deleted}, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{changed, deleted, same, touched}, changes.Added)
+	require.Len(t, m.Files, 4)
+
+	later := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(touched, later, later))
+	writeFile(t, changed, "changed text!")
+	require.NoError(t, os.Remove(deleted))
+	writeFile(t, added, "added text")
+
+	m2, changes, err := Scan([]string{same, touched, changed, added}, m)
+	require.NoError(t, err)
+	require.Equal(t, &Changes{
+		Added:    []string{added},
+		Modified: []string{changed},
+		Deleted:  []string{deleted},
+	}, changes)
+	require.Equal(t, []string{added, changed, deleted}, changes.Stale(), "added files may have partial postings")
+	require.Equal(t, m.Files[same], m2.Files[same])
+	require.Equal(t, m.Files[touched].Hash, m2.Files[touched].Hash)
+	require.True(t, m2.Files[touched].ModTime.Equal(later))
+
+	_, changes, err = Scan([]string{same, touched, changed, added}, m2)
+	require.NoError(t, err)
+	require.True(t, changes.Empty())
+
+	_, _, err = Scan([]string{deleted}, m2)
+	require.Error(t, err)
+}
+
+func TestReadWrite(t *testing.T) {
+	m := New()
+	m.Files["file"] = &Entry{ModTime: time.Unix(100, 0).UTC(), Size: 10, Hash: "abc"}
+
+	var buf bytes.Buffer
+	require.NoError(t, m.Write(&buf))
+	actual, err := Read(&buf)
+	require.NoError(t, err)
+	require.Equal(t, m, actual)
+
+	actual, err = Read(bytes.NewBufferString("{}"))
+	require.NoError(t, err)
+	require.Equal(t, New(), actual)
+
+	_, err = Read(bytes.NewBufferString("{"))
+	require.Error(t, err)
+}
+
+func TestTrack(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manifest")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "file")
+	writeFile(t, path, "indexed text")
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	tracker, err := Track(f)
+	require.NoError(t, err)
+	head := make([]byte, 7)
+	_, err = tracker.Read(head)
+	require.NoError(t, err)
+	require.Equal(t, "indexed", string(head))
+	e, err := tracker.Entry()
+	require.NoError(t, err)
+	m := New()
+	m.Set(path, e)
+
+	// the file is changed after it was read, but its size stays the same
+	writeFile(t, path, "changed text")
+	require.NoError(t, os.Chtimes(path, e.ModTime, e.ModTime))
+	hash, err := hashFile(path)
+	require.NoError(t, err)
+	require.NotEqual(t, hash, e.Hash, "the hash is taken from the read content")
+
+	require.NoError(t, os.Chtimes(path, e.ModTime.Add(time.Second), e.ModTime.Add(time.Second)))
+	_, changes, err := Scan([]string{path}, m)
+	require.NoError(t, err)
+	require.Equal(t, []string{path}, changes.Modified)
+
+	m.Delete(path)
+	require.Empty(t, m.Files)
+}
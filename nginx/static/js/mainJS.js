@@ -5,7 +5,7 @@ function isValid(s) {
         return false;
     }
 
-    let badSigns = "@#-+$=*^&%<>";
+    let badSigns = "@#$=*^&%<>";
     for (let i = 0; i < badSigns.length; i++) {
         if (s.indexOf(badSigns[i]) > -1) {
             return false;
@@ -29,16 +29,39 @@ document.addEventListener("DOMContentLoaded", function () {
         redraw();
     }
 
-    function addItem(filename, count, proximity) {
+    function escapeHTML(s) {
+        return s.replace(/&/g, "&amp;")
+            .replace(/</g, "&lt;")
+            .replace(/>/g, "&gt;")
+            .replace(/"/g, "&quot;");
+    }
+
+    function highlight(snippet) {
+        let result = "";
+        let last = 0;
+        snippet.Matches.forEach(function (m) {
+            result += escapeHTML(snippet.Text.substring(last, m[0]))
+                + '<mark>' + escapeHTML(snippet.Text.substring(m[0], m[1])) + '</mark>';
+            last = m[1];
+        });
+        return result + escapeHTML(snippet.Text.substring(last));
+    }
+
+    function addItem(filename, count, proximity, snippets) {
+        let text = "";
+        (snippets || []).forEach(function (s) {
+            text += '<div class="todos-list_item_snippet">&hellip;' + highlight(s) + '&hellip;</div>';
+        });
         list.insertAdjacentHTML(
             "beforeend",
             '<div class="todos-list_item">'
             + '<div class="todos-list_item_text-w">'
             + '<div class="todos-list_item_text" contenteditable="false">'
-            + '<span class="todos-toolbar_filters-item">' + filename + '</span>'
+            + '<span class="todos-toolbar_filters-item">' + escapeHTML(filename) + '</span>'
             + '<span class="todos-toolbar_filters-item">' + count + '</span>'
             + '<span class="todos-toolbar_filters-item">' + proximity + '</span>'
             + '</div>'
+            + text
             + '</div>'
             + '</div>'
         );
@@ -72,14 +95,14 @@ document.addEventListener("DOMContentLoaded", function () {
                             console.log(responseCreate);
                             redraw();
                             addSearchPhrase(text);
-                            responseCreate.forEach(function (t) {
+                            responseCreate.Results.forEach(function (t) {
                                 console.log(t.Filename, t.Count, t.Spacing);
-                                addItem(t.Filename, t.Count, t.Spacing);
+                                addItem(t.Filename, t.Count, t.Spacing, t.Snippets);
                             })
 
                         } else {
                             if (createRequest.status === 400) {
-                                alert("Incorrect data");
+                                alert("Incorrect data: " + createRequest.responseText);
                             }
 
                         }
@@ -0,0 +1,489 @@
+package query
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/polisgo2020/search-senyast4745/index"
+)
+
+// Set contains files matched by a query node with the start positions of the matched phrases
+type Set map[string][]int
+
+// Node describes a node of the query syntax tree
+type Node interface {
+	String() string
+	eval(e *evaluator) Set
+	terms(consumer func(word string, weight float64))
+}
+
+// Term matches files containing the word
+type Term struct {
+	Word string
+}
+
+// Fuzzy matches files containing terms within Distance edits of the word,
+// the terms are found in the dictionary of the index by Expand
+type Fuzzy struct {
+	Word     string
+	Distance int
+	Terms    []index.FuzzyTerm
+}
+
+// Phrase matches files containing the words one after another
+type Phrase struct {
+	Words []string
+}
+
+// Near matches files where the Left and Right phrases occur within Distance tokens.
+// If Ordered is true the Right phrase must follow the Left one
+type Near struct {
+	Left     []string
+	Right    []string
+	Distance int
+	Ordered  bool
+}
+
+// And matches files matched by all the nodes
+type And struct {
+	Nodes []Node
+}
+
+// Or matches files matched by at least one of the nodes
+type Or struct {
+	Nodes []Node
+	// variants is set if the nodes are the same word or phrase analyzed differently
+	variants bool
+}
+
+// Not matches files which are not matched by the node
+type Not struct {
+	Node Node
+}
+
+// Required marks node which must match in a Group
+type Required struct {
+	Node Node
+}
+
+// Group combines adjacent expressions: files must match all Must nodes,
+// at least one of Should nodes if there are no Must nodes, and none of MustNot nodes
+type Group struct {
+	Must    []Node
+	Should  []Node
+	MustNot []Node
+}
+
+func (t *Term) String() string {
+	return t.Word
+}
+
+func (f *Fuzzy) String() string {
+	return f.Word + "~" + strconv.Itoa(f.Distance)
+}
+
+func (p *Phrase) String() string {
+	return phraseString(p.Words)
+}
+
+func (n *Near) String() string {
+	op := "NEAR/"
+	if n.Ordered {
+		op = "ONEAR/"
+	}
+	return "(" + phraseString(n.Left) + " " + op + strconv.Itoa(n.Distance) + " " + phraseString(n.Right) + ")"
+}
+
+func phraseString(words []string) string {
+	if len(words) == 1 {
+		return words[0]
+	}
+	return `"` + strings.Join(words, " ") + `"`
+}
+
+func (a *And) String() string {
+	return "(" + joinNodes(a.Nodes, " AND ") + ")"
+}
+
+func (o *Or) String() string {
+	return "(" + joinNodes(o.Nodes, " OR ") + ")"
+}
+
+func (n *Not) String() string {
+	return "-" + n.Node.String()
+}
+
+func (r *Required) String() string {
+	return "+" + r.Node.String()
+}
+
+func (g *Group) String() string {
+	var parts []string
+	for _, n := range g.Must {
+		parts = append(parts, "+"+n.String())
+	}
+	for _, n := range g.Should {
+		parts = append(parts, n.String())
+	}
+	for _, n := range g.MustNot {
+		parts = append(parts, "-"+n.String())
+	}
+	return "(" + strings.Join(parts, " ") + ")"
+}
+
+func joinNodes(nodes []Node, sep string) string {
+	parts := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		parts = append(parts, n.String())
+	}
+	return strings.Join(parts, sep)
+}
+
+// NeedsAll reports whether the query matches files by the words they lack, like NOT x or a OR -b,
+// such queries are evaluated over all documents of the index
+func NeedsAll(n Node) bool {
+	switch n := n.(type) {
+	case *Not:
+		return true
+	case *And:
+		positive := false
+		for _, c := range n.Nodes {
+			if not, ok := c.(*Not); ok {
+				c = not.Node
+			} else {
+				positive = true
+			}
+			if NeedsAll(c) {
+				return true
+			}
+		}
+		return !positive
+	case *Or:
+		return anyNeedsAll(n.Nodes)
+	case *Required:
+		return NeedsAll(n.Node)
+	case *Group:
+		return anyNeedsAll(n.Must) || anyNeedsAll(n.Should) || anyNeedsAll(n.MustNot)
+	}
+	return false
+}
+
+func anyNeedsAll(nodes []Node) bool {
+	for _, n := range nodes {
+		if NeedsAll(n) {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluator evaluates query nodes over the index and caches the set of all known files
+type evaluator struct {
+	ind      *index.Index
+	universe Set
+}
+
+func (e *evaluator) all() Set {
+	if e.universe != nil {
+		return e.universe
+	}
+	e.universe = make(Set)
+	for _, file := range e.ind.Files() {
+		e.universe[file] = nil
+	}
+	return e.universe
+}
+
+func (t *Term) eval(e *evaluator) Set {
+	res := make(Set)
+	for _, p := range e.ind.Postings(t.Word) {
+		res[p.File] = nil
+	}
+	return res
+}
+
+func (f *Fuzzy) eval(e *evaluator) Set {
+	res := make(Set)
+	for _, t := range f.expansions() {
+		for _, p := range e.ind.Postings(t.Term) {
+			res[p.File] = nil
+		}
+	}
+	return res
+}
+
+// expansions returns the found terms or the word itself if the fuzzy word is not expanded
+func (f *Fuzzy) expansions() []index.FuzzyTerm {
+	if f.Terms == nil {
+		return []index.FuzzyTerm{{Term: f.Word}}
+	}
+	return f.Terms
+}
+
+func (p *Phrase) eval(e *evaluator) Set {
+	return Set(e.ind.Phrase(p.Words))
+}
+
+func (n *Near) eval(e *evaluator) Set {
+	return Set(e.ind.Near(n.Left, n.Right, n.Distance, n.Ordered))
+}
+
+func (a *And) eval(e *evaluator) Set {
+	var res Set
+	var excluded []Set
+	for _, n := range a.Nodes {
+		if not, ok := n.(*Not); ok {
+			excluded = append(excluded, not.Node.eval(e))
+			continue
+		}
+		if res == nil {
+			res = n.eval(e)
+		} else {
+			res = intersect(res, n.eval(e))
+		}
+	}
+	if res == nil {
+		res = union(make(Set), e.all())
+	}
+	for _, ex := range excluded {
+		res = subtract(res, ex)
+	}
+	return res
+}
+
+func (o *Or) eval(e *evaluator) Set {
+	res := make(Set)
+	for _, n := range o.Nodes {
+		res = union(res, n.eval(e))
+	}
+	return res
+}
+
+func (n *Not) eval(e *evaluator) Set {
+	return subtract(union(make(Set), e.all()), n.Node.eval(e))
+}
+
+func (r *Required) eval(e *evaluator) Set {
+	return r.Node.eval(e)
+}
+
+func (g *Group) eval(e *evaluator) Set {
+	var res Set
+	if len(g.Must) > 0 {
+		for _, n := range g.Must {
+			if res == nil {
+				res = n.eval(e)
+			} else {
+				res = intersect(res, n.eval(e))
+			}
+		}
+		// should nodes do not filter files here, but still provide phrase positions
+		for _, n := range g.Should {
+			for file, offsets := range n.eval(e) {
+				if _, ok := res[file]; ok {
+					res[file] = append(res[file], offsets...)
+				}
+			}
+		}
+	} else {
+		res = make(Set)
+		for _, n := range g.Should {
+			res = union(res, n.eval(e))
+		}
+	}
+	for _, n := range g.MustNot {
+		res = subtract(res, n.eval(e))
+	}
+	return res
+}
+
+func (t *Term) terms(consumer func(word string, weight float64)) {
+	consumer(t.Word, 1)
+}
+
+func (f *Fuzzy) terms(consumer func(word string, weight float64)) {
+	for _, t := range f.expansions() {
+		consumer(t.Term, FuzzyWeight(t.Distance))
+	}
+}
+
+func (p *Phrase) terms(consumer func(word string, weight float64)) {
+	for _, w := range p.Words {
+		consumer(w, 1)
+	}
+}
+
+func (n *Near) terms(consumer func(word string, weight float64)) {
+	for _, w := range n.Left {
+		consumer(w, 1)
+	}
+	for _, w := range n.Right {
+		consumer(w, 1)
+	}
+}
+
+func (a *And) terms(consumer func(word string, weight float64)) {
+	for _, n := range a.Nodes {
+		n.terms(consumer)
+	}
+}
+
+func (o *Or) terms(consumer func(word string, weight float64)) {
+	for _, n := range o.Nodes {
+		n.terms(consumer)
+	}
+}
+
+func (n *Not) terms(func(word string, weight float64)) {}
+
+func (r *Required) terms(consumer func(word string, weight float64)) {
+	r.Node.terms(consumer)
+}
+
+func (g *Group) terms(consumer func(word string, weight float64)) {
+	for _, n := range g.Must {
+		n.terms(consumer)
+	}
+	for _, n := range g.Should {
+		n.terms(consumer)
+	}
+}
+
+func intersect(a, b Set) Set {
+	res := make(Set)
+	for file, offsets := range a {
+		if other, ok := b[file]; ok {
+			res[file] = append(append([]int(nil), offsets...), other...)
+		}
+	}
+	return res
+}
+
+func union(a, b Set) Set {
+	for file, offsets := range b {
+		a[file] = append(a[file], offsets...)
+	}
+	return a
+}
+
+func subtract(a, b Set) Set {
+	for file := range b {
+		delete(a, file)
+	}
+	return a
+}
+
+// Eval returns files of the index matched by the query
+func Eval(n Node, ind *index.Index) Set {
+	if n == nil {
+		return make(Set)
+	}
+	res := n.eval(&evaluator{ind: ind})
+	for file, offsets := range res {
+		res[file] = uniqueSorted(offsets)
+	}
+	return res
+}
+
+// Terms returns unique words which are used to find files, words of the negated nodes are skipped
+func Terms(n Node) []string {
+	if n == nil {
+		return nil
+	}
+	var res []string
+	seen := make(map[string]bool)
+	n.terms(func(word string, _ float64) {
+		if !seen[word] {
+			seen[word] = true
+			res = append(res, word)
+		}
+	})
+	return res
+}
+
+// AllTerms returns unique words of the query including the words of the negated nodes,
+// postings of all of them are needed to evaluate the query
+func AllTerms(n Node) []string {
+	res := Terms(n)
+	if n == nil {
+		return res
+	}
+	seen := make(map[string]bool, len(res))
+	for _, word := range res {
+		seen[word] = true
+	}
+	add := func(word string, _ float64) {
+		if !seen[word] {
+			seen[word] = true
+			res = append(res, word)
+		}
+	}
+	walk(n, func(n Node) {
+		switch n.(type) {
+		case *Term, *Fuzzy, *Phrase, *Near:
+			n.terms(add)
+		}
+	})
+	return res
+}
+
+// FuzzyWeight is the weight of a term found by a fuzzy word in the score, exact terms have weight 1
+func FuzzyWeight(distance int) float64 {
+	return 1 / float64(distance+1)
+}
+
+// Weights returns weights of the words found by fuzzy words which are lower than 1,
+// a word searched several times gets the highest weight
+func Weights(n Node) map[string]float64 {
+	if n == nil {
+		return nil
+	}
+	weights := make(map[string]float64)
+	n.terms(func(word string, weight float64) {
+		if w, ok := weights[word]; !ok || weight > w {
+			weights[word] = weight
+		}
+	})
+	var res map[string]float64
+	for word, w := range weights {
+		if w < 1 {
+			if res == nil {
+				res = make(map[string]float64)
+			}
+			res[word] = w
+		}
+	}
+	return res
+}
+
+// Search evaluates the query and returns search data of the matched files ready for ranking
+func Search(n Node, ind *index.Index) map[string]*index.Data {
+	data := ind.Search(Terms(n))
+	weights := Weights(n)
+	res := make(map[string]*index.Data)
+	for file, offsets := range Eval(n, ind) {
+		d, ok := data[file]
+		if !ok {
+			d = &index.Data{Freq: make(map[string]int)}
+		}
+		d.Offsets = offsets
+		d.Weights = weights
+		res[file] = d
+	}
+	return res
+}
+
+func uniqueSorted(offsets []int) []int {
+	if len(offsets) == 0 {
+		return nil
+	}
+	sort.Ints(offsets)
+	res := offsets[:1]
+	for _, o := range offsets[1:] {
+		if o != res[len(res)-1] {
+			res = append(res, o)
+		}
+	}
+	return res
+}
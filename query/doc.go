@@ -0,0 +1,28 @@
+// Package query parses search queries into an abstract syntax tree
+// and evaluates it against the posting lists of the inverted index.
+//
+// Supported syntax:
+//
+//	word            file should contain the word
+//	+word           file must contain the word
+//	-word, NOT word file must not contain the word
+//	"some phrase"   file must contain the words one after another
+//	a AND b         file must contain both a and b
+//	a OR b          file must contain a or b
+//	(a OR b) AND c  parentheses group expressions
+//	a NEAR/5 b      a and b occur within 5 tokens of each other in any order
+//	a ONEAR/5 b     b follows a within 5 tokens
+//	number:2020     file should contain the number, also email:, url: and ident: (word of an identifier)
+//	golang~1        file should contain a term within 1 edit of golang, the distance is at most 2
+//	golang~         the distance depends on the length of the word, see AutoDistance
+//
+// Operands of NEAR and ONEAR must be words or phrases.
+// Typed words find only terms recognized as the type by the analyzer of the index, like the unicode analyzer.
+// Operators are case sensitive. NEAR binds tighter than NOT, NOT binds tighter than AND,
+// AND binds tighter than OR.
+// Adjacent expressions without an operator are combined as in Lucene:
+// at least one of them must match unless some of them are marked with +.
+// A query made only of negated expressions matches nothing.
+// Terms found by fuzzy words weigh less than exact ones, see FuzzyWeight. Search clients may also
+// replace words missing from the index with fuzzy words, see Fuzzify.
+package query
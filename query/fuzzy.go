@@ -0,0 +1,103 @@
+package query
+
+import (
+	"unicode/utf8"
+
+	"github.com/polisgo2020/search-senyast4745/index"
+)
+
+// maxFuzzyDistance is the largest edit distance of a fuzzy word
+const maxFuzzyDistance = 2
+
+// Dictionary returns terms of the index within the edit distance of the word
+type Dictionary func(word string, distance int) ([]index.FuzzyTerm, error)
+
+// AutoDistance returns the edit distance allowed for the word: 0 for words of one or two runes,
+// 1 for words of up to five runes and 2 for longer words
+func AutoDistance(word string) int {
+	switch n := utf8.RuneCountInString(word); {
+	case n <= 2:
+		return 0
+	case n <= 5:
+		return 1
+	}
+	return 2
+}
+
+// Expand finds the terms of the fuzzy words of the query in the dictionary
+func Expand(n Node, dict Dictionary) error {
+	var err error
+	walk(n, func(n Node) {
+		f, ok := n.(*Fuzzy)
+		if !ok || f.Terms != nil || err != nil {
+			return
+		}
+		if f.Terms, err = dict(f.Word, f.Distance); f.Terms == nil {
+			f.Terms = []index.FuzzyTerm{}
+		}
+	})
+	return err
+}
+
+// Fuzzify replaces words of the query which are missing from the index with fuzzy words
+// of the automatic distance, negated words are kept. It reports if any word is replaced
+func Fuzzify(n Node, missing func(word string) bool) (Node, bool) {
+	var changed bool
+	res := fuzzify(n, func(t *Term) Node {
+		d := AutoDistance(t.Word)
+		if d == 0 || !missing(t.Word) {
+			return t
+		}
+		changed = true
+		return &Fuzzy{Word: t.Word, Distance: d}
+	})
+	return res, changed
+}
+
+// fuzzify returns the node with terms replaced by the function, negated nodes are not changed
+func fuzzify(n Node, fn func(t *Term) Node) Node {
+	all := func(nodes []Node) []Node {
+		if nodes == nil {
+			return nil
+		}
+		res := make([]Node, len(nodes))
+		for i, n := range nodes {
+			res[i] = fuzzify(n, fn)
+		}
+		return res
+	}
+	switch v := n.(type) {
+	case *Term:
+		return fn(v)
+	case *And:
+		return &And{Nodes: all(v.Nodes)}
+	case *Or:
+		return &Or{Nodes: all(v.Nodes), variants: v.variants}
+	case *Required:
+		return &Required{Node: fuzzify(v.Node, fn)}
+	case *Group:
+		return &Group{Must: all(v.Must), Should: all(v.Should), MustNot: v.MustNot}
+	}
+	return n
+}
+
+// walk calls the function for the node and all its descendants
+func walk(n Node, fn func(n Node)) {
+	fn(n)
+	var children []Node
+	switch v := n.(type) {
+	case *And:
+		children = v.Nodes
+	case *Or:
+		children = v.Nodes
+	case *Not:
+		children = []Node{v.Node}
+	case *Required:
+		children = []Node{v.Node}
+	case *Group:
+		children = append(append(append([]Node(nil), v.Must...), v.Should...), v.MustNot...)
+	}
+	for _, c := range children {
+		walk(c, fn)
+	}
+}
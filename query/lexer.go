@@ -0,0 +1,173 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenPhrase
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenPlus
+	tokenMinus
+	tokenLParen
+	tokenRParen
+	tokenNear
+)
+
+func (k tokenKind) String() string {
+	switch k {
+	case tokenEOF:
+		return "end of query"
+	case tokenWord:
+		return "word"
+	case tokenPhrase:
+		return "phrase"
+	case tokenAnd:
+		return "AND"
+	case tokenOr:
+		return "OR"
+	case tokenNot:
+		return "NOT"
+	case tokenPlus:
+		return "'+'"
+	case tokenMinus:
+		return "'-'"
+	case tokenLParen:
+		return "'('"
+	case tokenRParen:
+		return "')'"
+	case tokenNear:
+		return "NEAR"
+	}
+	return "unknown token"
+}
+
+type token struct {
+	kind tokenKind
+	text string
+	// distance and ordered describe NEAR/k and ONEAR/k operators
+	distance int
+	ordered  bool
+	// pos is the rune position of the token in the query starting from 1
+	pos int
+}
+
+// SyntaxError describes incorrect query with the position of the error
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at position %d: %s", e.Pos, e.Msg)
+}
+
+type lexer struct {
+	input string
+	// offset is the byte offset of the next rune, pos is its rune position
+	offset int
+	pos    int
+}
+
+func newLexer(input string) *lexer {
+	return &lexer{input: input, pos: 1}
+}
+
+func (l *lexer) peekRune() rune {
+	if l.offset >= len(l.input) {
+		return utf8.RuneError
+	}
+	r, _ := utf8.DecodeRuneInString(l.input[l.offset:])
+	return r
+}
+
+func (l *lexer) nextRune() rune {
+	r, size := utf8.DecodeRuneInString(l.input[l.offset:])
+	l.offset += size
+	l.pos++
+	return r
+}
+
+func (l *lexer) eof() bool {
+	return l.offset >= len(l.input)
+}
+
+// next returns the next token of the query
+func (l *lexer) next() (token, error) {
+	for !l.eof() && unicode.IsSpace(l.peekRune()) {
+		l.nextRune()
+	}
+	start := l.pos
+	if l.eof() {
+		return token{kind: tokenEOF, pos: start}, nil
+	}
+
+	switch l.peekRune() {
+	case '(':
+		l.nextRune()
+		return token{kind: tokenLParen, text: "(", pos: start}, nil
+	case ')':
+		l.nextRune()
+		return token{kind: tokenRParen, text: ")", pos: start}, nil
+	case '+':
+		l.nextRune()
+		return token{kind: tokenPlus, text: "+", pos: start}, nil
+	case '-':
+		l.nextRune()
+		return token{kind: tokenMinus, text: "-", pos: start}, nil
+	case '"':
+		l.nextRune()
+		from := l.offset
+		for !l.eof() && l.peekRune() != '"' {
+			l.nextRune()
+		}
+		if l.eof() {
+			return token{}, &SyntaxError{Pos: start, Msg: "unterminated phrase"}
+		}
+		text := l.input[from:l.offset]
+		l.nextRune()
+		return token{kind: tokenPhrase, text: text, pos: start}, nil
+	}
+
+	from := l.offset
+	for !l.eof() && !isDelimiter(l.peekRune()) {
+		l.nextRune()
+	}
+	text := l.input[from:l.offset]
+	switch text {
+	case "AND":
+		return token{kind: tokenAnd, text: text, pos: start}, nil
+	case "OR":
+		return token{kind: tokenOr, text: text, pos: start}, nil
+	case "NOT":
+		return token{kind: tokenNot, text: text, pos: start}, nil
+	}
+	if strings.HasPrefix(text, "NEAR/") || strings.HasPrefix(text, "ONEAR/") {
+		return nearToken(text, start)
+	}
+	return token{kind: tokenWord, text: text, pos: start}, nil
+}
+
+// nearToken parses NEAR/k and ONEAR/k operators
+func nearToken(text string, pos int) (token, error) {
+	ordered := strings.HasPrefix(text, "O")
+	distance, err := strconv.Atoi(text[strings.IndexByte(text, '/')+1:])
+	if err != nil || distance <= 0 {
+		return token{}, &SyntaxError{Pos: pos, Msg: "distance of " + text + " must be a positive number"}
+	}
+	return token{kind: tokenNear, text: text, distance: distance, ordered: ordered, pos: pos}, nil
+}
+
+func isDelimiter(r rune) bool {
+	return unicode.IsSpace(r) || r == '(' || r == ')' || r == '"'
+}
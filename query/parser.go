@@ -0,0 +1,396 @@
+package query
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/polisgo2020/search-senyast4745/analysis"
+)
+
+// Analyzer turns raw words of the query into index terms, it is implemented by *analysis.Analyzer
+type Analyzer interface {
+	// Terms returns terms of the word without stop words
+	Terms(text string) []string
+	// PhraseTerms returns terms of the word of a quoted phrase, stop words are kept if the index keeps them
+	PhraseTerms(text string) []string
+	// AllTerms returns terms of the word with stop words
+	AllTerms(text string) []string
+}
+
+type parser struct {
+	lex       *lexer
+	tok       token
+	analyzers []Analyzer
+	// stop is set if stop words are searched, because the query has nothing else
+	stop bool
+}
+
+// Parse parses the query using the default analyzer.
+// Nil node without error is returned if the query contains no words
+func Parse(input string) (Node, error) {
+	return ParseWith(input, analysis.Default())
+}
+
+// ParseWith parses the query and analyzes its words with the given analyzers.
+// If the analyzers produce different terms for a word or phrase, files matching any of them are found.
+// Stop words are dropped outside of quoted phrases, but they are searched if the query has only stop words
+func ParseWith(input string, analyzers ...Analyzer) (Node, error) {
+	n, err := parse(input, analyzers, false)
+	if n != nil || err != nil {
+		return n, err
+	}
+	return parse(input, analyzers, true)
+}
+
+func parse(input string, analyzers []Analyzer, stop bool) (Node, error) {
+	p := &parser{lex: newLexer(input), analyzers: analyzers, stop: stop}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	n, err := p.parseSeq()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokenEOF {
+		return nil, p.unexpected()
+	}
+	return n, nil
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) unexpected() error {
+	if p.tok.kind == tokenEOF {
+		return &SyntaxError{Pos: p.tok.pos, Msg: "unexpected end of query, expected word, phrase or '('"}
+	}
+	return &SyntaxError{Pos: p.tok.pos, Msg: "unexpected " + p.tok.kind.String()}
+}
+
+// parseSeq parses adjacent expressions up to the end of the query or closing parenthesis
+func (p *parser) parseSeq() (Node, error) {
+	g := &Group{}
+	for p.tok.kind != tokenEOF && p.tok.kind != tokenRParen {
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		switch v := n.(type) {
+		case nil:
+		case *Required:
+			g.Must = append(g.Must, v.Node)
+		case *Not:
+			g.MustNot = append(g.MustNot, v.Node)
+		default:
+			g.Should = append(g.Should, v)
+		}
+	}
+	if len(g.Must)+len(g.MustNot) == 0 && len(g.Should) <= 1 {
+		if len(g.Should) == 0 {
+			return nil, nil
+		}
+		return g.Should[0], nil
+	}
+	return g, nil
+}
+
+func (p *parser) parseOr() (Node, error) {
+	var nodes []Node
+	for {
+		n, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if n != nil {
+			nodes = append(nodes, n)
+		}
+		if p.tok.kind != tokenOr {
+			break
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	switch len(nodes) {
+	case 0:
+		return nil, nil
+	case 1:
+		return nodes[0], nil
+	}
+	return &Or{Nodes: nodes}, nil
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	var nodes []Node
+	for {
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if n != nil {
+			nodes = append(nodes, n)
+		}
+		if p.tok.kind != tokenAnd {
+			break
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	switch len(nodes) {
+	case 0:
+		return nil, nil
+	case 1:
+		return nodes[0], nil
+	}
+	return &And{Nodes: nodes}, nil
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	switch p.tok.kind {
+	case tokenNot, tokenMinus:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		n, err := p.parseUnary()
+		if n == nil || err != nil {
+			return nil, err
+		}
+		if not, ok := n.(*Not); ok {
+			return not.Node, nil
+		}
+		return &Not{Node: n}, nil
+	case tokenPlus:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		n, err := p.parseUnary()
+		if n == nil || err != nil {
+			return nil, err
+		}
+		return &Required{Node: n}, nil
+	}
+	return p.parseNear()
+}
+
+// parseNear parses proximity operator, its operands must be words or phrases
+func (p *parser) parseNear() (Node, error) {
+	leftPos := p.tok.pos
+	left, err := p.parsePrimary()
+	if err != nil || p.tok.kind != tokenNear {
+		return left, err
+	}
+	op := p.tok
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	rightPos := p.tok.pos
+	right, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tokenNear {
+		return nil, &SyntaxError{Pos: p.tok.pos, Msg: op.text + " can not be chained"}
+	}
+
+	leftVariants, ok := nodeVariants(left)
+	if !ok {
+		return nil, &SyntaxError{Pos: leftPos, Msg: op.text + " operands must be words or phrases"}
+	}
+	rightVariants, ok := nodeVariants(right)
+	if !ok {
+		return nil, &SyntaxError{Pos: rightPos, Msg: op.text + " operands must be words or phrases"}
+	}
+	// operand made only of stop words does not restrict the other one
+	if left == nil {
+		return right, nil
+	}
+	if right == nil {
+		return left, nil
+	}
+	var nodes []Node
+	for _, l := range leftVariants {
+		for _, r := range rightVariants {
+			nodes = append(nodes, &Near{Left: l, Right: r, Distance: op.distance, Ordered: op.ordered})
+		}
+	}
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return &Or{Nodes: nodes, variants: true}, nil
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokenWord:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		text, distance, fuzzy := fuzzyWord(tok.text)
+		if distance > maxFuzzyDistance {
+			return nil, &SyntaxError{Pos: tok.pos,
+				Msg: "distance of " + tok.text + " must be at most " + strconv.Itoa(maxFuzzyDistance)}
+		}
+		var n Node
+		if t, word, ok := typedWord(text); ok {
+			n = p.variants([]string{word}, false, func(term string) string {
+				return analysis.TypedTerm(t, term)
+			})
+		} else {
+			n = p.variants([]string{text}, false, nil)
+		}
+		if fuzzy {
+			n = fuzzify(n, func(t *Term) Node {
+				d := distance
+				if d < 0 {
+					d = AutoDistance(t.Word)
+				}
+				if d == 0 {
+					return t
+				}
+				return &Fuzzy{Word: t.Word, Distance: d}
+			})
+		}
+		return n, nil
+	case tokenPhrase:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		return p.variants(strings.Fields(tok.text), true, nil), nil
+	case tokenLParen:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if p.tok.kind == tokenRParen {
+			return nil, &SyntaxError{Pos: tok.pos, Msg: "empty parentheses"}
+		}
+		n, err := p.parseSeq()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokenRParen {
+			return nil, &SyntaxError{Pos: p.tok.pos, Msg: "missing ')' for '(' at position " + strconv.Itoa(tok.pos)}
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		return n, nil
+	}
+	return nil, p.unexpected()
+}
+
+// fuzzyWord splits the fuzzy word, like golang~1, into the word and the edit distance.
+// The distance is -1 if it is not given, like golang~, then it depends on the length of the word
+func fuzzyWord(text string) (string, int, bool) {
+	i := strings.LastIndexByte(text, '~')
+	if i <= 0 {
+		return text, 0, false
+	}
+	if i == len(text)-1 {
+		return text[:i], -1, true
+	}
+	d, err := strconv.Atoi(text[i+1:])
+	if err != nil || d < 0 {
+		return text, 0, false
+	}
+	return text[:i], d, true
+}
+
+// typedWord splits the word targeting terms of a token type, like number:2020 or email:john@example.com
+func typedWord(text string) (analysis.TokenType, string, bool) {
+	i := strings.IndexByte(text, ':')
+	if i < 0 || i == len(text)-1 {
+		return analysis.Word, "", false
+	}
+	t, ok := analysis.ParseTokenType(text[:i])
+	if !ok || t == analysis.Word {
+		return analysis.Word, "", false
+	}
+	return t, text[i+1:], true
+}
+
+// nodeVariants returns words of the term or phrase node or of every variant made by the analyzers
+func nodeVariants(n Node) ([][]string, bool) {
+	switch v := n.(type) {
+	case nil:
+		return nil, true
+	case *Term:
+		return [][]string{{v.Word}}, true
+	case *Phrase:
+		return [][]string{v.Words}, true
+	case *Or:
+		if !v.variants {
+			return nil, false
+		}
+		var res [][]string
+		for _, n := range v.Nodes {
+			words, ok := nodeVariants(n)
+			if !ok {
+				return nil, false
+			}
+			res = append(res, words...)
+		}
+		return res, true
+	}
+	return nil, false
+}
+
+// analyze returns terms of the raw word of a phrase or of a single word
+func (p *parser) analyze(a Analyzer, word string, phrase bool) []string {
+	switch {
+	case p.stop:
+		return a.AllTerms(word)
+	case phrase:
+		return a.PhraseTerms(word)
+	}
+	return a.Terms(word)
+}
+
+// variants analyzes the raw words with every analyzer, different results are combined with OR.
+// Analyzers which drop all the words are skipped. If mark is set, it changes every term
+func (p *parser) variants(raw []string, phrase bool, mark func(term string) string) Node {
+	var nodes []Node
+	seen := make(map[string]bool)
+	for _, a := range p.analyzers {
+		var words []string
+		for _, w := range raw {
+			words = append(words, p.analyze(a, w, phrase)...)
+		}
+		if mark != nil {
+			for i := range words {
+				words[i] = mark(words[i])
+			}
+		}
+		key := strings.Join(words, " ")
+		if len(words) == 0 || seen[key] {
+			continue
+		}
+		seen[key] = true
+		nodes = append(nodes, wordsNode(words))
+	}
+	switch len(nodes) {
+	case 0:
+		return nil
+	case 1:
+		return nodes[0]
+	}
+	return &Or{Nodes: nodes, variants: true}
+}
+
+// words makes a node of the analyzed words, several words of one raw word or phrase are searched as a phrase
+func wordsNode(words []string) Node {
+	switch len(words) {
+	case 0:
+		return nil
+	case 1:
+		return &Term{Word: words[0]}
+	}
+	return &Phrase{Words: words}
+}
@@ -0,0 +1,318 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/polisgo2020/search-senyast4745/analysis"
+	"github.com/polisgo2020/search-senyast4745/index"
+	"github.com/stretchr/testify/require"
+)
+
+func testIndex() *index.Index {
+	ind := index.NewIndex()
+	ind.Data["invert"] = []*index.FileStruct{
+		{File: "file1", Position: []int{1, 4}},
+		{File: "file2", Position: []int{3}},
+	}
+	ind.Data["index"] = []*index.FileStruct{
+		{File: "file1", Position: []int{2}},
+		{File: "file3", Position: []int{0}},
+	}
+	ind.Data["golang"] = []*index.FileStruct{
+		{File: "file2", Position: []int{0}},
+		{File: "file3", Position: []int{5}},
+	}
+	ind.Docs = map[string]int{"file1": 5, "file2": 4, "file3": 6, "file4": 2}
+	return ind
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "single word", input: "golang", want: "golang"},
+		{name: "stemming", input: "inverted", want: "invert"},
+		{name: "implicit or", input: "golang index", want: "(golang index)"},
+		{name: "required", input: "+golang index", want: "(+golang index)"},
+		{name: "excluded", input: "golang -index", want: "(golang -index)"},
+		{name: "not keyword", input: "golang NOT index", want: "(golang -index)"},
+		{name: "double negation", input: "NOT -golang", want: "golang"},
+		{name: "and", input: "golang AND index", want: "(golang AND index)"},
+		{name: "or", input: "golang OR index", want: "(golang OR index)"},
+		{name: "precedence", input: "golang OR index AND invert", want: "(golang OR (index AND invert))"},
+		{name: "grouping", input: "(golang OR index) AND invert", want: "((golang OR index) AND invert)"},
+		{name: "phrase", input: `"inverted index"`, want: `"invert index"`},
+		{name: "stop words skipped", input: "the golang AND a", want: "golang"},
+		{name: "only stop words are searched", input: "the a", want: "(the a)"},
+		{name: "stop word phrase", input: `"the"`, want: "the"},
+		{name: "no words", input: "!!", want: "<nil>"},
+		{name: "hyphen inside word", input: "e-mail", want: "e-mail"},
+		{name: "near", input: "inverted NEAR/3 index", want: "(invert NEAR/3 index)"},
+		{name: "ordered near", input: `"inverted index" ONEAR/2 golang`, want: `("invert index" ONEAR/2 golang)`},
+		{name: "near with stop word", input: "the NEAR/2 golang", want: "golang"},
+		{name: "negated near", input: "-golang NEAR/2 index", want: "(-(golang NEAR/2 index))"},
+		{name: "near is a word without distance", input: "NEAR", want: "near"},
+		{name: "fuzzy", input: "golang~1", want: "golang~1"},
+		{name: "fuzzy stemmed", input: "Inverted~2", want: "invert~2"},
+		{name: "fuzzy auto distance", input: "golang~ index~ ox~", want: "(golang~2 index~1 ox)"},
+		{name: "fuzzy zero distance", input: "golang~0", want: "golang"},
+		{name: "tilde inside word", input: "a~b", want: "a~b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := Parse(tt.input)
+			require.NoError(t, err)
+			if n == nil {
+				require.Equal(t, tt.want, "<nil>")
+				return
+			}
+			require.Equal(t, tt.want, n.String())
+		})
+	}
+}
+
+func TestParse_SyntaxError(t *testing.T) {
+	tests := []struct {
+		input string
+		pos   int
+		msg   string
+	}{
+		{input: "golang AND", pos: 11, msg: "unexpected end of query, expected word, phrase or '('"},
+		{input: "OR golang", pos: 1, msg: "unexpected OR"},
+		{input: "(golang", pos: 8, msg: "missing ')' for '(' at position 1"},
+		{input: "golang)", pos: 7, msg: "unexpected ')'"},
+		{input: "a ()", pos: 3, msg: "empty parentheses"},
+		{input: `golang "index`, pos: 8, msg: "unterminated phrase"},
+		{input: "привет -", pos: 9, msg: "unexpected end of query, expected word, phrase or '('"},
+		{input: "golang NEAR/x index", pos: 8, msg: "distance of NEAR/x must be a positive number"},
+		{input: "golang ONEAR/0 index", pos: 8, msg: "distance of ONEAR/0 must be a positive number"},
+		{input: "(index OR golang) NEAR/2 index", pos: 1, msg: "NEAR/2 operands must be words or phrases"},
+		{input: "golang NEAR/2 index NEAR/2 invert", pos: 21, msg: "NEAR/2 can not be chained"},
+		{input: "golang NEAR/2", pos: 14, msg: "unexpected end of query, expected word, phrase or '('"},
+		{input: "index golang~3", pos: 7, msg: "distance of golang~3 must be at most 2"},
+		{input: "golang~1 NEAR/2 index", pos: 1, msg: "NEAR/2 operands must be words or phrases"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			require.Equal(t, &SyntaxError{Pos: tt.pos, Msg: tt.msg}, err)
+		})
+	}
+}
+
+func TestParseWith_Analyzers(t *testing.T) {
+	auto, err := analysis.Get("auto")
+	require.NoError(t, err)
+	var analyzers []Analyzer
+	for _, a := range auto.QueryAnalyzers() {
+		analyzers = append(analyzers, a)
+	}
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "книги", want: "(книги OR книг)"},
+		{input: "golang", want: "golang"},
+		{input: "inverted", want: "(invert OR inverted)"},
+		{input: "и the", want: "(и the)"},
+		{input: "и", want: "и"},
+		{input: `"красивые книги"`, want: `("красивые книги" OR "красив книг")`},
+		{input: "книги NEAR/2 golang", want: "((книги NEAR/2 golang) OR (книг NEAR/2 golang))"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			n, err := ParseWith(tt.input, analyzers...)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, n.String())
+		})
+	}
+
+	_, err = ParseWith("(a OR b) NEAR/2 книги", analyzers...)
+	require.Error(t, err, "only variants of one word are allowed as NEAR operands")
+}
+
+func TestParseWith_KeepStopWords(t *testing.T) {
+	a, err := analysis.WithStopWords(analysis.Default(), nil, true)
+	require.NoError(t, err)
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "the golang", want: "golang"},
+		{input: `"to be or not to be" golang`, want: `("to be or not to be" golang)`},
+		{input: `"bank of america"`, want: `"bank of america"`},
+		{input: "to be", want: "(to be)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			n, err := ParseWith(tt.input, a)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, n.String())
+		})
+	}
+
+	n, err := Parse(`"bank of america"`)
+	require.NoError(t, err)
+	require.Equal(t, `"bank america"`, n.String(), "stop words are not in the index of the default analyzer")
+}
+
+func TestParseWith_Typed(t *testing.T) {
+	a, err := analysis.Get("unicode")
+	require.NoError(t, err)
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "number:2020", want: "number:2020"},
+		{input: "email:John@Example.com", want: "email:john@example.com"},
+		{input: "ident:parseRequest", want: `"ident:parse ident:request"`},
+		{input: "parseRequest", want: `"parse request"`},
+		{input: "word:golang", want: `"word golang"`},
+		{input: "number:", want: "number"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			n, err := ParseWith(tt.input, a)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, n.String())
+		})
+	}
+}
+
+func TestEval(t *testing.T) {
+	ind := testIndex()
+	tests := []struct {
+		input string
+		want  Set
+	}{
+		{input: "golang", want: Set{"file2": nil, "file3": nil}},
+		{input: "golang index", want: Set{"file1": nil, "file2": nil, "file3": nil}},
+		{input: "+golang index", want: Set{"file2": nil, "file3": nil}},
+		{input: "golang -index", want: Set{"file2": nil}},
+		{input: "golang AND index", want: Set{"file3": nil}},
+		{input: "golang AND NOT index", want: Set{"file2": nil}},
+		{input: "NOT golang", want: Set{}},
+		{input: "invert OR NOT golang", want: Set{"file1": nil, "file2": nil, "file4": nil}},
+		{input: "-golang", want: Set{}},
+		{input: "(golang OR index) AND invert", want: Set{"file1": nil, "file2": nil}},
+		{input: `"inverted index"`, want: Set{"file1": {1}}},
+		{input: `+"inverted index" golang`, want: Set{"file1": {1}}},
+		{input: "unknown", want: Set{}},
+		{input: "invert NEAR/1 index", want: Set{"file1": {1, 2}}},
+		{input: "index NEAR/3 invert", want: Set{"file1": {1, 2, 4}}},
+		{input: "index ONEAR/3 invert", want: Set{"file1": {2, 4}}},
+		{input: "golang ONEAR/3 invert", want: Set{"file2": {0, 3}}},
+		{input: "invert ONEAR/3 golang", want: Set{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			n, err := Parse(tt.input)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, Eval(n, ind))
+		})
+	}
+}
+
+func TestFuzzy(t *testing.T) {
+	ind := testIndex()
+	ind.Data["golan"] = []*index.FileStruct{{File: "file4", Position: []int{0}}}
+
+	n, err := Parse("golng~1 -index")
+	require.NoError(t, err)
+	require.Equal(t, Set{}, Eval(n, ind), "the fuzzy word matches only itself before expansion")
+
+	require.NoError(t, Expand(n, ind.FuzzyTerms))
+	require.Equal(t, []string{"golang"}, Terms(n), "golan is two edits away")
+	require.Equal(t, map[string]float64{"golang": 0.5}, Weights(n))
+	require.Equal(t, Set{"file2": nil}, Eval(n, ind))
+
+	n, err = Parse("golan~2 golang")
+	require.NoError(t, err)
+	require.NoError(t, Expand(n, ind.FuzzyTerms))
+	require.Nil(t, Weights(n), "exact words keep their weight")
+
+	n, err = Parse("unknown~1")
+	require.NoError(t, err)
+	require.NoError(t, Expand(n, ind.FuzzyTerms))
+	require.Equal(t, Set{}, Eval(n, ind))
+	require.Nil(t, Terms(n))
+}
+
+func TestFuzzify(t *testing.T) {
+	ind := testIndex()
+	missing := func(word string) bool { return ind.DocFreq(word) == 0 }
+
+	n, err := Parse("+golnag indx -invrt ox")
+	require.NoError(t, err)
+	n, ok := Fuzzify(n, missing)
+	require.True(t, ok)
+	require.Equal(t, "(+golnag~2 indx~1 ox -invrt)", n.String(), "negated and short words are kept")
+	require.NoError(t, Expand(n, ind.FuzzyTerms))
+	require.Equal(t, Set{"file2": nil, "file3": nil}, Eval(n, ind))
+
+	n, err = Parse(`golang AND "inverted index"`)
+	require.NoError(t, err)
+	same, ok := Fuzzify(n, missing)
+	require.False(t, ok)
+	require.Equal(t, n.String(), same.String())
+}
+
+func TestSearch_FuzzyWeights(t *testing.T) {
+	ind := testIndex()
+	n, err := Parse("golang~1 OR indx~1")
+	require.NoError(t, err)
+	require.NoError(t, Expand(n, ind.FuzzyTerms))
+	res := Search(n, ind)
+	require.Len(t, res, 3)
+	require.Equal(t, map[string]float64{"index": 0.5}, res["file3"].Weights)
+
+	page := ind.Rank(res, index.ProximityScorer{}, 0, 0)
+	require.Equal(t, "file1", page.Results[2].File, "the fuzzy match is ranked below the exact ones")
+}
+
+func TestTerms(t *testing.T) {
+	n, err := Parse(`+golang -index ("inverted index" OR golang)`)
+	require.NoError(t, err)
+	require.Equal(t, []string{"golang", "invert", "index"}, Terms(n))
+	require.Nil(t, Terms(nil))
+}
+
+func TestAllTerms(t *testing.T) {
+	n, err := Parse(`+golang -index (NOT "inverted search" OR golang)`)
+	require.NoError(t, err)
+	require.Equal(t, []string{"golang", "invert", "search", "index"}, AllTerms(n))
+	require.Nil(t, AllTerms(nil))
+}
+
+func TestNeedsAll(t *testing.T) {
+	for search, needs := range map[string]bool{
+		"golang":                    false,
+		"golang -index":             false,
+		"golang NOT index":          false,
+		"+golang -index":            false,
+		"NOT index":                 false,
+		"-index":                    false,
+		"golang OR NOT index":       true,
+		"golang OR -index":          true,
+		"golang (search OR -index)": true,
+	} {
+		n, err := Parse(search)
+		require.NoError(t, err, search)
+		require.Equal(t, needs, NeedsAll(n), search)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	ind := testIndex()
+	n, err := Parse(`golang OR NOT index`)
+	require.NoError(t, err)
+
+	data := Search(n, ind)
+	require.Len(t, data, 3)
+	require.Equal(t, 1, data["file2"].Path)
+	require.Equal(t, 1, data["file3"].Path)
+	require.Equal(t, 0, data["file4"].Path)
+}
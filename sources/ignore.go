@@ -0,0 +1,117 @@
+package sources
+
+import (
+	"bufio"
+	"io"
+	"path"
+	"strings"
+)
+
+// rule is a single pattern of the ignore file or of the include and exclude flags.
+//
+// Patterns follow the gitignore syntax: a pattern without a slash matches the name at any level,
+// otherwise it matches the path relative to the folder of the ignore file.
+// "*" and "?" match within a name, "**" matches any number of folders,
+// a trailing slash matches folders only and a leading "!" includes the path again
+type rule struct {
+	// base is the slash-separated folder the pattern is relative to, it is empty for the root
+	base     string
+	segments []string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+	source   string
+}
+
+// parseRule parses the line of the ignore file, ok is false for comments and empty lines
+func parseRule(line, base, source string) (r rule, ok bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return r, false
+	}
+	r = rule{base: base, source: source}
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		r.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return r, false
+	}
+	r.segments = strings.Split(line, "/")
+	return r, true
+}
+
+// parseRules reads the rules of the ignore file located in the base folder
+func parseRules(reader io.Reader, base, source string) ([]rule, error) {
+	var rules []rule
+	sc := bufio.NewScanner(reader)
+	for sc.Scan() {
+		if r, ok := parseRule(sc.Text(), base, source); ok {
+			rules = append(rules, r)
+		}
+	}
+	return rules, sc.Err()
+}
+
+// match reports whether the rule matches the slash-separated path relative to the root
+func (r *rule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.base != "" {
+		if !strings.HasPrefix(rel, r.base+"/") {
+			return false
+		}
+		rel = rel[len(r.base)+1:]
+	}
+	if !r.anchored {
+		ok, _ := path.Match(r.segments[0], path.Base(rel))
+		return ok
+	}
+	return matchSegments(r.segments, strings.Split(rel, "/"))
+}
+
+func matchSegments(pattern, names []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			if len(pattern) == 1 {
+				return len(names) > 0
+			}
+			for i := 0; i <= len(names); i++ {
+				if matchSegments(pattern[1:], names[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(names) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], names[0]); !ok {
+			return false
+		}
+		pattern, names = pattern[1:], names[1:]
+	}
+	return len(names) == 0
+}
+
+// ignored returns the last rule matching the path, the path is ignored if the rule is not negated
+func ignored(rules []rule, rel string, isDir bool) (*rule, bool) {
+	var last *rule
+	for i := range rules {
+		if rules[i].match(rel, isDir) {
+			last = &rules[i]
+		}
+	}
+	return last, last != nil && !last.negate
+}
@@ -0,0 +1,289 @@
+// Package sources selects the files to index.
+//
+// Files are left out by the exclude patterns, by the ".searchignore" files in gitignore syntax
+// found in every folder, by the include patterns, by their size and by binary content.
+// Folders of version control systems are never indexed.
+package sources
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// IgnoreFile is the name of the files with patterns of the ignored paths
+const IgnoreFile = ".searchignore"
+
+// binaryPeek is the number of the first bytes checked for binary content, git checks as many
+const binaryPeek = 8000
+
+// ErrBinary is returned for files with binary content
+var ErrBinary = errors.New("binary content")
+
+// vcsDirs are folders of version control systems
+var vcsDirs = map[string]bool{".git": true, ".hg": true, ".svn": true}
+
+// Filter describes which files are indexed
+type Filter struct {
+	// Include lists patterns of the indexed files, all files are indexed if it is empty
+	Include []string
+	// Exclude lists patterns of the ignored files and folders
+	Exclude []string
+	// MaxSize is the size of the largest indexed file in bytes, 0 means no limit
+	MaxSize int64
+}
+
+// Skipped describes the file or the folder left out of the index
+type Skipped struct {
+	Path   string
+	Reason string
+}
+
+// Selector selects the files of the folder
+type Selector struct {
+	root    string
+	maxSize int64
+	include []rule
+	exclude []rule
+
+	m sync.Mutex
+	// ignores caches rules of the ignore files by the slash-separated folder
+	ignores map[string][]rule
+}
+
+// NewSelector creates the selector of the files under the root
+func NewSelector(root string, f Filter) *Selector {
+	s := &Selector{root: root, maxSize: f.MaxSize, ignores: make(map[string][]rule)}
+	for _, p := range f.Include {
+		if r, ok := parseRule(p, "", "--include"); ok {
+			s.include = append(s.include, r)
+		}
+	}
+	for _, p := range f.Exclude {
+		if r, ok := parseRule(p, "", "--exclude"); ok {
+			s.exclude = append(s.exclude, r)
+		}
+	}
+	return s
+}
+
+// Root returns the folder of the selected files
+func (s *Selector) Root() string {
+	return s.root
+}
+
+// Walk returns the selected files with their total size and the skipped files and folders
+func (s *Selector) Walk() ([]string, int64, []Skipped, error) {
+	var files []string
+	var size int64
+	var skipped []Skipped
+	err := filepath.Walk(s.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := s.rel(path)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if rel == "" {
+				return nil
+			}
+			reason, err := s.check(rel, info, s.rules(parent(rel)))
+			if err != nil {
+				return err
+			}
+			if reason != "" {
+				skipped = append(skipped, Skipped{Path: path, Reason: reason})
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		// walk reports links themselves, the size and the type of their targets are checked
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Stat(path)
+			switch {
+			case err != nil:
+				skipped = append(skipped, Skipped{Path: path, Reason: "broken link"})
+				return nil
+			case target.IsDir():
+				skipped = append(skipped, Skipped{Path: path, Reason: "link to a folder"})
+				return nil
+			}
+			info = linkInfo{FileInfo: target, name: info.Name()}
+		}
+		reason, err := s.check(rel, info, s.rules(parent(rel)))
+		if err != nil {
+			return err
+		}
+		if reason != "" {
+			if info.Name() != IgnoreFile {
+				skipped = append(skipped, Skipped{Path: path, Reason: reason})
+			}
+			return nil
+		}
+		files = append(files, path)
+		size += info.Size()
+		return nil
+	})
+	return files, size, skipped, err
+}
+
+// linkInfo describes the target of the link under the name of the link
+type linkInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (l linkInfo) Name() string {
+	return l.name
+}
+
+// Check returns the reason to skip the file or the empty string if the file is selected.
+// Folders of the file are checked as well
+func (s *Selector) Check(path string) (string, error) {
+	rel, err := s.rel(path)
+	if err != nil {
+		return "", err
+	}
+	if rel == "" || strings.HasPrefix(rel, "../") {
+		return "outside of " + s.root, nil
+	}
+
+	names := strings.Split(rel, "/")
+	for i := 1; i <= len(names); i++ {
+		dir := strings.Join(names[:i], "/")
+		info, err := os.Stat(filepath.Join(s.root, filepath.FromSlash(dir)))
+		if err != nil {
+			return "", err
+		}
+		reason, err := s.check(dir, info, s.rules(parent(dir)))
+		if err != nil || reason != "" {
+			return reason, err
+		}
+	}
+	return "", nil
+}
+
+// check returns the reason to skip the file or the folder
+func (s *Selector) check(rel string, info os.FileInfo, ignores []rule) (string, error) {
+	isDir := info.IsDir()
+	if isDir && vcsDirs[info.Name()] {
+		return "version control folder", nil
+	}
+	if !isDir && info.Name() == IgnoreFile {
+		return "ignore file", nil
+	}
+	if r, ok := ignored(s.exclude, rel, isDir); ok {
+		return fmt.Sprintf("excluded by %s %s", r.source, strings.Join(r.segments, "/")), nil
+	}
+	if r, ok := ignored(ignores, rel, isDir); ok {
+		return fmt.Sprintf("ignored by %s", r.source), nil
+	}
+	if isDir {
+		return "", nil
+	}
+	if !info.Mode().IsRegular() {
+		return "not a regular file", nil
+	}
+	if len(s.include) > 0 {
+		if _, ok := ignored(s.include, rel, false); !ok {
+			return "not included", nil
+		}
+	}
+	if s.maxSize > 0 && info.Size() > s.maxSize {
+		return fmt.Sprintf("larger than %d bytes", s.maxSize), nil
+	}
+	return "", nil
+}
+
+// rules returns rules of the ignore files in the folder and in all its parents
+func (s *Selector) rules(dir string) []rule {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.rulesLocked(dir)
+}
+
+func (s *Selector) rulesLocked(dir string) []rule {
+	if rules, ok := s.ignores[dir]; ok {
+		return rules
+	}
+	var rules []rule
+	if dir != "" {
+		rules = append(rules, s.rulesLocked(parent(dir))...)
+	}
+
+	path := filepath.Join(s.root, filepath.FromSlash(dir), IgnoreFile)
+	if f, err := os.Open(path); err == nil {
+		own, err := parseRules(f, dir, path)
+		f.Close()
+		if err == nil {
+			rules = append(rules, own...)
+		}
+	}
+	s.ignores[dir] = rules
+	return rules
+}
+
+// rel returns the slash-separated path relative to the root
+func (s *Selector) rel(path string) (string, error) {
+	rel, err := filepath.Rel(s.root, path)
+	if err != nil {
+		return "", err
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == "." {
+		return "", nil
+	}
+	return rel, nil
+}
+
+func parent(rel string) string {
+	if i := strings.LastIndex(rel, "/"); i >= 0 {
+		return rel[:i]
+	}
+	return ""
+}
+
+// Open opens the file and returns ErrBinary if its first bytes contain a NUL byte
+func Open(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := Text(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &file{Reader: r, f: f}, nil
+}
+
+// Text returns the reader of the same content or ErrBinary if its first bytes contain a NUL byte
+func Text(reader io.Reader) (*bufio.Reader, error) {
+	r := bufio.NewReaderSize(reader, binaryPeek)
+	head, err := r.Peek(binaryPeek)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+	if bytes.IndexByte(head, 0) >= 0 {
+		return nil, ErrBinary
+	}
+	return r, nil
+}
+
+type file struct {
+	*bufio.Reader
+	f *os.File
+}
+
+func (f *file) Close() error {
+	return f.f.Close()
+}
@@ -0,0 +1,200 @@
+package sources
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeTree(t *testing.T, files map[string]string) string {
+	root, err := ioutil.TempDir("", "sources")
+	require.NoError(t, err)
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+	return root
+}
+
+func relFiles(t *testing.T, root string, files []string) []string {
+	res := make([]string, len(files))
+	for i, f := range files {
+		rel, err := filepath.Rel(root, f)
+		require.NoError(t, err)
+		res[i] = filepath.ToSlash(rel)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func TestMatchRule(t *testing.T) {
+	for _, c := range []struct {
+		pattern, path string
+		isDir, match  bool
+	}{
+		{"*.log", "a.log", false, true},
+		{"*.log", "logs/deep/a.log", false, true},
+		{"*.log", "a.txt", false, false},
+		{"build/", "build", true, true},
+		{"build/", "build", false, false},
+		{"/a.txt", "a.txt", false, true},
+		{"/a.txt", "docs/a.txt", false, false},
+		{"docs/*.md", "docs/a.md", false, true},
+		{"docs/*.md", "docs/sub/a.md", false, false},
+		{"docs/**/*.md", "docs/sub/deep/a.md", false, true},
+		{"docs/**/*.md", "docs/a.md", false, true},
+		{"**/tmp", "a/b/tmp", true, true},
+		{"vendor/**", "vendor/x/y.go", false, true},
+		{"vendor/**", "vendor", true, false},
+	} {
+		r, ok := parseRule(c.pattern, "", "")
+		require.True(t, ok)
+		require.Equal(t, c.match, r.match(c.path, c.isDir), "%s %s", c.pattern, c.path)
+	}
+
+	r, ok := parseRule("*.tmp", "sub", "")
+	require.True(t, ok)
+	require.True(t, r.match("sub/a.tmp", false))
+	require.False(t, r.match("a.tmp", false))
+
+	_, ok = parseRule("# comment", "", "")
+	require.False(t, ok)
+	_, ok = parseRule("   ", "", "")
+	require.False(t, ok)
+}
+
+func TestSelector_Walk(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"a.txt":              "text",
+		"b.log":              "log",
+		"keep.log":           "log",
+		"big.txt":            strings.Repeat("a", 100),
+		".searchignore":      "*.log\n!keep.log\n# comment\ntmp/\n",
+		".git/config":        "git",
+		"tmp/x.txt":          "x",
+		"docs/c.md":          "doc",
+		"docs/d.txt":         "doc",
+		"docs/.searchignore": "d.txt\n",
+		"secret/s.txt":       "s",
+	})
+	defer os.RemoveAll(root)
+
+	files, size, skipped, err := NewSelector(root, Filter{Exclude: []string{"secret"}, MaxSize: 50}).Walk()
+	require.NoError(t, err)
+	require.Equal(t, []string{"a.txt", "docs/c.md", "keep.log"}, relFiles(t, root, files))
+	require.Equal(t, int64(10), size)
+
+	reasons := make(map[string]string)
+	for _, s := range skipped {
+		rel, err := filepath.Rel(root, s.Path)
+		require.NoError(t, err)
+		reasons[filepath.ToSlash(rel)] = s.Reason
+	}
+	require.Equal(t, map[string]string{
+		".git":       "version control folder",
+		"b.log":      "ignored by " + filepath.Join(root, IgnoreFile),
+		"big.txt":    "larger than 50 bytes",
+		"docs/d.txt": "ignored by " + filepath.Join(root, "docs", IgnoreFile),
+		"secret":     "excluded by --exclude secret",
+		"tmp":        "ignored by " + filepath.Join(root, IgnoreFile),
+	}, reasons)
+
+	files, _, _, err = NewSelector(root, Filter{Include: []string{"*.md", "/*.txt"}}).Walk()
+	require.NoError(t, err)
+	require.Equal(t, []string{"a.txt", "big.txt", "docs/c.md"}, relFiles(t, root, files))
+}
+
+func TestSelector_Links(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"small.txt":   "text",
+		"big.txt":     strings.Repeat("a", 100),
+		"dir/a.txt":   "text",
+		"links/.keep": "",
+	})
+	defer os.RemoveAll(root)
+	for link, target := range map[string]string{
+		"links/small": "small.txt",
+		"links/big":   "big.txt",
+		"links/dir":   "dir",
+		"links/none":  "missing.txt",
+	} {
+		require.NoError(t, os.Symlink(filepath.Join(root, target), filepath.Join(root, filepath.FromSlash(link))))
+	}
+
+	s := NewSelector(root, Filter{Exclude: []string{".keep"}, MaxSize: 50})
+	files, size, skipped, err := s.Walk()
+	require.NoError(t, err)
+	require.Equal(t, []string{"dir/a.txt", "links/small", "small.txt"}, relFiles(t, root, files))
+	require.Equal(t, int64(12), size, "sizes of the targets are counted")
+
+	reasons := make(map[string]string)
+	for _, s := range skipped {
+		rel, err := filepath.Rel(root, s.Path)
+		require.NoError(t, err)
+		reasons[filepath.ToSlash(rel)] = s.Reason
+	}
+	require.Equal(t, "larger than 50 bytes", reasons["links/big"])
+	require.Equal(t, "link to a folder", reasons["links/dir"])
+	require.Equal(t, "broken link", reasons["links/none"])
+
+	reason, err := s.Check(filepath.Join(root, "links", "big"))
+	require.NoError(t, err)
+	require.Equal(t, "larger than 50 bytes", reason)
+}
+
+func TestSelector_Check(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"a.txt":         "text",
+		"tmp/x.txt":     "x",
+		".searchignore": "tmp/\n",
+	})
+	defer os.RemoveAll(root)
+
+	s := NewSelector(root, Filter{})
+	reason, err := s.Check(filepath.Join(root, "a.txt"))
+	require.NoError(t, err)
+	require.Empty(t, reason)
+
+	reason, err = s.Check(filepath.Join(root, "tmp", "x.txt"))
+	require.NoError(t, err)
+	require.Contains(t, reason, "ignored by")
+
+	reason, err = s.Check(filepath.Join(root, "..", "a.txt"))
+	require.NoError(t, err)
+	require.Contains(t, reason, "outside")
+
+	_, err = s.Check(filepath.Join(root, "missing.txt"))
+	require.Error(t, err)
+}
+
+func TestOpen(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"text.txt": "hello world",
+		"bin.dat":  "hello\x00world",
+		"late.dat": strings.Repeat("a", binaryPeek) + "\x00",
+	})
+	defer os.RemoveAll(root)
+
+	f, err := Open(filepath.Join(root, "text.txt"))
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(f)
+	require.NoError(t, err)
+	require.Equal(t, "hello world", string(data))
+	require.NoError(t, f.Close())
+
+	_, err = Open(filepath.Join(root, "bin.dat"))
+	require.Equal(t, ErrBinary, err)
+
+	f, err = Open(filepath.Join(root, "late.dat"))
+	require.NoError(t, err, "only the first bytes are checked")
+	require.NoError(t, f.Close())
+
+	_, err = Open(filepath.Join(root, "missing"))
+	require.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,163 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	barWidth    = 30
+	barInterval = 200 * time.Millisecond
+	logInterval = 10 * time.Second
+)
+
+// Progress reports processed files and bytes while the build runs.
+// It redraws a bar on a terminal and writes a log line from time to time otherwise.
+// All methods may be called on a nil Progress, they do nothing then
+type Progress struct {
+	// files and bytes are changed atomically, they go first to be aligned
+	files int64
+	bytes int64
+
+	totalFiles int64
+	totalBytes int64
+	start      time.Time
+	w          io.Writer
+	tty        bool
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+// IsTerminal reports whether the file is a terminal
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// NewProgress creates the progress of the files with the given total size,
+// the bar is drawn to the writer if tty is set
+func NewProgress(w io.Writer, tty bool, files int, bytes int64) *Progress {
+	return &Progress{totalFiles: int64(files), totalBytes: bytes, w: w, tty: tty}
+}
+
+// Start begins reporting until Stop is called
+func (p *Progress) Start() {
+	if p == nil {
+		return
+	}
+	p.start = time.Now()
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+
+	interval := logInterval
+	if p.tty {
+		interval = barInterval
+	}
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.report(time.Now())
+			case <-p.stop:
+				p.report(time.Now())
+				if p.tty {
+					fmt.Fprintln(p.w)
+				}
+				return
+			}
+		}
+	}()
+}
+
+// Stop reports the final progress and stops reporting
+func (p *Progress) Stop() {
+	if p == nil || p.stop == nil {
+		return
+	}
+	close(p.stop)
+	<-p.done
+}
+
+// FileDone counts the processed file
+func (p *Progress) FileDone() {
+	if p == nil {
+		return
+	}
+	atomic.AddInt64(&p.files, 1)
+}
+
+// Reader counts bytes read from the reader
+func (p *Progress) Reader(r io.Reader) io.Reader {
+	if p == nil {
+		return r
+	}
+	return &countingReader{r: r, n: &p.bytes}
+}
+
+func (p *Progress) report(now time.Time) {
+	files, bytes := atomic.LoadInt64(&p.files), atomic.LoadInt64(&p.bytes)
+	elapsed := now.Sub(p.start)
+	speed, eta := p.estimate(files, bytes, elapsed)
+
+	if p.tty {
+		fmt.Fprintf(p.w, "\r%s %d/%d files, %s/s, ETA %s\x1b[K", bar(p.fraction(files, bytes)), files, p.totalFiles,
+			FormatBytes(speed), eta.Round(time.Second))
+		return
+	}
+	log.Info().Int64("files done", files).Int64("files", p.totalFiles).Int64("bytes done", bytes).
+		Int64("bytes", p.totalBytes).Int64("bytes per second", speed).Dur("eta", eta.Round(time.Second)).
+		Msg("indexing progress")
+}
+
+// fraction returns the processed part from 0 to 1, bytes are preferred as files may differ in size
+func (p *Progress) fraction(files, bytes int64) float64 {
+	var res float64
+	switch {
+	case p.totalBytes > 0:
+		res = float64(bytes) / float64(p.totalBytes)
+	case p.totalFiles > 0:
+		res = float64(files) / float64(p.totalFiles)
+	}
+	if res > 1 {
+		res = 1
+	}
+	return res
+}
+
+// estimate returns the speed in bytes per second and the remaining time
+func (p *Progress) estimate(files, bytes int64, elapsed time.Duration) (int64, time.Duration) {
+	if elapsed <= 0 {
+		return 0, 0
+	}
+	speed := int64(float64(bytes) / elapsed.Seconds())
+	done := p.fraction(files, bytes)
+	if done == 0 {
+		return speed, 0
+	}
+	return speed, time.Duration(float64(elapsed) * (1 - done) / done)
+}
+
+func bar(done float64) string {
+	n := int(done * barWidth)
+	return "[" + strings.Repeat("=", n) + strings.Repeat(" ", barWidth-n) + "]"
+}
+
+type countingReader struct {
+	r io.Reader
+	n *int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	atomic.AddInt64(c.n, int64(n))
+	return n, err
+}
@@ -0,0 +1,134 @@
+// Package stats collects statistics of the index build and reports its progress.
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+	"time"
+)
+
+// Phase describes a step of the build
+type Phase struct {
+	Name     string
+	Duration time.Duration
+}
+
+// MarshalJSON writes the duration in seconds
+func (p Phase) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Name    string  `json:"name"`
+		Seconds float64 `json:"seconds"`
+	}{p.Name, p.Duration.Seconds()})
+}
+
+// File describes the file which is not indexed
+type File struct {
+	File   string `json:"file"`
+	Reason string `json:"reason"`
+}
+
+// Stats describes the result of the index build
+type Stats struct {
+	m sync.Mutex
+
+	Documents int   `json:"documents"`
+	Terms     int   `json:"terms"`
+	Tokens    int   `json:"tokens"`
+	Files     int   `json:"files"`
+	Bytes     int64 `json:"bytes"`
+	// IndexSize is the size of the index file in bytes, it is 0 for the database
+	IndexSize int64   `json:"index_size"`
+	Failed    []File  `json:"failed,omitempty"`
+	Skipped   []File  `json:"skipped,omitempty"`
+	Phases    []Phase `json:"phases"`
+}
+
+// Start begins the phase, the returned function ends it
+func (s *Stats) Start(name string) func() {
+	start := time.Now()
+	return func() {
+		s.m.Lock()
+		defer s.m.Unlock()
+		s.Phases = append(s.Phases, Phase{Name: name, Duration: time.Since(start)})
+	}
+}
+
+// Fail records the file which can not be indexed
+func (s *Stats) Fail(file string, err error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.Failed = append(s.Failed, File{File: file, Reason: err.Error()})
+}
+
+// Skip records the file or the folder which is left out of the index
+func (s *Stats) Skip(file, reason string) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.Skipped = append(s.Skipped, File{File: file, Reason: reason})
+}
+
+// Total returns the duration of all phases
+func (s *Stats) Total() time.Duration {
+	var total time.Duration
+	for _, p := range s.Phases {
+		total += p.Duration
+	}
+	return total
+}
+
+// Print writes the human-readable report
+func (s *Stats) Print(w io.Writer) {
+	fmt.Fprintf(w, "documents:   %d\n", s.Documents)
+	fmt.Fprintf(w, "terms:       %d\n", s.Terms)
+	fmt.Fprintf(w, "tokens:      %d\n", s.Tokens)
+	fmt.Fprintf(w, "files:       %d (%s), %d failed, %d skipped\n", s.Files, FormatBytes(s.Bytes), len(s.Failed),
+		len(s.Skipped))
+	printFiles(w, "failed", s.Failed)
+	printFiles(w, "skipped", s.Skipped)
+	if s.IndexSize > 0 {
+		fmt.Fprintf(w, "index size:  %s\n", FormatBytes(s.IndexSize))
+	}
+	for _, p := range s.Phases {
+		fmt.Fprintf(w, "%-12s %s\n", p.Name+":", p.Duration.Round(time.Millisecond))
+	}
+	fmt.Fprintf(w, "total:       %s\n", s.Total().Round(time.Millisecond))
+}
+
+// maxPrinted limits the failed and skipped files in the printed report, all of them are saved as json
+const maxPrinted = 10
+
+func printFiles(w io.Writer, name string, files []File) {
+	for i, f := range files {
+		if i == maxPrinted {
+			fmt.Fprintf(w, "  and %d more %s files\n", len(files)-maxPrinted, name)
+			break
+		}
+		fmt.Fprintf(w, "  %s %s: %s\n", name, f.File, f.Reason)
+	}
+}
+
+// Save writes the report as json
+func (s *Stats) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// FormatBytes returns the size with a binary unit, like 1.5 MB
+func FormatBytes(n int64) string {
+	const unit = 1 << 10
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 3; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGT"[exp])
+}
@@ -0,0 +1,105 @@
+package stats
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatBytes(t *testing.T) {
+	require.Equal(t, "0 B", FormatBytes(0))
+	require.Equal(t, "1023 B", FormatBytes(1023))
+	require.Equal(t, "1.5 KB", FormatBytes(1536))
+	require.Equal(t, "2.0 MB", FormatBytes(2<<20))
+	require.Equal(t, "3.0 GB", FormatBytes(3<<30))
+}
+
+func TestStats_Save(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stats")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s := &Stats{Documents: 2, Terms: 3, Tokens: 10, Files: 3}
+	s.Fail("c.txt", errors.New("permission denied"))
+	s.Skip("d.bin", "binary content")
+	s.Phases = append(s.Phases, Phase{Name: "index", Duration: 1500 * time.Millisecond})
+
+	path := filepath.Join(dir, "stats.json")
+	require.NoError(t, s.Save(path))
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+
+	var res map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &res))
+	require.Equal(t, 3.0, res["terms"])
+	require.Equal(t, []interface{}{map[string]interface{}{"file": "c.txt", "reason": "permission denied"}}, res["failed"])
+	require.Equal(t, []interface{}{map[string]interface{}{"file": "d.bin", "reason": "binary content"}}, res["skipped"])
+	require.Equal(t, []interface{}{map[string]interface{}{"name": "index", "seconds": 1.5}}, res["phases"])
+
+	var buf bytes.Buffer
+	s.Print(&buf)
+	require.Contains(t, buf.String(), "3 (0 B), 1 failed, 1 skipped")
+	require.Contains(t, buf.String(), "index:       1.5s")
+	require.Contains(t, buf.String(), "  skipped d.bin: binary content\n")
+
+	for i := 0; i < maxPrinted+2; i++ {
+		s.Skip("e.bin", "binary content")
+	}
+	buf.Reset()
+	s.Print(&buf)
+	require.Contains(t, buf.String(), "  and 3 more skipped files\n")
+}
+
+func TestStats_Start(t *testing.T) {
+	s := &Stats{}
+	end := s.Start("walk")
+	time.Sleep(time.Millisecond)
+	end()
+	require.Len(t, s.Phases, 1)
+	require.Equal(t, "walk", s.Phases[0].Name)
+	require.True(t, s.Phases[0].Duration > 0)
+	require.Equal(t, s.Phases[0].Duration, s.Total())
+}
+
+func TestProgress(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewProgress(&buf, true, 4, 100)
+	p.Start()
+	_, err := ioutil.ReadAll(p.Reader(strings.NewReader(strings.Repeat("a", 50))))
+	require.NoError(t, err)
+	p.FileDone()
+	p.FileDone()
+	p.Stop()
+
+	out := buf.String()
+	require.True(t, strings.HasSuffix(out, "\n"))
+	require.Contains(t, out, "["+strings.Repeat("=", barWidth/2)+strings.Repeat(" ", barWidth/2)+"] 2/4 files")
+}
+
+func TestProgress_Estimate(t *testing.T) {
+	p := NewProgress(nil, false, 10, 0)
+	speed, eta := p.estimate(5, 1000, 2*time.Second)
+	require.Equal(t, int64(500), speed)
+	require.Equal(t, 2*time.Second, eta)
+
+	speed, eta = p.estimate(0, 0, time.Second)
+	require.Zero(t, speed)
+	require.Zero(t, eta)
+}
+
+func TestProgress_Nil(t *testing.T) {
+	var p *Progress
+	p.Start()
+	p.FileDone()
+	r := strings.NewReader("a")
+	require.Equal(t, r, p.Reader(r))
+	p.Stop()
+}
@@ -1,10 +1,9 @@
 package util
 
 import (
+	"fmt"
+	"strconv"
 	"strings"
-	"unicode"
-
-	"github.com/reiver/go-porterstemmer"
 )
 
 // Abs takes a number x and returns its module
@@ -15,15 +14,33 @@ func Abs(x int) int {
 	return x
 }
 
-// CleanUserInput takes an array of words and formats it, removing the word stop and highlighting tokens
-func CleanUserInput(rawWord string, consumer func(input string)) {
-	word := strings.TrimFunc(rawWord, func(r rune) bool {
-		return !unicode.IsLetter(r)
-	})
-	if !EnglishStopWordChecker(word) {
-		word = porterstemmer.StemString(word)
-		if len(word) > 0 {
-			consumer(word)
+var sizeUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"KB", 1 << 10},
+	{"MB", 1 << 20},
+	{"GB", 1 << 30},
+	{"K", 1 << 10},
+	{"M", 1 << 20},
+	{"G", 1 << 30},
+	{"B", 1},
+}
+
+// ParseSize parses size in bytes with an optional KB, MB or GB suffix, like 512MB
+func ParseSize(s string) (int64, error) {
+	str := strings.ToUpper(strings.TrimSpace(s))
+	unit := int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(str, u.suffix) {
+			str = strings.TrimSpace(strings.TrimSuffix(str, u.suffix))
+			unit = u.size
+			break
 		}
 	}
+	n, err := strconv.ParseInt(str, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * unit, nil
 }
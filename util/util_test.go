@@ -18,40 +18,23 @@ func TestAbs(t *testing.T) {
 	require.Equal(t, 0, Abs(0))
 }
 
-func TestCleanUserInput(t *testing.T) {
+func TestParseSize(t *testing.T) {
 	t.Parallel()
-	input := "a"
-	called := false
-	assertFunc := func(input string) {
-		called = true
+	for str, size := range map[string]int64{
+		"0":     0,
+		"100":   100,
+		"100B":  100,
+		"2KB":   2048,
+		"512mb": 512 << 20,
+		"1 GB":  1 << 30,
+		"3M":    3 << 20,
+	} {
+		res, err := ParseSize(str)
+		require.NoError(t, err, str)
+		require.Equal(t, size, res, str)
 	}
-	CleanUserInput(input, assertFunc)
-	require.False(t, called, "function must not be called")
-
-	input = "word"
-	called = false
-	assertFunc = func(i string) {
-		called = true
-		require.Equal(t, input, i)
-	}
-	CleanUserInput(input, assertFunc)
-	require.True(t, called, "function must be called")
-
-	input = "beautiful"
-	called = false
-	assertFunc = func(i string) {
-		called = true
-		require.NotEqual(t, input, i, "input must be stemmed")
+	for _, str := range []string{"", "MB", "-1", "1TB", "1.5GB"} {
+		_, err := ParseSize(str)
+		require.Error(t, err, str)
 	}
-	CleanUserInput(input, assertFunc)
-	require.True(t, called, "function must be called")
-
-}
-
-func TestEnglishStopWordChecker(t *testing.T) {
-	t.Parallel()
-	word := "you"
-	require.True(t, EnglishStopWordChecker(word))
-	word = "golang"
-	require.False(t, EnglishStopWordChecker(word))
 }
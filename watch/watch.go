@@ -0,0 +1,149 @@
+// Package watch monitors the tree of the indexed files and reports changed files in batches.
+//
+// Events are collected until the tree stays quiet for the given delay,
+// so a file written in several chunks or renamed is reported once.
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/polisgo2020/search-senyast4745/sources"
+	"github.com/rs/zerolog/log"
+)
+
+// Batch describes files changed since the previous batch.
+// Updated contains created and modified files, Removed contains deleted
+// and renamed files or directories
+type Batch struct {
+	Updated []string
+	Removed []string
+}
+
+// Watcher watches the directory tree recursively
+type Watcher struct {
+	w     *fsnotify.Watcher
+	sel   *sources.Selector
+	delay time.Duration
+}
+
+// New creates watcher of the directories under the root of the selector,
+// directories skipped by the selector, like .git or ignored ones, are not watched
+func New(sel *sources.Selector, delay time.Duration) (*Watcher, error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	watcher := &Watcher{w: w, sel: sel, delay: delay}
+	if _, err := watcher.addTree(sel.Root()); err != nil {
+		w.Close()
+		return nil, err
+	}
+	return watcher, nil
+}
+
+// Close stops watching
+func (w *Watcher) Close() error {
+	return w.w.Close()
+}
+
+// addTree watches the directory with all selected subdirectories and returns files found in them
+func (w *Watcher) addTree(root string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if filepath.Clean(path) != filepath.Clean(w.sel.Root()) {
+				reason, err := w.sel.Check(path)
+				if err != nil {
+					return err
+				}
+				if reason != "" {
+					log.Debug().Str("directory", path).Str("reason", reason).Msg("directory is not watched")
+					return filepath.SkipDir
+				}
+			}
+			log.Debug().Str("directory", path).Msg("watch directory")
+			return w.w.Add(path)
+		}
+		files = append(files, path)
+		return nil
+	})
+	return files, err
+}
+
+// Run calls the handler with batches of changes until the context is done or watcher is closed
+func (w *Watcher) Run(ctx context.Context, handler func(Batch)) error {
+	changes := make(map[string]bool)
+	timer := time.NewTimer(w.delay)
+	timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err, ok := <-w.w.Errors:
+			if !ok {
+				return nil
+			}
+			log.Err(err).Msg("error while watching files")
+		case event, ok := <-w.w.Events:
+			if !ok {
+				return nil
+			}
+			log.Debug().Str("event", event.String()).Msg("file event")
+			w.collect(event, changes)
+			timer.Reset(w.delay)
+		case <-timer.C:
+			if len(changes) == 0 {
+				continue
+			}
+			handler(makeBatch(changes))
+			changes = make(map[string]bool)
+		}
+	}
+}
+
+// collect stores whether the file of the event exists after it, new directories are watched immediately
+func (w *Watcher) collect(event fsnotify.Event, changes map[string]bool) {
+	if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+		changes[event.Name] = false
+		return
+	}
+	info, err := os.Stat(event.Name)
+	if err != nil {
+		changes[event.Name] = false
+		return
+	}
+	if !info.IsDir() {
+		changes[event.Name] = true
+		return
+	}
+	files, err := w.addTree(event.Name)
+	if err != nil {
+		log.Err(err).Str("directory", event.Name).Msg("can not watch directory")
+	}
+	for _, f := range files {
+		changes[f] = true
+	}
+}
+
+func makeBatch(changes map[string]bool) Batch {
+	var b Batch
+	for file, exists := range changes {
+		if exists {
+			b.Updated = append(b.Updated, file)
+		} else {
+			b.Removed = append(b.Removed, file)
+		}
+	}
+	sort.Strings(b.Updated)
+	sort.Strings(b.Removed)
+	return b
+}
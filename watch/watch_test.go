@@ -0,0 +1,102 @@
+package watch
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/polisgo2020/search-senyast4745/sources"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatcher_Run(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watch")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	old := filepath.Join(dir, "old")
+	require.NoError(t, ioutil.WriteFile(old, []byte("old text"), 0644))
+
+	w, err := New(sources.NewSelector(dir, sources.Filter{}), 50*time.Millisecond)
+	require.NoError(t, err)
+	defer w.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	batches := make(chan Batch, 10)
+	go func() {
+		_ = w.Run(ctx, func(b Batch) {
+			batches <- b
+		})
+	}()
+
+	created := filepath.Join(dir, "created")
+	renamed := filepath.Join(dir, "renamed")
+	require.NoError(t, ioutil.WriteFile(created, []byte("new text"), 0644))
+	require.NoError(t, ioutil.WriteFile(created, []byte("new text again"), 0644))
+	require.NoError(t, os.Rename(old, renamed))
+
+	select {
+	case b := <-batches:
+		require.Equal(t, Batch{Updated: []string{created, renamed}, Removed: []string{old}}, b)
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch was not received")
+	}
+
+	sub := filepath.Join(dir, "sub")
+	require.NoError(t, os.Mkdir(sub, 0755))
+	// give watcher time to add the new directory
+	time.Sleep(200 * time.Millisecond)
+
+	nested := filepath.Join(sub, "nested")
+	require.NoError(t, ioutil.WriteFile(nested, []byte("nested text"), 0644))
+	require.NoError(t, os.Remove(created))
+
+	select {
+	case b := <-batches:
+		require.Equal(t, Batch{Updated: []string{nested}, Removed: []string{created}}, b)
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch was not received")
+	}
+}
+
+func TestWatcher_SkippedDirectories(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watch")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, sub := range []string{".git", "ignored", "excluded", "kept"} {
+		require.NoError(t, os.Mkdir(filepath.Join(dir, sub), 0755))
+	}
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, sources.IgnoreFile), []byte("ignored/\n"), 0644))
+
+	sel := sources.NewSelector(dir, sources.Filter{Exclude: []string{"excluded"}})
+	w, err := New(sel, 50*time.Millisecond)
+	require.NoError(t, err)
+	defer w.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	batches := make(chan Batch, 10)
+	go func() {
+		_ = w.Run(ctx, func(b Batch) {
+			batches <- b
+		})
+	}()
+
+	for _, sub := range []string{".git", "ignored", "excluded"} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, sub, "file"), []byte("text"), 0644))
+	}
+	kept := filepath.Join(dir, "kept", "file")
+	require.NoError(t, ioutil.WriteFile(kept, []byte("text"), 0644))
+
+	select {
+	case b := <-batches:
+		require.Equal(t, Batch{Updated: []string{kept}}, b, "skipped directories are not watched")
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch was not received")
+	}
+}
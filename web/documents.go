@@ -0,0 +1,104 @@
+package web
+
+import (
+	"context"
+	"crypto/subtle"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-chi/chi"
+	"github.com/polisgo2020/search-senyast4745/extract"
+	"github.com/polisgo2020/search-senyast4745/index"
+	"github.com/rs/zerolog/log"
+)
+
+// maxDocumentSize limits the body of the uploaded document
+const maxDocumentSize = 32 << 20
+
+// Updater is implemented by indexes which can change a single document.
+// They keep the text of the added documents, so snippets are not cut from a local file with the same name
+type Updater interface {
+	AddDocument(ctx context.Context, file string, reader io.Reader) error
+	RemoveDocument(ctx context.Context, file string) error
+	Text(file string) ([]byte, bool, error)
+}
+
+// documentSources reads the stored text of the documents added through the api and the files of other documents
+type documentSources struct {
+	texts Updater
+	files index.SourceReader
+}
+
+func (s *documentSources) Source(file string) ([]byte, error) {
+	text, ok, err := s.texts.Text(file)
+	if err != nil || ok {
+		return text, err
+	}
+	return s.files.Source(file)
+}
+
+func (a *App) putDocumentHandler(w http.ResponseWriter, req *http.Request) {
+	id, ok := documentID(w, req)
+	if !ok {
+		return
+	}
+	log.Info().Str("document", id).Msg("start indexing document")
+
+	body := http.MaxBytesReader(w, req.Body, maxDocumentSize)
+	defer body.Close()
+	text, err := extract.TextReader(extract.Lookup(id, req.Header.Get("Content-Type")), body)
+	if err != nil {
+		log.Err(err).Str("document", id).Msg("can not extract text of the document")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if err := a.updater.AddDocument(req.Context(), id, text); err != nil {
+		log.Err(err).Str("document", id).Msg("error while indexing document")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *App) deleteDocumentHandler(w http.ResponseWriter, req *http.Request) {
+	id, ok := documentID(w, req)
+	if !ok {
+		return
+	}
+	log.Info().Str("document", id).Msg("start removing document")
+
+	if err := a.updater.RemoveDocument(req.Context(), id); err != nil {
+		log.Err(err).Str("document", id).Msg("error while removing document")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// documentID returns unescaped document id from the url, the id may contain slashes
+func documentID(w http.ResponseWriter, req *http.Request) (string, bool) {
+	id, err := url.PathUnescape(chi.URLParam(req, "*"))
+	if err != nil || id == "" {
+		log.Err(err).Str("path", req.URL.Path).Msg("Incorrect document id")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return "", false
+	}
+	return id, true
+}
+
+// authMiddleware checks the bearer token of the request
+func authMiddleware(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				log.Warn().Str("remote", r.RemoteAddr).Str("path", r.URL.Path).Msg("unauthorized request")
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -4,11 +4,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"strings"
+	"strconv"
 	"time"
 
+	"github.com/polisgo2020/search-senyast4745/analysis"
+	"github.com/polisgo2020/search-senyast4745/extract"
 	"github.com/polisgo2020/search-senyast4745/index"
-	"github.com/polisgo2020/search-senyast4745/util"
+	"github.com/polisgo2020/search-senyast4745/query"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
@@ -20,20 +22,72 @@ import (
 type App struct {
 	Mux          *chi.Mux
 	ind          Indexed
+	scorer       index.Scorer
+	sources      index.SourceReader
+	updater      Updater
 	netInterface string
 }
 
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+
+	snippetCount    = 2
+	sourceCacheSize = 100
+
+	// documentTimeout limits indexing of a single document
+	documentTimeout = time.Minute
+)
+
 type FileResponse struct {
 	Filename string
 	Count    int
 	Spacing  int
+	Score    float64
+	Offsets  []int            `json:",omitempty"`
+	Snippets []*index.Snippet `json:",omitempty"`
+}
+
+type SearchResponse struct {
+	Total   int
+	Offset  int
+	Limit   int
+	Results []FileResponse
+}
+
+// Notifier is implemented by indexes which report the changed files, their cached texts are dropped
+type Notifier interface {
+	OnChange(fn func(files []string))
 }
 
+// Indexed returns the part of the index with postings of the given words,
+// a search asks for all words of the query including the negated ones.
+// The part holds only the documents with the words, DocLengths lists all of them
+// for queries matching documents by the words they lack
 type Indexed interface {
 	GetIndex(str ...string) (*index.Index, error)
+	DocLengths() (map[string]int, error)
+}
+
+// Analyzed is implemented by indexes which know the analyzer of their terms,
+// queries to other indexes are analyzed with the default analyzer
+type Analyzed interface {
+	Analyzer() *analysis.Analyzer
+}
+
+// Fuzzy is implemented by indexes which find terms similar to a word,
+// fuzzy words of queries to other indexes match only the word itself
+type Fuzzy interface {
+	FuzzyTerms(word string, distance int) ([]index.FuzzyTerm, error)
 }
 
 func NewApp(c *config.Config, i Indexed) (*App, error) {
+	scorer, err := index.NewScorer(c.Scorer)
+	if err != nil {
+		return nil, err
+	}
+	log.Debug().Str("scorer", c.Scorer).Msg("scorer created")
+
 	r := chi.NewMux()
 
 	log.Debug().Msg("add custom log and header middleware")
@@ -49,8 +103,6 @@ func NewApp(c *config.Config, i Indexed) (*App, error) {
 
 	log.Debug().Dur("timeout", d).Msg("server timeout")
 
-	r.Use(middleware.Timeout(d))
-
 	corsFilter := cors.New(cors.Options{
 		AllowedOrigins:   []string{"*"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
@@ -66,40 +118,132 @@ func NewApp(c *config.Config, i Indexed) (*App, error) {
 
 	log.Debug().RawJSON("endpoint", []byte("{\"method\" : \"POST\", \"pattern\" : \"\\\"")).Msg("register controller")
 
-	app := &App{Mux: r, netInterface: c.Listen, ind: i}
+	cache := index.NewSourceCacheReader(sourceCacheSize, extract.ReadText)
+	if n, ok := i.(Notifier); ok {
+		n.OnChange(cache.Invalidate)
+	}
+	app := &App{
+		Mux:          r,
+		netInterface: c.Listen,
+		ind:          i,
+		scorer:       scorer,
+		sources:      cache,
+	}
 
-	r.Post("/", app.searchHandler)
+	r.With(middleware.Timeout(d)).Post("/", app.searchHandler)
+
+	u, ok := i.(Updater)
+	if ok {
+		app.sources = &documentSources{texts: u, files: app.sources}
+	}
+	if ok && c.APIToken != "" {
+		app.updater = u
+		// documents are indexed longer than searches, so they are not limited by the search timeout
+		r.Route("/documents", func(r chi.Router) {
+			r.Use(middleware.Timeout(documentTimeout))
+			r.Use(authMiddleware(c.APIToken))
+			r.Put("/*", app.putDocumentHandler)
+			r.Delete("/*", app.deleteDocumentHandler)
+		})
+		log.Debug().Msg("document endpoints registered")
+	} else {
+		log.Info().Msg("document endpoints are disabled, API_TOKEN is not set or index is read only")
+	}
 	return app, nil
 }
 
 func (a *App) searchHandler(w http.ResponseWriter, req *http.Request) {
 	searchWords := req.FormValue("search")
 	log.Info().Str("search phrase", searchWords).Msg("start search")
-	var inputWords []string
-	for _, word := range strings.Split(searchWords, " ") {
-		util.CleanUserInput(word, func(input string) {
-			inputWords = append(inputWords, input)
-		})
+	analyzers := []query.Analyzer{analysis.Default()}
+	if ai, ok := a.ind.(Analyzed); ok {
+		analyzers = analyzers[:0]
+		for _, an := range ai.Analyzer().QueryAnalyzers() {
+			analyzers = append(analyzers, an)
+		}
+	}
+	q, err := query.ParseWith(searchWords, analyzers...)
+	if err != nil {
+		log.Err(err).Str("input", searchWords).Msg("Incorrect search query")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
-	log.Debug().Msgf("clean input: %+v", inputWords)
+	inputWords := query.Terms(q)
+	log.Debug().Msgf("parsed query: %v, terms: %+v", q, inputWords)
 	if len(inputWords) == 0 {
 		log.Err(nil).Str("input", searchWords).Msg("Incorrect search words")
 		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 		return
 	}
+	fuzzy, _ := a.ind.(Fuzzy)
+	if fuzzy != nil {
+		if err := query.Expand(q, fuzzy.FuzzyTerms); err != nil {
+			log.Err(err).Str("input", searchWords).Msg("can not expand fuzzy words")
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		inputWords = query.Terms(q)
+	}
+
+	offset, limit, err := parsePage(req)
+	if err != nil {
+		log.Err(err).Str("offset", req.FormValue("offset")).Str("limit", req.FormValue("limit")).
+			Msg("Incorrect page parameters")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
 
-	var resp []FileResponse
-	ind, err := a.ind.GetIndex(inputWords...)
+	// negated words are not scored, but their postings are needed to exclude files
+	ind, err := a.ind.GetIndex(query.AllTerms(q)...)
 	if err != nil {
 		log.Err(err).Msg("error while getting index")
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
-	for k, v := range ind.Search(inputWords) {
-		resp = append(resp, FileResponse{
-			Filename: k,
-			Count:    v.Path,
-			Spacing:  v.Weight,
+	// words without hits are probably misspelled, similar terms are searched instead
+	missing := func(word string) bool { return ind.DocFreq(word) == 0 }
+	if fq, ok := query.Fuzzify(q, missing); ok && fuzzy != nil {
+		if err := query.Expand(fq, fuzzy.FuzzyTerms); err != nil {
+			log.Err(err).Str("input", searchWords).Msg("can not expand fuzzy words")
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		q, inputWords = fq, query.Terms(fq)
+		log.Debug().Msgf("fuzzy query: %v, terms: %+v", q, inputWords)
+		if ind, err = a.ind.GetIndex(query.AllTerms(q)...); err != nil {
+			log.Err(err).Msg("error while getting index")
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+	}
+	if query.NeedsAll(q) {
+		docs, err := a.ind.DocLengths()
+		if err != nil {
+			log.Err(err).Msg("error while getting documents")
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		ind.AddDocLengths(docs)
+	}
+	page := ind.Rank(query.Search(q, ind), a.scorer, offset, limit)
+	resp := SearchResponse{
+		Total:   page.Total,
+		Offset:  offset,
+		Limit:   limit,
+		Results: make([]FileResponse, 0, len(page.Results)),
+	}
+	for _, r := range page.Results {
+		snippets, err := ind.Snippets(r.File, inputWords, a.sources, snippetCount)
+		if err != nil {
+			log.Debug().Err(err).Str("file", r.File).Msg("can not make snippets")
+		}
+		resp.Results = append(resp.Results, FileResponse{
+			Filename: r.File,
+			Count:    r.Data.Path,
+			Spacing:  r.Data.Weight,
+			Score:    r.Score,
+			Offsets:  r.Data.Offsets,
+			Snippets: snippets,
 		})
 	}
 	log.Info().Interface("result", resp).Msgf("search finished")
@@ -118,6 +262,32 @@ func (a *App) searchHandler(w http.ResponseWriter, req *http.Request) {
 	log.Debug().Interface("headers", w.Header())
 }
 
+// parsePage reads offset and limit of the requested page, limit is capped by maxLimit
+func parsePage(req *http.Request) (int, int, error) {
+	offset, limit := 0, defaultLimit
+	var err error
+	if raw := req.FormValue("offset"); raw != "" {
+		if offset, err = strconv.Atoi(raw); err != nil {
+			return 0, 0, err
+		}
+		if offset < 0 {
+			return 0, 0, fmt.Errorf("negative offset %d", offset)
+		}
+	}
+	if raw := req.FormValue("limit"); raw != "" {
+		if limit, err = strconv.Atoi(raw); err != nil {
+			return 0, 0, err
+		}
+		if limit <= 0 {
+			return 0, 0, fmt.Errorf("non-positive limit %d", limit)
+		}
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+	return offset, limit, nil
+}
+
 func headerMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
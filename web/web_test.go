@@ -0,0 +1,138 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/polisgo2020/search-senyast4745/config"
+	"github.com/polisgo2020/search-senyast4745/index"
+	"github.com/stretchr/testify/require"
+)
+
+// snapshotIndexed serves snapshots of the index as the file index of the search command does
+type snapshotIndexed struct {
+	ind *index.Index
+}
+
+func (s *snapshotIndexed) GetIndex(str ...string) (*index.Index, error) {
+	return s.ind.Snapshot(str...), nil
+}
+
+func (s *snapshotIndexed) DocLengths() (map[string]int, error) {
+	return s.ind.DocLengths(), nil
+}
+
+// updatedIndexed keeps the texts of the added documents as the indexes of the search command do
+type updatedIndexed struct {
+	snapshotIndexed
+	texts map[string][]byte
+}
+
+func (u *updatedIndexed) AddDocument(_ context.Context, file string, reader io.Reader) error {
+	text, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	u.texts[file] = text
+	return u.ind.AddDocument(file, bytes.NewReader(text))
+}
+
+func (u *updatedIndexed) RemoveDocument(_ context.Context, file string) error {
+	delete(u.texts, file)
+	u.ind.RemoveDocument(file)
+	return nil
+}
+
+func (u *updatedIndexed) Text(file string) ([]byte, bool, error) {
+	text, ok := u.texts[file]
+	return text, ok, nil
+}
+
+func testApp(t *testing.T, docs map[string]string) *App {
+	ind := index.NewIndex()
+	for file, text := range docs {
+		require.NoError(t, ind.AddDocument(file, bytes.NewBufferString(text)))
+	}
+	app, err := NewApp(&config.Config{TimeOut: "1s", Scorer: "proximity"}, &snapshotIndexed{ind: ind})
+	require.NoError(t, err)
+	return app
+}
+
+func searchResponse(t *testing.T, app *App, search string) *SearchResponse {
+	req := httptest.NewRequest(http.MethodPost, "/?search="+url.QueryEscape(search), nil)
+	w := httptest.NewRecorder()
+	app.Mux.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp SearchResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return &resp
+}
+
+func searchFiles(t *testing.T, app *App, search string) []string {
+	resp := searchResponse(t, app, search)
+	files := make([]string, 0, len(resp.Results))
+	for _, r := range resp.Results {
+		files = append(files, r.Filename)
+	}
+	sort.Strings(files)
+	return files
+}
+
+func TestSearchHandler_Negation(t *testing.T) {
+	app := testApp(t, map[string]string{
+		"file1": "golang inverted index",
+		"file2": "golang search engine",
+	})
+	require.Equal(t, []string{"file1", "file2"}, searchFiles(t, app, "golang"))
+	require.Equal(t, []string{"file1"}, searchFiles(t, app, "golang -search"))
+	require.Equal(t, []string{"file1"}, searchFiles(t, app, "golang NOT search"))
+	require.Equal(t, []string{"file2"}, searchFiles(t, app, `golang -"inverted index"`))
+	require.Equal(t, []string{"file2"}, searchFiles(t, app, "search -unknown"))
+}
+
+func TestSearchHandler_NegationUniverse(t *testing.T) {
+	app := testApp(t, map[string]string{
+		"file1": "golang inverted index",
+		"file2": "golang search engine",
+		"file3": "python scripts",
+	})
+	require.Equal(t, []string{"file1", "file3"}, searchFiles(t, app, "index OR NOT golang"))
+	require.Equal(t, []string{"file1", "file3"}, searchFiles(t, app, "index OR -golang"))
+	require.Equal(t, []string{"file1"}, searchFiles(t, app, "index -search"), "excluded words do not add files")
+}
+
+func TestDocuments_Snippets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "web")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	local := filepath.Join(dir, "secret.txt")
+	require.NoError(t, ioutil.WriteFile(local, []byte("golang secret local text"), 0644))
+
+	ind := &updatedIndexed{snapshotIndexed: snapshotIndexed{ind: index.NewIndex()}, texts: make(map[string][]byte)}
+	app, err := NewApp(&config.Config{TimeOut: "1s", Scorer: "proximity", APIToken: "token"}, ind)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPut, "/documents/"+url.PathEscape(local), strings.NewReader("golang public text"))
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("Content-Type", "text/plain")
+	w := httptest.NewRecorder()
+	app.Mux.ServeHTTP(w, req)
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+
+	resp := searchResponse(t, app, "golang")
+	require.Len(t, resp.Results, 1)
+	require.Len(t, resp.Results[0].Snippets, 1)
+	require.Equal(t, "golang public text", resp.Results[0].Snippets[0].Text, "the local file must not be read")
+}